@@ -0,0 +1,287 @@
+package enrichers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	defaultArchiveMaxDepth = 5
+	defaultArchiveMaxRatio = 100
+	defaultArchiveMaxSize  = 256 * 1024 * 1024
+)
+
+// ErrArchiveTooLarge An archive expands to more than the ratio or size allowed, such as a zip bomb
+var ErrArchiveTooLarge = errors.New("archive expands too much")
+
+// ArchiveOptions How to unpack archives and compressed files (zip, tar, gzip, bzip2, xz, and 7z) found while reading.
+// Archives are detected by their magic bytes and replaced by their entries, named like archive.zip!/inner/path.
+// A compressed tar is unpacked in one step, so entries of backup.tar.gz are named backup.tar.gz!/inner/path
+type ArchiveOptions struct {
+	MaxDepth int     // Max number of archives inside archives to unpack.  0 for the default of 5
+	MaxRatio float64 // Max bytes unpacked per byte of the outermost archive.  0 for the default of 100
+	MaxSize  int64   // Max bytes unpacked from the outermost archive.  0 for the default of 256MB
+}
+
+// archiveMagic Archive formats and their magic bytes at the start of the data
+var archiveMagic = []struct {
+	format string
+	magic  []byte
+}{
+	{"zip", []byte("PK\x03\x04")},
+	{"zip", []byte("PK\x05\x06")}, // Empty
+	{"gzip", []byte{0x1f, 0x8b}},
+	{"bzip2", []byte("BZh")},
+	{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{"7z", []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}},
+}
+
+// ArchiveFormat Get the archive format of the data from its magic bytes: zip, tar, gzip, bzip2, xz, or 7z.
+// Empty if it is not an archive
+func ArchiveFormat(data []byte) string {
+	for _, archive := range archiveMagic {
+		if bytes.HasPrefix(data, archive.magic) {
+			return archive.format
+		}
+	}
+	if len(data) >= 262 && bytes.Equal(data[257:262], []byte("ustar")) {
+		return "tar"
+	}
+	return ""
+}
+
+// UnpackItem Get the entries of the item if it is an archive, recursively.  Items that are not archives, or that
+// fail to unpack, are returned as is.  Entries unpacked before an archive turns out to be too large are kept
+func UnpackItem(item *Item, options ArchiveOptions) []*Item {
+	return newUnpacker(item, options).unpack(item, 0)
+}
+
+// newUnpacker Create an unpacker for the item with the defaults filled in
+func newUnpacker(item *Item, options ArchiveOptions) *unpacker {
+	if options.MaxDepth <= 0 {
+		options.MaxDepth = defaultArchiveMaxDepth
+	}
+	if options.MaxRatio <= 0 {
+		options.MaxRatio = defaultArchiveMaxRatio
+	}
+	if options.MaxSize <= 0 {
+		options.MaxSize = defaultArchiveMaxSize
+	}
+
+	budget := int64(float64(len(item.Data)) * options.MaxRatio)
+	if budget > options.MaxSize {
+		budget = options.MaxSize
+	}
+	return &unpacker{maxDepth: options.MaxDepth, budget: budget}
+}
+
+// unpacker State of unpacking one item
+type unpacker struct {
+	maxDepth      int
+	budget        int64 // Bytes left to unpack
+	keepDocuments bool  // Do not unpack Office and OpenDocument files
+}
+
+// unpack Get the entries of the item if it is an archive and unpack them too
+func (unpacker *unpacker) unpack(item *Item, depth int) []*Item {
+	format := ArchiveFormat(item.Data)
+	if format == "" || depth >= unpacker.maxDepth {
+		return []*Item{item}
+	}
+	if format == "zip" && unpacker.keepDocuments && DocumentFormat(item.Data) != "" {
+		return []*Item{item}
+	}
+
+	entries, err := unpacker.entries(format, item)
+	if err != nil && len(entries) == 0 {
+		return []*Item{item}
+	}
+
+	items := []*Item{}
+	for _, entry := range entries {
+		items = append(items, unpacker.unpack(entry, depth+1)...)
+	}
+	return items
+}
+
+// entries Get the entries of the archive
+func (unpacker *unpacker) entries(format string, item *Item) ([]*Item, error) {
+	switch format {
+	case "zip":
+		return unpacker.zipEntries(item)
+	case "tar":
+		return unpacker.tarEntries(item)
+	case "7z":
+		return unpacker.sevenZipEntries(item)
+	}
+
+	// Compressed streams
+	var reader io.Reader
+	var err error
+	name := strings.TrimSuffix(path.Base(item.Name), path.Ext(item.Name))
+	switch format {
+	case "gzip":
+		var gzipReader *gzip.Reader
+		gzipReader, err = gzip.NewReader(bytes.NewReader(item.Data))
+		if err == nil && gzipReader.Name != "" {
+			name = path.Base(gzipReader.Name)
+		}
+		reader = gzipReader
+	case "bzip2":
+		reader = bzip2.NewReader(bytes.NewReader(item.Data))
+	case "xz":
+		reader, err = xz.NewReader(bytes.NewReader(item.Data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := unpacker.read(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unpack compressed tars in one step
+	if ArchiveFormat(data) == "tar" {
+		return unpacker.tarEntries(&Item{Name: item.Name, Data: data})
+	}
+	if name == "" || name == "." || name == "/" {
+		name = "data"
+	}
+	return []*Item{archiveEntry(item, format, name, data, -1, time.Time{})}, nil
+}
+
+// zipEntries Get the files in a zip
+func (unpacker *unpacker) zipEntries(item *Item) ([]*Item, error) {
+	reader, err := zip.NewReader(bytes.NewReader(item.Data), int64(len(item.Data)))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*Item{}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if file.UncompressedSize64 > uint64(unpacker.budget) {
+			return entries, ErrArchiveTooLarge
+		}
+		fileReader, err := file.Open()
+		if err != nil {
+			continue
+		}
+		data, err := unpacker.read(fileReader)
+		fileReader.Close()
+		if err == ErrArchiveTooLarge {
+			return entries, err
+		} else if err != nil {
+			continue
+		}
+		entries = append(entries, archiveEntry(item, "zip", file.Name, data, int64(file.UncompressedSize64), file.Modified))
+	}
+
+	return entries, nil
+}
+
+// tarEntries Get the regular files in a tar
+func (unpacker *unpacker) tarEntries(item *Item) ([]*Item, error) {
+	reader := tar.NewReader(bytes.NewReader(item.Data))
+
+	entries := []*Item{}
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return entries, err
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		data, err := unpacker.read(reader)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, archiveEntry(item, "tar", header.Name, data, header.Size, header.ModTime))
+	}
+}
+
+// sevenZipEntries Get the files in a 7z
+func (unpacker *unpacker) sevenZipEntries(item *Item) (entries []*Item, err error) {
+	// The 7z library panics on some malformed headers
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("bad 7z: %v", r)
+		}
+	}()
+
+	reader, err := sevenzip.NewReader(bytes.NewReader(item.Data), int64(len(item.Data)))
+	if err != nil {
+		return nil, err
+	}
+
+	entries = []*Item{}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if file.UncompressedSize > uint64(unpacker.budget) {
+			return entries, ErrArchiveTooLarge
+		}
+		fileReader, err := file.Open()
+		if err != nil {
+			continue
+		}
+		data, err := unpacker.read(fileReader)
+		fileReader.Close()
+		if err == ErrArchiveTooLarge {
+			return entries, err
+		} else if err != nil {
+			continue
+		}
+		entries = append(entries, archiveEntry(item, "7z", file.Name, data, int64(file.UncompressedSize), file.Modified))
+	}
+
+	return entries, nil
+}
+
+// read Read everything from the reader within the budget
+func (unpacker *unpacker) read(reader io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, unpacker.budget+1))
+	if int64(len(data)) > unpacker.budget {
+		unpacker.budget = 0
+		return nil, ErrArchiveTooLarge
+	}
+	unpacker.budget -= int64(len(data))
+	return data, err
+}
+
+// archiveEntry Create an item for an entry of the archive named like archive!/inner/path.  size is -1 and modTime
+// zero when unknown
+func archiveEntry(archive *Item, format, name string, data []byte, size int64, modTime time.Time) *Item {
+	entry := &Item{
+		Name:     archive.Name + "!/" + strings.TrimPrefix(path.Clean("/"+name), "/"),
+		Data:     data,
+		Metadata: map[string]string{"parent": archive.Name, "archive": format},
+	}
+	if size >= 0 {
+		entry.Metadata["size"] = strconv.FormatInt(size, 10)
+	}
+	if !modTime.IsZero() {
+		entry.Metadata["modified"] = modTime.Format(time.RFC3339)
+	}
+	return entry
+}
@@ -0,0 +1,266 @@
+package enrichers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/ulikunitz/xz"
+)
+
+// bzip2Secret "bzip2 secret" compressed with bzip2, which the standard library can not write
+var bzip2Secret, _ = hex.DecodeString("425a68393141592653594907a69b0000011980400010001a205c1020003100d34d0401a32402094910bc5dc914e14241241e9a6c")
+
+// archiveFile A file to put in a test archive
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+func zipArchive(t *testing.T, files ...archiveFile) []byte {
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	for _, file := range files {
+		fileWriter, err := writer.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fileWriter.Write(file.data)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func tarArchive(t *testing.T, files ...archiveFile) []byte {
+	buffer := &bytes.Buffer{}
+	writer := tar.NewWriter(buffer)
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.data)), ModTime: fakeFTPModTime, Typeflag: tar.TypeReg}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		writer.Write(file.data)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func gzipData(t *testing.T, name string, data []byte) []byte {
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	writer.Name = name
+	writer.Write(data)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func xzData(t *testing.T, data []byte) []byte {
+	buffer := &bytes.Buffer{}
+	writer, err := xz.NewWriter(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write(data)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// sevenZipArchive A 7z archive that stores the files without compression.  Sizes must be under 128 bytes
+func sevenZipArchive(crcs bool, files ...archiveFile) []byte {
+	packed := []byte{}
+	for _, file := range files {
+		packed = append(packed, file.data...)
+	}
+
+	names := []byte{}
+	for _, file := range files {
+		for _, char := range utf16.Encode([]rune(file.name + "\x00")) {
+			names = append(names, byte(char), byte(char>>8))
+		}
+	}
+
+	header := []byte{
+		0x01,                                            // Header
+		0x04,                                            // Main streams info
+		0x06, 0x00, 0x01, 0x09, byte(len(packed)), 0x00, // Pack info: one stream at 0
+		0x07, 0x0b, 0x01, 0x00, 0x01, 0x01, 0x00, // Unpack info: one folder with the copy coder
+		0x0c, byte(len(packed)), 0x00,
+		0x08, 0x0d, byte(len(files)), 0x09, // Sub streams info
+	}
+	for _, file := range files[0 : len(files)-1] {
+		header = append(header, byte(len(file.data)))
+	}
+	if crcs {
+		header = append(header, 0x0a, 0x01) // Every CRC
+		for _, file := range files {
+			crc := make([]byte, 4)
+			binary.LittleEndian.PutUint32(crc, crc32.ChecksumIEEE(file.data))
+			header = append(header, crc...)
+		}
+	}
+	header = append(header, 0x00, 0x00)
+	header = append(header, 0x05, byte(len(files)), 0x11, byte(len(names)+1), 0x00) // Files info with names
+	header = append(header, names...)
+	header = append(header, 0x00, 0x00)
+
+	start := make([]byte, 20)
+	binary.LittleEndian.PutUint64(start[0:], uint64(len(packed)))
+	binary.LittleEndian.PutUint64(start[8:], uint64(len(header)))
+	binary.LittleEndian.PutUint32(start[16:], crc32.ChecksumIEEE(header))
+	archive := []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c, 0, 4, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(archive[8:], crc32.ChecksumIEEE(start))
+	archive = append(archive, start...)
+	archive = append(archive, packed...)
+	return append(archive, header...)
+}
+
+// itemNames Get the names of the items and their data
+func itemNames(items []*Item) map[string]string {
+	names := map[string]string{}
+	for _, item := range items {
+		names[item.Name] = string(item.Data)
+	}
+	return names
+}
+
+func TestUnpackItem(t *testing.T) {
+	nested := zipArchive(t, archiveFile{"inner/creds.txt", []byte("zip secret")})
+	// Without CRCs, which panics the 7z library
+	noCRC := sevenZipArchive(false, archiveFile{"a.txt", []byte("a")})
+
+	tests := []struct {
+		name  string
+		data  []byte
+		items map[string]string
+	}{
+		{"plain.txt", []byte("plain"), map[string]string{"plain.txt": "plain"}},
+		{"a.zip", zipArchive(t, archiveFile{"dir/a.txt", []byte("a")}, archiveFile{"b.txt", []byte("b")}),
+			map[string]string{"a.zip!/dir/a.txt": "a", "a.zip!/b.txt": "b"}},
+		{"backup.tar.gz", gzipData(t, "", tarArchive(t, archiveFile{"etc/passwd", []byte("root")})),
+			map[string]string{"backup.tar.gz!/etc/passwd": "root"}},
+		{"dump.sql.gz", gzipData(t, "", []byte("gzip secret")), map[string]string{"dump.sql.gz!/dump.sql": "gzip secret"}},
+		{"renamed.gz", gzipData(t, "original.sql", []byte("gzip secret")), map[string]string{"renamed.gz!/original.sql": "gzip secret"}},
+		{"notes.bz2", bzip2Secret, map[string]string{"notes.bz2!/notes": "bzip2 secret"}},
+		{"log.xz", xzData(t, []byte("xz secret")), map[string]string{"log.xz!/log": "xz secret"}},
+		{"files.7z", sevenZipArchive(true, archiveFile{"a.txt", []byte("7z a")}, archiveFile{"dir/b.txt", []byte("7z b")}),
+			map[string]string{"files.7z!/a.txt": "7z a", "files.7z!/dir/b.txt": "7z b"}},
+		// Archives in archives
+		{"outer.tar", tarArchive(t, archiveFile{"nested.zip", nested}),
+			map[string]string{"outer.tar!/nested.zip!/inner/creds.txt": "zip secret"}},
+		// Broken archives are read as is
+		{"broken.zip", []byte("PK\x03\x04broken"), map[string]string{"broken.zip": "PK\x03\x04broken"}},
+		{"nocrc.7z", noCRC, map[string]string{"nocrc.7z": string(noCRC)}},
+	}
+
+	for _, test := range tests {
+		items := UnpackItem(&Item{Name: test.name, Data: test.data}, ArchiveOptions{})
+		if names := itemNames(items); !reflect.DeepEqual(names, test.items) {
+			t.Errorf("Wrong items for %s: %v", test.name, names)
+		}
+	}
+
+	// Metadata
+	items := UnpackItem(&Item{Name: "backup.tar", Data: tarArchive(t, archiveFile{"a", []byte("data")})}, ArchiveOptions{})
+	if metadata := items[0].Metadata; metadata["parent"] != "backup.tar" || metadata["archive"] != "tar" || metadata["size"] != "4" || metadata["modified"] != "2006-01-02T15:04:05Z" {
+		t.Errorf("Wrong metadata %v", metadata)
+	}
+}
+
+func TestUnpackItemLimits(t *testing.T) {
+	// Nesting
+	nested := gzipData(t, "", gzipData(t, "", gzipData(t, "", []byte("deep"))))
+	items := UnpackItem(&Item{Name: "x.gz", Data: nested}, ArchiveOptions{MaxDepth: 2})
+	if len(items) != 1 || items[0].Name != "x.gz!/x!/x" || ArchiveFormat(items[0].Data) != "gzip" {
+		t.Errorf("Did not stop at max depth: %v", itemNames(items))
+	}
+
+	// Zip bomb
+	bomb := zipArchive(t, archiveFile{"small.txt", []byte("small")}, archiveFile{"zeros", make([]byte, 10*1024*1024)})
+	if len(bomb) > 100*1024 {
+		t.Fatalf("Bomb did not compress: %d", len(bomb))
+	}
+	items = UnpackItem(&Item{Name: "bomb.zip", Data: bomb}, ArchiveOptions{})
+	if names := itemNames(items); !reflect.DeepEqual(names, map[string]string{"bomb.zip!/small.txt": "small"}) {
+		t.Errorf("Unpacked bomb: %d items", len(items))
+	}
+	// Allowed with a higher ratio
+	items = UnpackItem(&Item{Name: "bomb.zip", Data: bomb}, ArchiveOptions{MaxRatio: 10000})
+	if len(items) != 2 {
+		t.Errorf("Wrong items with a high ratio: %d", len(items))
+	}
+	// Bombs that hide the size in a stream
+	items = UnpackItem(&Item{Name: "zeros.gz", Data: gzipData(t, "", make([]byte, 10*1024*1024))}, ArchiveOptions{})
+	if len(items) != 1 || items[0].Name != "zeros.gz" {
+		t.Errorf("Unpacked gzip bomb")
+	}
+	items = UnpackItem(&Item{Name: "zeros.gz", Data: gzipData(t, "", make([]byte, 10*1024*1024))}, ArchiveOptions{MaxRatio: 10000, MaxSize: 1024})
+	if len(items) != 1 || items[0].Name != "zeros.gz" {
+		t.Errorf("Unpacked more than the max size")
+	}
+}
+
+func TestFTPArchives(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{
+		"/backup.zip": zipArchive(t, archiveFile{"db/dump.sql", []byte("password=secret")}),
+		"/plain.txt":  []byte("plain"),
+	}}
+	client := connectFakeFTP(t, server, FTPOptions{Concurrency: 1, Archives: &ArchiveOptions{}})
+	defer server.Close()
+	defer client.Close()
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil || string(data) != "password=secretplain" {
+		t.Errorf("Wrong data %q %v", data, err)
+	}
+}
+
+func TestHTTPArchives(t *testing.T) {
+	archive := gzipData(t, "", tarArchive(t, archiveFile{"config/.env", []byte("API_KEY=secret")}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPWithOptions(server.URL+"/site.tar.gz", HTTPOptions{Archives: &ArchiveOptions{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	items, err := client.Items(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for item := range items {
+		names = append(names, item.Name)
+		if string(item.Data) != "API_KEY=secret" {
+			t.Errorf("Wrong data %q", item.Data)
+		}
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{server.URL + "/site.tar.gz!/config/.env"}) {
+		t.Errorf("Wrong items %v", names)
+	}
+}
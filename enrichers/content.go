@@ -0,0 +1,266 @@
+package enrichers
+
+import (
+	"bytes"
+	"math"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	sniffLength = 4096 // Bytes checked for the type and for UTF-16 without a byte order mark
+)
+
+// ContentInfo What the data of an item is, detected from its bytes and name
+type ContentInfo struct {
+	Type     string  // MIME type without parameters, such as text/html or image/png
+	Encoding string  // Text encoding: utf-8, utf-16le, utf-16be, or latin-1.  Empty for binary data
+	Entropy  float64 // Shannon entropy in bits per byte, from 0 to 8.  Compressed and encrypted data is close to 8
+}
+
+// IsText Check if the data is text in a known encoding
+func (info ContentInfo) IsText() bool {
+	return info.Encoding != ""
+}
+
+// magicTypes MIME types of the archive and document formats found by ArchiveFormat and DocumentFormat
+var magicTypes = map[string]string{
+	"zip":   "application/zip",
+	"gzip":  "application/gzip",
+	"bzip2": "application/x-bzip2",
+	"xz":    "application/x-xz",
+	"7z":    "application/x-7z-compressed",
+	"tar":   "application/x-tar",
+	"pdf":   "application/pdf",
+	"docx":  "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"xlsx":  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"pptx":  "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"odt":   "application/vnd.oasis.opendocument.text",
+	"ods":   "application/vnd.oasis.opendocument.spreadsheet",
+	"odp":   "application/vnd.oasis.opendocument.presentation",
+}
+
+// textExtensionTypes MIME types of text files that can only be told apart by extension
+var textExtensionTypes = map[string]string{
+	".sql":  "application/sql",
+	".json": "application/json",
+	".csv":  "text/csv",
+	".tsv":  "text/tab-separated-values",
+	".xml":  "text/xml",
+	".js":   "text/javascript",
+	".css":  "text/css",
+	".md":   "text/markdown",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".sh":   "application/x-sh",
+	".py":   "text/x-python",
+	".php":  "application/x-httpd-php",
+}
+
+// windows1252 Characters of the bytes 0x80 to 0x9f, which are control codes in Latin-1 but almost always mean these
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// SniffContent Detect the MIME type, text encoding, and entropy of the data.  The type comes from magic bytes and
+// falls back to the extension of the name
+func SniffContent(name string, data []byte) ContentInfo {
+	info := ContentInfo{Entropy: Entropy(data)}
+
+	// Binary formats with magic bytes
+	if format := DocumentFormat(data); format != "" {
+		info.Type = magicTypes[format]
+		return info
+	}
+	if format := ArchiveFormat(data); format != "" {
+		info.Type = magicTypes[format]
+		return info
+	}
+	detected := mediaType(http.DetectContentType(data))
+	if detected != "application/octet-stream" && !strings.HasPrefix(detected, "text/") {
+		info.Type = detected
+		return info
+	}
+
+	info.Encoding = textEncoding(data)
+	extension := strings.ToLower(path.Ext(strings.SplitN(strings.SplitN(name, "?", 2)[0], "#", 2)[0]))
+	if !info.IsText() {
+		info.Type = "application/octet-stream"
+		if extensionType := mediaType(mime.TypeByExtension(extension)); extensionType != "" {
+			info.Type = extensionType
+		}
+		return info
+	}
+
+	// Type of the text once it is UTF-8, refined by the extension when it is plain text
+	sample := data
+	if len(sample) > sniffLength {
+		sample = sample[0:sniffLength]
+	}
+	info.Type = mediaType(http.DetectContentType(TranscodeUTF8(sample, info.Encoding)))
+	if info.Type == "text/plain" {
+		if extensionType, ok := textExtensionTypes[extension]; ok {
+			info.Type = extensionType
+		} else if extensionType := mediaType(mime.TypeByExtension(extension)); strings.HasPrefix(extensionType, "text/") {
+			info.Type = extensionType
+		}
+	}
+	return info
+}
+
+// mediaType Remove the parameters from a MIME type
+func mediaType(contentType string) string {
+	return strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+}
+
+// textEncoding Get the encoding of the data if it is text
+func textEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8"
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return "utf-16le"
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return "utf-16be"
+	}
+	if encoding := utf16Encoding(data); encoding != "" {
+		return encoding
+	}
+	if utf8.Valid(data) {
+		if isMostlyText(data) {
+			return "utf-8"
+		}
+		return ""
+	}
+
+	// Single byte text has few control codes
+	control := 0
+	for _, b := range data {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			control++
+		}
+	}
+	if control*20 < len(data) {
+		return "latin-1"
+	}
+	return ""
+}
+
+// utf16Encoding Guess if the data is UTF-16 without a byte order mark.  Latin text in UTF-16 has a zero in every
+// other byte
+func utf16Encoding(data []byte) string {
+	if len(data) > sniffLength {
+		data = data[0:sniffLength]
+	}
+	pairs := len(data) / 2
+	if pairs < 2 {
+		return ""
+	}
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		}
+		if data[i+1] == 0 {
+			oddZeros++
+		}
+	}
+
+	encoding := ""
+	if oddZeros*10 > pairs*4 && evenZeros*20 < pairs {
+		encoding = "utf-16le"
+	} else if evenZeros*10 > pairs*4 && oddZeros*20 < pairs {
+		encoding = "utf-16be"
+	}
+	// Arrays of small numbers look the same, make sure it decodes to text
+	if encoding == "" || !isMostlyText(TranscodeUTF8(data, encoding)) {
+		return ""
+	}
+	return encoding
+}
+
+// TranscodeUTF8 Convert text in the encoding (from SniffContent) to UTF-8 without a byte order mark.  Data in other
+// encodings is returned as is
+func TranscodeUTF8(data []byte, encoding string) []byte {
+	switch encoding {
+	case "utf-8":
+		return bytes.TrimPrefix(data, []byte{0xef, 0xbb, 0xbf})
+	case "utf-16le", "utf-16be":
+		if bytes.HasPrefix(data, []byte{0xff, 0xfe}) || bytes.HasPrefix(data, []byte{0xfe, 0xff}) {
+			data = data[2:]
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if encoding == "utf-16le" {
+				units[i] = uint16(data[i*2]) | uint16(data[i*2+1])<<8
+			} else {
+				units[i] = uint16(data[i*2])<<8 | uint16(data[i*2+1])
+			}
+		}
+		return []byte(string(utf16.Decode(units)))
+	case "latin-1":
+		decoded := make([]rune, len(data))
+		for i, b := range data {
+			if b >= 0x80 && b <= 0x9f {
+				decoded[i] = windows1252[b-0x80]
+			} else {
+				decoded[i] = rune(b)
+			}
+		}
+		return []byte(string(decoded))
+	}
+	return data
+}
+
+// Entropy Get the Shannon entropy of the data in bits per byte
+func Entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	counts := [256]int{}
+	for _, b := range data {
+		counts[b]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(len(data))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// sniffItem Add the content-type, encoding, and entropy of the item to its metadata and convert text to UTF-8 so
+// it can be matched.  Types already in the metadata are kept, and items that were already sniffed are left alone
+func sniffItem(item *Item) {
+	if item.Metadata == nil {
+		item.Metadata = map[string]string{}
+	}
+	if _, ok := item.Metadata["entropy"]; ok {
+		return
+	}
+
+	info := SniffContent(item.Name, item.Data)
+	setContentMetadata(item, info)
+	if info.IsText() {
+		item.Data = TranscodeUTF8(item.Data, info.Encoding)
+	}
+}
+
+// setContentMetadata Add the content info to the metadata of the item
+func setContentMetadata(item *Item, info ContentInfo) {
+	if _, ok := item.Metadata["content-type"]; !ok {
+		item.Metadata["content-type"] = info.Type
+	}
+	if info.IsText() {
+		item.Metadata["encoding"] = info.Encoding
+	}
+	item.Metadata["entropy"] = strconv.FormatFloat(info.Entropy, 'f', 2, 64)
+}
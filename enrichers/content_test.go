@@ -0,0 +1,139 @@
+package enrichers
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// utf16Data Encode the text as UTF-16, with a byte order mark if bom is set
+func utf16Data(text string, bigEndian, bom bool) []byte {
+	units := utf16.Encode([]rune(text))
+	if bom {
+		units = append([]uint16{0xfeff}, units...)
+	}
+	data := []byte{}
+	for _, unit := range units {
+		if bigEndian {
+			data = append(data, byte(unit>>8), byte(unit))
+		} else {
+			data = append(data, byte(unit), byte(unit>>8))
+		}
+	}
+	return data
+}
+
+func TestSniffContent(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		encoding    string
+		text        string
+	}{
+		{"page.html", []byte("<!DOCTYPE html><html><body>hi</body></html>"), "text/html", "utf-8", "<!DOCTYPE html><html><body>hi</body></html>"},
+		{"dump.sql", []byte("INSERT INTO users VALUES ('root');"), "application/sql", "utf-8", "INSERT INTO users VALUES ('root');"},
+		{"http://host/data.json?page=2", []byte(`{"key": "value"}`), "application/json", "utf-8", `{"key": "value"}`},
+		{"bom.txt", []byte("\xef\xbb\xbfpassword"), "text/plain", "utf-8", "password"},
+		{"errors", utf16Data("Login failed for sa", false, true), "text/plain", "utf-16le", "Login failed for sa"},
+		{"errors", utf16Data("Login failed for sa", true, true), "text/plain", "utf-16be", "Login failed for sa"},
+		{"export.csv", utf16Data("name,pass\nJosé,secret", false, false), "text/csv", "utf-16le", "name,pass\nJosé,secret"},
+		{"dump.sql", []byte("INSERT INTO users VALUES ('Jos\xe9', '\x93quoted\x94');"), "application/sql", "latin-1", "INSERT INTO users VALUES ('José', '“quoted”');"},
+		{"image.jpg", png, "image/png", "", ""},
+		{"unknown.jpg", []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, "image/jpeg", "", ""},
+		{"random", []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, "application/octet-stream", "", ""},
+		{"backup.zip", zipArchive(t, archiveFile{"a.txt", []byte("a")}), "application/zip", "", ""},
+		{"report", testDocx(t), "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "", ""},
+	}
+
+	for _, test := range tests {
+		info := SniffContent(test.name, test.data)
+		if info.Type != test.contentType || info.Encoding != test.encoding {
+			t.Errorf("Wrong content of %s: %+v", test.name, info)
+		}
+		if info.IsText() {
+			if text := string(TranscodeUTF8(test.data, info.Encoding)); text != test.text {
+				t.Errorf("Wrong text of %s: %q", test.name, text)
+			}
+		}
+	}
+
+	// Arrays of small numbers are not UTF-16
+	numbers := []byte{}
+	for i := 0; i < 100; i++ {
+		numbers = append(numbers, byte(i%10), 0)
+	}
+	if info := SniffContent("numbers", numbers); info.IsText() {
+		t.Errorf("Numbers detected as %s", info.Encoding)
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	everyByte := make([]byte, 256)
+	for i := range everyByte {
+		everyByte[i] = byte(i)
+	}
+
+	tests := []struct {
+		data    []byte
+		entropy float64
+	}{
+		{nil, 0},
+		{[]byte("aaaa"), 0},
+		{[]byte("abab"), 1},
+		{everyByte, 8},
+	}
+	for _, test := range tests {
+		if entropy := Entropy(test.data); math.Abs(entropy-test.entropy) > 0.0001 {
+			t.Errorf("Wrong entropy of %q: %f", test.data, entropy)
+		}
+	}
+}
+
+func TestHTTPItemMetadata(t *testing.T) {
+	log := utf16Data("ERROR password=hunter2", false, true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(log)
+	}))
+	defer server.Close()
+
+	// Pages are dumped, the metadata describes the body
+	client, err := NewHTTP(server.URL + "/errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	items, err := client.Items(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for item := range items {
+		if !strings.HasSuffix(string(item.Data), "\n\nERROR password=hunter2") {
+			t.Errorf("Not transcoded %q", item.Data)
+		}
+		if item.Metadata["content-type"] != "text/plain" || item.Metadata["encoding"] != "utf-16le" || item.Metadata["entropy"] == "" || item.Metadata["status"] != "200" {
+			t.Errorf("Wrong metadata %v", item.Metadata)
+		}
+	}
+}
+
+func TestFTPTranscode(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{"/users.csv": utf16Data("user,password\nadmin,hunter2", false, true)}}
+	client := connectFakeFTP(t, server, FTPOptions{})
+	defer server.Close()
+	defer client.Close()
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil || string(data) != "user,password\nadmin,hunter2" {
+		t.Errorf("Wrong data %q %v", data, err)
+	}
+}
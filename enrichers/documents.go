@@ -0,0 +1,354 @@
+package enrichers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxDocumentPartSize     = 64 * 1024 * 1024 // Max bytes decompressed from one part of a document
+	maxDocumentMimetypeSize = 256              // Max bytes read of the mimetype file of an OpenDocument file
+)
+
+// ErrNotDocument The data is not a supported document
+var ErrNotDocument = errors.New("not a supported document")
+
+// opendocumentFormats OpenDocument formats by the mimetype file in the zip
+var opendocumentFormats = map[string]string{
+	"application/vnd.oasis.opendocument.text":         "odt",
+	"application/vnd.oasis.opendocument.spreadsheet":  "ods",
+	"application/vnd.oasis.opendocument.presentation": "odp",
+}
+
+// DocumentFormat Get the format of the document: docx, xlsx, pptx, odt, ods, odp, or pdf.  Empty if it is not a
+// supported document
+func DocumentFormat(data []byte) string {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "pdf"
+	}
+	if ArchiveFormat(data) != "zip" {
+		return ""
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return ""
+	}
+	return zipDocumentFormat(reader)
+}
+
+// zipDocumentFormat Get the format of a zip based document from the files in it
+func zipDocumentFormat(reader *zip.Reader) string {
+	for _, file := range reader.File {
+		switch file.Name {
+		case "word/document.xml":
+			return "docx"
+		case "xl/workbook.xml":
+			return "xlsx"
+		case "ppt/presentation.xml":
+			return "pptx"
+		case "mimetype":
+			mimetype, err := readZipFile(file, &unpacker{budget: maxDocumentMimetypeSize})
+			if err == nil {
+				if format, ok := opendocumentFormats[strings.TrimSpace(string(mimetype))]; ok {
+					return format
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// DocumentText Get the text of an Office, OpenDocument, or PDF document along with its format.
+// ErrNotDocument if it is not one
+func DocumentText(data []byte) (format string, text []byte, err error) {
+	return DocumentTextWithOptions(data, ArchiveOptions{})
+}
+
+// DocumentTextWithOptions Get the text of a document, decompressing its parts within the ratio and size of the
+// options like an archive.  Text read before the limit is reached is returned with ErrArchiveTooLarge
+func DocumentTextWithOptions(data []byte, options ArchiveOptions) (format string, text []byte, err error) {
+	return documentText(data, newUnpacker(&Item{Data: data}, options))
+}
+
+// documentText Get the text of a document, decompressing its parts within the budget of the unpacker
+func documentText(data []byte, unpacker *unpacker) (format string, text []byte, err error) {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "pdf", pdfText(data, unpacker), nil
+	}
+	if ArchiveFormat(data) != "zip" {
+		return "", nil, ErrNotDocument
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil, ErrNotDocument
+	}
+	format = zipDocumentFormat(reader)
+	switch format {
+	case "docx":
+		// Body then headers, footers, and notes
+		text, err = ooxmlText(reader, unpacker, "word/document.xml", "word/header", "word/footer", "word/footnotes.xml", "word/endnotes.xml", "word/comments.xml")
+	case "pptx":
+		text, err = ooxmlText(reader, unpacker, "ppt/slides/slide", "ppt/notesSlides/notesSlide")
+	case "xlsx":
+		text, err = xlsxText(reader, unpacker)
+	case "odt", "ods", "odp":
+		text, err = odfText(reader, unpacker)
+	default:
+		return "", nil, ErrNotDocument
+	}
+
+	return format, text, err
+}
+
+// documentItem Get the text of the item as a child item (#text) if it is a document, decompressing its parts within
+// the budget of the unpacker.  nil if it is not
+func documentItem(item *Item, unpacker *unpacker) *Item {
+	format, text, err := documentText(item.Data, unpacker)
+	if err != nil {
+		return nil
+	}
+	child := childItem(item, "text", text)
+	child.Metadata["format"] = format
+	return child
+}
+
+// readZipFile Read a file in a zip up to the max part size within the budget of the unpacker
+func readZipFile(file *zip.File, unpacker *unpacker) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return unpacker.read(io.LimitReader(reader, maxDocumentPartSize))
+}
+
+// zipFiles Get the files in the zip that are named, or start with, the prefixes in that order.
+// Files matching one prefix are sorted by the number in their name, so slide10 comes after slide9
+func zipFiles(reader *zip.Reader, prefixes ...string) []*zip.File {
+	files := []*zip.File{}
+	for _, prefix := range prefixes {
+		matched := []*zip.File{}
+		for _, file := range reader.File {
+			if strings.HasPrefix(file.Name, prefix) && strings.HasSuffix(file.Name, ".xml") {
+				matched = append(matched, file)
+			}
+		}
+		sort.Slice(matched, func(i, j int) bool {
+			return partNumber(matched[i].Name) < partNumber(matched[j].Name)
+		})
+		files = append(files, matched...)
+	}
+	return files
+}
+
+// partNumber Get the number in a part name like ppt/slides/slide12.xml
+func partNumber(name string) int {
+	name = strings.TrimSuffix(name[strings.LastIndex(name, "/")+1:], ".xml")
+	start := len(name)
+	for start > 0 && name[start-1] >= '0' && name[start-1] <= '9' {
+		start--
+	}
+	number, _ := strconv.Atoi(name[start:])
+	return number
+}
+
+// xmlTextRules How to get text out of the XML of a document format.  Elements are matched by local name
+type xmlTextRules struct {
+	text    map[string]bool   // Elements whose character data is text
+	lines   map[string]bool   // Elements ending with a newline
+	cells   map[string]bool   // Elements ending with a tab
+	symbols map[string]string // Empty elements standing for text
+	ignore  map[string]bool   // Elements whose children are not text
+}
+
+// ooxmlRules Text in Word and PowerPoint XML
+var ooxmlRules = xmlTextRules{
+	text:    map[string]bool{"t": true},
+	lines:   map[string]bool{"p": true, "tr": true},
+	cells:   map[string]bool{"tc": true},
+	symbols: map[string]string{"tab": "\t", "br": "\n", "cr": "\n"},
+	ignore:  map[string]bool{"tabs": true}, // Tab stops
+}
+
+// odfRules Text in OpenDocument XML
+var odfRules = xmlTextRules{
+	text:    map[string]bool{"p": true, "h": true},
+	lines:   map[string]bool{"p": true, "h": true, "table-row": true},
+	cells:   map[string]bool{"table-cell": true},
+	symbols: map[string]string{"tab": "\t", "line-break": "\n", "s": " "},
+}
+
+// xmlText Get the text out of document XML
+func xmlText(data []byte, rules xmlTextRules, text *bytes.Buffer) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	inText := 0
+	parents := []string{}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if rules.text[token.Name.Local] {
+				inText++
+			}
+			if symbol, ok := rules.symbols[token.Name.Local]; ok && (len(parents) == 0 || !rules.ignore[parents[len(parents)-1]]) {
+				text.WriteString(symbol)
+			}
+			parents = append(parents, token.Name.Local)
+		case xml.EndElement:
+			if len(parents) > 0 {
+				parents = parents[0 : len(parents)-1]
+			}
+			if rules.text[token.Name.Local] && inText > 0 {
+				inText--
+			}
+			// Cells hold paragraphs.  Keep a row on one line
+			if rules.cells[token.Name.Local] {
+				text.Truncate(len(bytes.TrimRight(text.Bytes(), "\n")))
+				text.WriteByte('\t')
+			} else if rules.lines[token.Name.Local] {
+				text.Truncate(len(bytes.TrimRight(text.Bytes(), "\t")))
+				text.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText > 0 {
+				text.Write(token)
+			}
+		}
+	}
+}
+
+// ooxmlText Get the text of the parts of a Word or PowerPoint document
+func ooxmlText(reader *zip.Reader, unpacker *unpacker, prefixes ...string) ([]byte, error) {
+	text := &bytes.Buffer{}
+	for _, file := range zipFiles(reader, prefixes...) {
+		data, err := readZipFile(file, unpacker)
+		if err != nil {
+			return text.Bytes(), err
+		}
+		if err := xmlText(data, ooxmlRules, text); err != nil {
+			return text.Bytes(), err
+		}
+	}
+	return text.Bytes(), nil
+}
+
+// odfText Get the text of an OpenDocument document
+func odfText(reader *zip.Reader, unpacker *unpacker) ([]byte, error) {
+	text := &bytes.Buffer{}
+	for _, file := range zipFiles(reader, "content.xml") {
+		data, err := readZipFile(file, unpacker)
+		if err != nil {
+			return text.Bytes(), err
+		}
+		if err := xmlText(data, odfRules, text); err != nil {
+			return text.Bytes(), err
+		}
+	}
+	return text.Bytes(), nil
+}
+
+// xlsxString A string in an Excel workbook, either plain or in formatted runs
+type xlsxString struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+// text Get the whole string
+func (s xlsxString) text() string {
+	text := s.Text
+	for _, run := range s.Runs {
+		text += run.Text
+	}
+	return text
+}
+
+// xlsxCell A cell of an Excel sheet
+type xlsxCell struct {
+	Type   string     `xml:"t,attr"`
+	Value  string     `xml:"v"`
+	Inline xlsxString `xml:"is"`
+}
+
+// xlsxSheet Rows of an Excel sheet
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// xlsxText Get the cells of every sheet of an Excel workbook, a line per row with tabs between cells
+func xlsxText(reader *zip.Reader, unpacker *unpacker) ([]byte, error) {
+	// Strings are stored once and referenced by index from cells
+	sharedStrings := []string{}
+	for _, file := range zipFiles(reader, "xl/sharedStrings.xml") {
+		data, err := readZipFile(file, unpacker)
+		if err != nil {
+			return nil, err
+		}
+		var table struct {
+			Items []xlsxString `xml:"si"`
+		}
+		if err := xml.Unmarshal(data, &table); err != nil {
+			return nil, err
+		}
+		for _, item := range table.Items {
+			sharedStrings = append(sharedStrings, item.text())
+		}
+	}
+
+	text := &bytes.Buffer{}
+	for _, file := range zipFiles(reader, "xl/worksheets/sheet") {
+		data, err := readZipFile(file, unpacker)
+		if err != nil {
+			return text.Bytes(), err
+		}
+		var sheet xlsxSheet
+		if err := xml.Unmarshal(data, &sheet); err != nil {
+			return text.Bytes(), err
+		}
+
+		for _, row := range sheet.Rows {
+			for i, cell := range row.Cells {
+				if i > 0 {
+					text.WriteByte('\t')
+				}
+				text.WriteString(cell.text(sharedStrings))
+			}
+			text.WriteByte('\n')
+		}
+		text.WriteByte('\n')
+	}
+	return text.Bytes(), nil
+}
+
+// text Get the text of the cell
+func (cell xlsxCell) text(sharedStrings []string) string {
+	switch cell.Type {
+	case "s":
+		index, err := strconv.Atoi(cell.Value)
+		if err != nil || index < 0 || index >= len(sharedStrings) {
+			return ""
+		}
+		return sharedStrings[index]
+	case "inlineStr":
+		return cell.Inline.text()
+	}
+	return cell.Value
+}
@@ -0,0 +1,217 @@
+package enrichers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	wordNamespace = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	drawNamespace = `xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"`
+	odfNamespace  = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"`
+)
+
+func testDocx(t *testing.T) []byte {
+	return zipArchive(t,
+		archiveFile{"[Content_Types].xml", []byte(`<Types/>`)},
+		archiveFile{"word/document.xml", []byte(`<w:document ` + wordNamespace + `><w:body>
+			<w:p><w:pPr><w:tabs><w:tab w:val="left" w:pos="720"/></w:tabs></w:pPr><w:r><w:t>Confidential</w:t></w:r><w:r><w:t xml:space="preserve"> report</w:t></w:r></w:p>
+			<w:p><w:r><w:t>user:</w:t><w:tab/><w:t>admin</w:t></w:r></w:p>
+			<w:tbl><w:tr><w:tc><w:p><w:r><w:t>password</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>hunter2</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+			<w:p><w:r><w:instrText>PAGE</w:instrText></w:r></w:p>
+		</w:body></w:document>`)},
+		archiveFile{"word/footer1.xml", []byte(`<w:ftr ` + wordNamespace + `><w:p><w:r><w:t>Internal only</w:t></w:r></w:p></w:ftr>`)},
+	)
+}
+
+func testXlsx(t *testing.T) []byte {
+	return zipArchive(t,
+		archiveFile{"xl/workbook.xml", []byte(`<workbook/>`)},
+		archiveFile{"xl/sharedStrings.xml", []byte(`<sst><si><t>name</t></si><si><t>card</t></si><si><r><t>Ali</t></r><r><t>ce</t></r></si></sst>`)},
+		archiveFile{"xl/worksheets/sheet1.xml", []byte(`<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+			<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2"><v>4111111111111111</v></c><c t="inlineStr"><is><t>inline</t></is></c></row>
+		</sheetData></worksheet>`)},
+	)
+}
+
+func testPptx(t *testing.T) []byte {
+	slide := func(text string) []byte {
+		return []byte(`<p:sld ` + drawNamespace + `><p:cSld><p:spTree><p:sp><p:txBody><a:p><a:r><a:t>` + text + `</a:t></a:r></a:p></p:txBody></p:sp></p:spTree></p:cSld></p:sld>`)
+	}
+	files := []archiveFile{{"ppt/presentation.xml", []byte(`<p:presentation/>`)}}
+	// Out of order, slide10 comes last
+	for _, number := range []int{10, 2, 1} {
+		files = append(files, archiveFile{fmt.Sprintf("ppt/slides/slide%d.xml", number), slide(fmt.Sprintf("Slide %d", number))})
+	}
+	return zipArchive(t, files...)
+}
+
+func testOdt(t *testing.T) []byte {
+	return zipArchive(t,
+		archiveFile{"mimetype", []byte("application/vnd.oasis.opendocument.text")},
+		archiveFile{"content.xml", []byte(`<office:document-content ` + odfNamespace + `><office:body><office:text>
+			<text:h>Notes</text:h>
+			<text:p>API<text:s/>key:<text:tab/>abc123<text:line-break/>next line</text:p>
+			<table:table><table:table-row><table:table-cell><text:p>a</text:p></table:table-cell></table:table-row></table:table>
+		</office:text></office:body></office:document-content>`)},
+	)
+}
+
+// testPDF A PDF with a compressed and an uncompressed page
+func testPDF(t *testing.T) []byte {
+	compressed := &bytes.Buffer{}
+	writer := zlib.NewWriter(compressed)
+	writer.Write([]byte("BT /F1 12 Tf 72 720 Td (Account: 1234) Tj 0 -14 Td [(pass)-10(word)-300(=)] TJ T* <FEFF0073006500630072006500740021> Tj ET"))
+	writer.Close()
+
+	pdf := &bytes.Buffer{}
+	pdf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	pdf.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	fmt.Fprintf(pdf, "4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	pdf.Write(compressed.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n")
+	content := "BT /F1 12 Tf 72 700 Td (Escaped \\(parens\\) and \\101\\102) Tj (second) ' ET"
+	fmt.Fprintf(pdf, "5 0 obj\n<< /Length %d >>\nstream\r\n%s\r\nendstream\nendobj\n", len(content), content)
+	pdf.WriteString("6 0 obj\n<< /Type /XObject /Subtype /Image /Length 4 >>\nstream\nBTET\nendstream\nendobj\n")
+	pdf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestDocumentText(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		text   string
+	}{
+		{"docx", testDocx(t), "docx", "Confidential report\nuser:\tadmin\npassword\thunter2\n\nInternal only\n"},
+		{"xlsx", testXlsx(t), "xlsx", "name\tcard\nAlice\t4111111111111111\tinline\n\n"},
+		{"pptx", testPptx(t), "pptx", "Slide 1\nSlide 2\nSlide 10\n"},
+		{"odt", testOdt(t), "odt", "Notes\nAPI key:\tabc123\nnext line\na\n"},
+		{"pdf", testPDF(t), "pdf", "Account: 1234\npassword =\nsecret!\nEscaped (parens) and AB\nsecond\n"},
+	}
+
+	for _, test := range tests {
+		if format := DocumentFormat(test.data); format != test.format {
+			t.Errorf("Wrong format for %s: %s", test.name, format)
+		}
+		format, text, err := DocumentText(test.data)
+		if err != nil || format != test.format || string(text) != test.text {
+			t.Errorf("Wrong text for %s: %q %v", test.name, text, err)
+		}
+	}
+
+	for _, data := range [][]byte{[]byte("plain"), zipArchive(t, archiveFile{"a.txt", []byte("a")})} {
+		if _, _, err := DocumentText(data); err != ErrNotDocument || DocumentFormat(data) != "" {
+			t.Errorf("Should not be a document: %q", data)
+		}
+	}
+}
+
+func TestDocumentTextLimits(t *testing.T) {
+	// Compresses a thousand times over
+	bomb := zipArchive(t, archiveFile{"word/document.xml", []byte(`<w:document ` + wordNamespace + `><w:body><w:p><w:r><w:t>` +
+		strings.Repeat("a", 1<<20) + `</w:t></w:r></w:p></w:body></w:document>`)})
+	if _, _, err := DocumentText(bomb); err != ErrArchiveTooLarge {
+		t.Errorf("Read text past the ratio: %v", err)
+	}
+	if _, text, err := DocumentTextWithOptions(bomb, ArchiveOptions{MaxRatio: 10000}); err != nil || len(text) != 1<<20+1 {
+		t.Errorf("Wrong text %d %v", len(text), err)
+	}
+	if _, _, err := DocumentTextWithOptions(bomb, ArchiveOptions{MaxRatio: 10000, MaxSize: 1024}); err != ErrArchiveTooLarge {
+		t.Errorf("Read text past the size: %v", err)
+	}
+
+	// Documents in archives share the budget of the archive
+	docx := testDocx(t)
+	backup := zipArchive(t, archiveFile{"report.docx", docx})
+	items := expandedItems(&Item{Name: "backup.zip", Data: backup}, expandOptions{archives: &ArchiveOptions{MaxSize: int64(len(docx)) + 10}, documents: true})
+	if len(items) != 1 || items[0].Name != "backup.zip!/report.docx" {
+		t.Errorf("Read text past the budget of the archive: %v", items)
+	}
+}
+
+// expandedItems Get every item the item expands to
+func expandedItems(item *Item, options expandOptions) []*Item {
+	items := []*Item{}
+	expandItem(context.Background(), item, options, func(item *Item) bool {
+		items = append(items, item)
+		return true
+	})
+	return items
+}
+
+func TestExpandItem(t *testing.T) {
+	docx := testDocx(t)
+	backup := zipArchive(t, archiveFile{"docs/report.docx", docx}, archiveFile{"notes.txt", []byte("notes")})
+
+	tests := []struct {
+		archives *ArchiveOptions
+		items    []string
+	}{
+		{nil, []string{"backup.zip"}},
+		{&ArchiveOptions{}, []string{"backup.zip!/docs/report.docx", "backup.zip!/docs/report.docx#text", "backup.zip!/notes.txt"}},
+	}
+	for i, test := range tests {
+		names := []string{}
+		for _, item := range expandedItems(&Item{Name: "backup.zip", Data: backup}, expandOptions{archives: test.archives, documents: true}) {
+			names = append(names, item.Name)
+		}
+		if !reflect.DeepEqual(names, test.items) {
+			t.Errorf("Test %d got %v", i, names)
+		}
+	}
+
+	items := expandedItems(&Item{Name: "report.docx", Data: docx}, expandOptions{archives: &ArchiveOptions{}, documents: true})
+	if len(items) != 2 || items[1].Name != "report.docx#text" || items[1].Metadata["parent"] != "report.docx" || items[1].Metadata["format"] != "docx" {
+		t.Errorf("Wrong document items %v", items)
+	}
+}
+
+func TestFTPDocuments(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{"/report.pdf": testPDF(t)}}
+	client := connectFakeFTP(t, server, FTPOptions{Documents: true})
+	defer server.Close()
+	defer client.Close()
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil || !bytes.HasSuffix(data, []byte("secret!\nEscaped (parens) and AB\nsecond\n")) {
+		t.Errorf("Wrong data %q %v", data, err)
+	}
+}
+
+func TestHTTPDocuments(t *testing.T) {
+	xlsx := testXlsx(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(xlsx)
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPWithOptions(server.URL+"/cards.xlsx", HTTPOptions{Documents: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	items, err := client.Items(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := map[string]string{}
+	for item := range items {
+		texts[item.Name] = string(item.Data)
+	}
+	if texts[server.URL+"/cards.xlsx#text"] != "name\tcard\nAlice\t4111111111111111\tinline\n\n" {
+		t.Errorf("Wrong items %v", texts)
+	}
+}
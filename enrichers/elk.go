@@ -6,8 +6,10 @@ import (
 	"errors"
 	"io"
 	"net"
+	"net/http"
 	"net/url"
 	"regexp"
+	"strings"
 
 	"github.com/vertoforce/multiregex"
 
@@ -17,6 +19,8 @@ import (
 // ELKClient ELK Connection
 type ELKClient struct {
 	url          *url.URL
+	options      ELKOptions
+	httpClient   *http.Client
 	client       *elastic.Client
 	reader       io.ReadCloser
 	readerCtx    context.Context
@@ -38,9 +42,19 @@ type ELKIndex struct {
 	StoreSize          uint64 // Store size in bytes
 }
 
+// ELKOptions Options for the ELK client
+type ELKOptions struct {
+	HTTPConfig // Auth, headers, TLS, proxy, etc.  User/pass in the URL are used for basic auth
+}
+
 // NewELK Connect to ELK server
 func NewELK(urlString string) (*ELKClient, error) {
-	client := ELKClient{}
+	return NewELKWithOptions(urlString, ELKOptions{})
+}
+
+// NewELKWithOptions Connect to ELK server with options
+func NewELKWithOptions(urlString string, options ELKOptions) (*ELKClient, error) {
+	client := ELKClient{options: options}
 	// Set URL
 	url, err := url.Parse(urlString)
 	if err != nil {
@@ -48,14 +62,28 @@ func NewELK(urlString string) (*ELKClient, error) {
 	}
 	client.url = url
 
-	// TODO: Check for user/pass
+	client.httpClient, err = newHTTPClient(options.HTTPConfig, client.url, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	return &client, nil
 }
 
-// Connect to ELK server
+// Connect to ELK server.  The other nodes of the cluster are sniffed first so they get the credentials too
 func (client *ELKClient) Connect(ctx context.Context) error {
-	c, err := elastic.DialContext(ctx, elastic.SetURL(client.url.String()))
+	if nodes := client.sniffNodes(ctx); len(nodes) > 0 {
+		httpClient, err := newHTTPClient(client.options.HTTPConfig, client.url, nodes)
+		if err != nil {
+			return err
+		}
+		client.httpClient = httpClient
+	}
+
+	// Credentials are sent by our http client
+	connectURL := *client.url
+	connectURL.User = nil
+	c, err := elastic.DialContext(ctx, elastic.SetURL(connectURL.String()), elastic.SetHttpClient(client.httpClient))
 	if err != nil {
 		return err
 	}
@@ -65,6 +93,56 @@ func (client *ELKClient) Connect(ctx context.Context) error {
 	return nil
 }
 
+// sniffNodes Get the host:port of the HTTP address of every node of the cluster the same way elastic sniffs them
+func (client *ELKClient) sniffNodes(ctx context.Context) []string {
+	nodesURL := *client.url
+	nodesURL.User = nil
+	nodesURL.Path = strings.TrimSuffix(nodesURL.Path, "/") + "/_nodes/http"
+	req, err := http.NewRequest("GET", nodesURL.String(), nil)
+	if err != nil {
+		return nil
+	}
+	response, err := client.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil
+	}
+	defer response.Body.Close()
+
+	var info elastic.NodesInfoResponse
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return nil
+	}
+	hosts := []string{}
+	for _, node := range info.Nodes {
+		if node.HTTP == nil {
+			continue
+		}
+		if host := elkNodeHost(node.HTTP.PublishAddress); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// elkPublishAddressRegex Old style publish address like inet[/127.0.0.1:9200]
+var elkPublishAddressRegex = regexp.MustCompile(`\/([^:]*):([0-9]+)\]`)
+
+// elkNodeHost Get the host:port of a publish address like 127.0.0.1:9200, es1/127.0.0.1:9200, or inet[/127.0.0.1:9200]
+func elkNodeHost(address string) string {
+	if strings.HasPrefix(address, "inet") {
+		if match := elkPublishAddressRegex.FindStringSubmatch(address); len(match) == 3 {
+			return match[1] + ":" + match[2]
+		}
+	}
+	if slash := strings.Index(address, "/"); slash != -1 {
+		address = address[slash+1:]
+	}
+	if !strings.Contains(address, ":") {
+		return ""
+	}
+	return address
+}
+
 // GetIP Get IP of server
 func (client *ELKClient) GetIP() net.IP {
 	return urlToIP(client.url)
@@ -144,6 +222,19 @@ func (client *ELKClient) ResetReader() error {
 
 // -- ELK specific functions --
 
+// GetTLSInfo Get details of the server's TLS connection and certificates.  ErrNoTLS if the URL is not https
+func (client *ELKClient) GetTLSInfo(ctx context.Context) (*TLSInfo, error) {
+	if client.url.Scheme != "https" {
+		return nil, ErrNoTLS
+	}
+
+	port := client.url.Port()
+	if port == "" {
+		port = "443"
+	}
+	return dialInspectTLS(ctx, net.JoinHostPort(client.url.Hostname(), port), client.url.Hostname(), nil)
+}
+
 // getAllData Get all entries in all indices
 func (client *ELKClient) getAllData(ctx context.Context) io.ReadCloser {
 	allDataReader, allDataWriter := io.Pipe()
@@ -252,6 +343,22 @@ func (client *ELKClient) GetTotalSize(ctx context.Context) (uint64, error) {
 	return totalSize, nil
 }
 
+// Size Get the size of every index on disk along with the number of documents
+func (client *ELKClient) Size(ctx context.Context) (*ServerSize, error) {
+	indices, err := client.GetIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	size := &ServerSize{Complete: true}
+	for _, index := range indices {
+		size.Bytes += index.StoreSize
+		size.Items += int64(index.DocsCount)
+	}
+
+	return size, nil
+}
+
 // GetJSONData Given index name, return channel of jsons limited to `limit` hits. -1 for unlimited
 func (client *ELKClient) GetJSONData(ctx context.Context, indexName string, limit int64) chan *json.RawMessage {
 	ret := make(chan *json.RawMessage)
@@ -19,6 +19,8 @@ const (
 	SSH
 	SQL
 	HTTP
+	MSSQL
+	SQLite
 )
 
 type serverRegex struct {
@@ -28,6 +30,8 @@ type serverRegex struct {
 
 // serverTypeRegexes array of regexes to determine server type.  Array because order matters to check regexes
 var serverTypeRegexes = []serverRegex{
+	{MSSQL, regexp.MustCompile("^sqlserver://")},
+	{SQLite, regexp.MustCompile("^sqlite:")},
 	{ELK, regexp.MustCompile("https?://.*9200")},
 	{FTP, regexp.MustCompile("ftp")},
 	{SQL, regexp.MustCompile(`(\w*):?(\w*)@(tcp|udp)`)}, // TODO: Finish regex
@@ -61,6 +65,8 @@ func GetConnectionString(ip net.IP, port int, serverType ServerType) string {
 		return fmt.Sprintf("ftp://%s:%d", ip.String(), port)
 	case HTTP:
 		return fmt.Sprintf("http://%s:%d", ip.String(), port)
+	case MSSQL:
+		return fmt.Sprintf("sqlserver://%s:%d", ip.String(), port)
 	case SQL:
 		// TODO:
 		return fmt.Sprintf("%s:%d", ip.String(), port)
@@ -0,0 +1,110 @@
+package enrichers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// BinaryExtensions Extensions of binary and media files skipped when FileFilter.SkipBinary is set
+var BinaryExtensions = []string{
+	// Disk images and installers
+	".iso", ".img", ".dmg", ".vmdk", ".vdi", ".vhd", ".vhdx", ".qcow2", ".ova", ".msi", ".deb", ".rpm", ".apk",
+	// Executables and libraries
+	".exe", ".dll", ".so", ".dylib", ".bin", ".o", ".a", ".class", ".pyc",
+	// Images
+	".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tif", ".tiff", ".ico", ".webp", ".psd", ".heic",
+	// Audio and video
+	".mp3", ".wav", ".flac", ".aac", ".ogg", ".m4a", ".mp4", ".m4v", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm",
+	// Fonts
+	".ttf", ".otf", ".woff", ".woff2",
+}
+
+// FileFilter Which files to read from servers that hold files, such as FTP servers and HTTP directory listings.
+// It is checked against listings before downloading anything.  The zero value accepts every file
+type FileFilter struct {
+	// Glob patterns as in path.Match.  Patterns with a / are matched against the full path, others against the name.
+	// The full path is the path in the file's URL: from the login directory on FTP, and from the server root on HTTP
+	// even when listing a subdirectory, so /pub/logs/* matches http://host/pub/logs/app.log when reading
+	// http://host/pub/.  Paths and patterns are rooted, so logs/* and /logs/* both match logs/app.log on FTP
+	Include []string // Only files matching one of these.  Empty for all files
+	Exclude []string // Skip files matching any of these
+
+	Extensions        []string // Only files with one of these extensions such as ".sql".  Empty for all files
+	ExcludeExtensions []string // Skip files with these extensions
+	SkipBinary        bool     // Skip binary and media files with BinaryExtensions
+
+	// Limits.  Files with unknown size or modification time are not skipped by these
+	MinSize        int64     // Skip files smaller than this
+	MaxSize        int64     // Skip files larger than this.  0 for unlimited
+	ModifiedAfter  time.Time // Skip files modified before this.  Zero for no limit
+	ModifiedBefore time.Time // Skip files modified after this.  Zero for no limit
+}
+
+// validate Check the glob patterns
+func (filter FileFilter) validate() error {
+	for _, pattern := range append(append([]string{}, filter.Include...), filter.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match Check if the filter accepts a file.  size is -1 and modTime zero when unknown
+func (filter FileFilter) Match(filePath string, size int64, modTime time.Time) bool {
+	// Size and time
+	if size >= 0 && (size < filter.MinSize || (filter.MaxSize > 0 && size > filter.MaxSize)) {
+		return false
+	}
+	if !modTime.IsZero() {
+		if !filter.ModifiedAfter.IsZero() && modTime.Before(filter.ModifiedAfter) {
+			return false
+		}
+		if !filter.ModifiedBefore.IsZero() && modTime.After(filter.ModifiedBefore) {
+			return false
+		}
+	}
+
+	// Extensions
+	extension := strings.ToLower(path.Ext(filePath))
+	if len(filter.Extensions) > 0 && !hasExtension(filter.Extensions, extension) {
+		return false
+	}
+	if hasExtension(filter.ExcludeExtensions, extension) || (filter.SkipBinary && hasExtension(BinaryExtensions, extension)) {
+		return false
+	}
+
+	// Globs
+	if len(filter.Include) > 0 && !matchesGlob(filter.Include, filePath) {
+		return false
+	}
+	return !matchesGlob(filter.Exclude, filePath)
+}
+
+// hasExtension Check if the extension is in the list, ignoring case
+func hasExtension(extensions []string, extension string) bool {
+	for _, e := range extensions {
+		if strings.ToLower(e) == extension {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesGlob Check if any pattern matches the path, or its name for patterns without a /.  FTP walks give paths
+// relative to where they start and listings give URL paths, so both are rooted before matching
+func matchesGlob(patterns []string, filePath string) bool {
+	name := path.Base(filePath)
+	for _, pattern := range patterns {
+		target := name
+		if strings.Contains(pattern, "/") {
+			pattern, target = path.Join("/", pattern), path.Join("/", filePath)
+		}
+		if matched, _ := path.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
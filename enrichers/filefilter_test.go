@@ -0,0 +1,83 @@
+package enrichers
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestFileFilter(t *testing.T) {
+	day := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		filter  FileFilter
+		path    string
+		size    int64
+		modTime time.Time
+		match   bool
+	}{
+		{FileFilter{}, "/any/file.bin", 1 << 40, day, true},
+		// Globs on the name and the full path
+		{FileFilter{Include: []string{"*.xlsx"}}, "/docs/passwords.xlsx", 10, day, true},
+		{FileFilter{Include: []string{"*.xlsx"}}, "/docs/notes.txt", 10, day, false},
+		{FileFilter{Include: []string{"/docs/*"}}, "/docs/notes.txt", 10, day, true},
+		{FileFilter{Include: []string{"/docs/*"}}, "/other/notes.txt", 10, day, false},
+		{FileFilter{Include: []string{"/docs/*"}}, "docs/notes.txt", 10, day, true},
+		{FileFilter{Include: []string{"docs/*"}}, "/docs/notes.txt", 10, day, true},
+		{FileFilter{Exclude: []string{"backup-*"}}, "/backup-2020.tar", 10, day, false},
+		{FileFilter{Include: []string{"*.txt"}, Exclude: []string{"readme*"}}, "/readme.txt", 10, day, false},
+		// Extensions
+		{FileFilter{Extensions: []string{".SQL"}}, "/dump.sql", 10, day, true},
+		{FileFilter{Extensions: []string{".sql"}}, "/dump.sql.gz", 10, day, false},
+		{FileFilter{ExcludeExtensions: []string{".log"}}, "/app.LOG", 10, day, false},
+		{FileFilter{SkipBinary: true}, "/ubuntu.iso", 10, day, false},
+		{FileFilter{SkipBinary: true}, "/photo.JPG", 10, day, false},
+		{FileFilter{SkipBinary: true}, "/passwords.xlsx", 10, day, true},
+		// Size
+		{FileFilter{MaxSize: 100}, "/big", 101, day, false},
+		{FileFilter{MaxSize: 100}, "/small", 100, day, true},
+		{FileFilter{MaxSize: 100}, "/unknown", -1, day, true},
+		{FileFilter{MinSize: 1}, "/empty", 0, day, false},
+		// Time
+		{FileFilter{ModifiedAfter: day.Add(-time.Hour)}, "/new", 10, day, true},
+		{FileFilter{ModifiedAfter: day.Add(time.Hour)}, "/old", 10, day, false},
+		{FileFilter{ModifiedBefore: day.Add(-time.Hour)}, "/new", 10, day, false},
+		{FileFilter{ModifiedBefore: day.Add(time.Hour)}, "/unknown", 10, time.Time{}, true},
+	}
+
+	for i, test := range tests {
+		if match := test.filter.Match(test.path, test.size, test.modTime); match != test.match {
+			t.Errorf("Test %d failed for %s, wanted %v", i, test.path, test.match)
+		}
+	}
+
+	if err := (FileFilter{Include: []string{"["}}).validate(); err == nil {
+		t.Errorf("Should not accept bad pattern")
+	}
+	if _, err := NewFTPWithOptions("ftp://localhost", FTPOptions{Filter: FileFilter{Exclude: []string{"["}}}); err == nil {
+		t.Errorf("FTP client should not accept bad pattern")
+	}
+}
+
+func TestFTPFileFilter(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{
+		"/passwords.xlsx": []byte("secret"),
+		"/ubuntu.iso":     make([]byte, 1000),
+		"/logs/app.log":   []byte("log"),
+	}}
+	client := connectFakeFTP(t, server, FTPOptions{Filter: FileFilter{SkipBinary: true, Exclude: []string{"/logs/*"}, MaxSize: 100}})
+	defer server.Close()
+	defer client.Close()
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil || string(data) != "secret" {
+		t.Errorf("Wrong data %q %v", data, err)
+	}
+
+	// Filtered files are never downloaded
+	for _, command := range server.received() {
+		if command == "RETR ubuntu.iso" || command == "RETR logs/app.log" {
+			t.Errorf("Downloaded filtered file: %s", command)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package enrichers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+//go:generate go run ./internal/genembed enrichers fingerprints.json fingerprintsJSON fingerprints_json.go
+
+const maxFaviconSize = 1024 * 1024 // Max size of a favicon to download
+
+// SecurityHeaders Response headers that harden a site, reported as present or missing
+var SecurityHeaders = []string{
+	"Strict-Transport-Security",
+	"Content-Security-Policy",
+	"X-Frame-Options",
+	"X-Content-Type-Options",
+	"Referrer-Policy",
+	"Permissions-Policy",
+	"Cross-Origin-Opener-Policy",
+}
+
+// HTTPEnrichment What we learned about a web server from its page
+type HTTPEnrichment struct {
+	URL          string       `json:"url"`
+	StatusCode   int          `json:"status_code"`
+	Title        string       `json:"title"`
+	Server       string       `json:"server"`     // Server header
+	PoweredBy    string       `json:"powered_by"` // X-Powered-By header
+	Technologies []Technology `json:"technologies"`
+
+	// Favicon.  The hash is the mmh3 hash Shodan uses for http.favicon.hash
+	FaviconURL  string `json:"favicon_url,omitempty"`
+	FaviconHash int32  `json:"favicon_hash,omitempty"`
+
+	SecurityHeaders        map[string]string `json:"security_headers"`         // Security headers present and their values
+	MissingSecurityHeaders []string          `json:"missing_security_headers"` // Security headers not sent
+
+	TLS *TLSInfo `json:"tls,omitempty"` // Certificates and connection details if the page came over TLS
+}
+
+// Technology Software detected on a web server
+type Technology struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Version  string `json:"version,omitempty"` // Empty if unknown
+}
+
+// fingerprint Signatures of a technology.  Regexes are case insensitive and the first non empty
+// capture group is the version.  An empty regex only checks that the header, cookie, or meta tag exists
+type fingerprint struct {
+	Name     string            `json:"name"`
+	Category string            `json:"category"`
+	Headers  map[string]string `json:"headers"`
+	Cookies  map[string]string `json:"cookies"`
+	Meta     map[string]string `json:"meta"`
+	HTML     []string          `json:"html"`
+	Scripts  []string          `json:"scripts"` // Matched against the src of each script
+	Favicon  []int32           `json:"favicon"`
+
+	headers map[string]*regexp.Regexp
+	cookies map[string]*regexp.Regexp
+	meta    map[string]*regexp.Regexp
+	html    []*regexp.Regexp
+	scripts []*regexp.Regexp
+}
+
+var (
+	fingerprints     []*fingerprint
+	fingerprintsLock sync.RWMutex
+)
+
+func init() {
+	if err := LoadFingerprints(bytes.NewReader(fingerprintsJSON)); err != nil {
+		panic(err)
+	}
+}
+
+// LoadFingerprints Replace the embedded fingerprints with ones from a signature file in the same format as
+// fingerprints.json
+func LoadFingerprints(r io.Reader) error {
+	file := struct {
+		Technologies []*fingerprint `json:"technologies"`
+	}{}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return err
+	}
+
+	for _, f := range file.Technologies {
+		if err := f.compile(); err != nil {
+			return fmt.Errorf("fingerprint %s: %v", f.Name, err)
+		}
+	}
+
+	fingerprintsLock.Lock()
+	fingerprints = file.Technologies
+	fingerprintsLock.Unlock()
+	return nil
+}
+
+// compile Compile the regexes of the fingerprint
+func (f *fingerprint) compile() error {
+	var err error
+	compileMap := func(patterns map[string]string) (map[string]*regexp.Regexp, error) {
+		compiled := map[string]*regexp.Regexp{}
+		for name, pattern := range patterns {
+			if compiled[name], err = regexp.Compile("(?i)" + pattern); err != nil {
+				return nil, err
+			}
+		}
+		return compiled, nil
+	}
+	compileList := func(patterns []string) ([]*regexp.Regexp, error) {
+		compiled := []*regexp.Regexp{}
+		for _, pattern := range patterns {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, err
+			}
+			compiled = append(compiled, re)
+		}
+		return compiled, nil
+	}
+
+	if f.headers, err = compileMap(f.Headers); err != nil {
+		return err
+	}
+	if f.cookies, err = compileMap(f.Cookies); err != nil {
+		return err
+	}
+	if f.meta, err = compileMap(f.Meta); err != nil {
+		return err
+	}
+	if f.html, err = compileList(f.HTML); err != nil {
+		return err
+	}
+	f.scripts, err = compileList(f.Scripts)
+	return err
+}
+
+// Enrich Fingerprint the server from the page at the URL and its favicon
+func (client *HTTPClient) Enrich(ctx context.Context) (*HTTPEnrichment, error) {
+	page, err := client.getPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := page.response
+	enrichment := &HTTPEnrichment{
+		URL:                    page.url.String(),
+		StatusCode:             response.StatusCode,
+		Server:                 response.Header.Get("Server"),
+		PoweredBy:              response.Header.Get("X-Powered-By"),
+		SecurityHeaders:        map[string]string{},
+		MissingSecurityHeaders: []string{},
+	}
+	if response.TLS != nil {
+		enrichment.TLS = newTLSInfo(response.TLS)
+	}
+	for _, header := range SecurityHeaders {
+		if value := response.Header.Get(header); value != "" {
+			enrichment.SecurityHeaders[header] = value
+		} else {
+			enrichment.MissingSecurityHeaders = append(enrichment.MissingSecurityHeaders, header)
+		}
+	}
+
+	var document *html.Node
+	if page.isHTML() {
+		document, _ = html.Parse(bytes.NewReader(page.body))
+	}
+	if document != nil {
+		if titles := findElements(document, atom.Title); len(titles) > 0 {
+			enrichment.Title = strings.TrimSpace(string(htmlText(titles[0])))
+		}
+	}
+
+	if faviconURL := page.faviconURL(document); faviconURL != nil {
+		favicon, err := client.fetch(ctx, faviconURL, maxFaviconSize)
+		if err == nil && favicon.response.StatusCode == 200 && len(favicon.body) > 0 {
+			enrichment.FaviconURL = faviconURL.String()
+			enrichment.FaviconHash = FaviconHash(favicon.body)
+		}
+	}
+
+	enrichment.Technologies = page.technologies(document, enrichment.FaviconURL != "", enrichment.FaviconHash)
+
+	return enrichment, nil
+}
+
+// fingerprintItem Get the enrichment as an item named like url#fingerprint
+func (client *HTTPClient) fingerprintItem(ctx context.Context) (*Item, error) {
+	enrichment, err := client.Enrich(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(enrichment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Item{
+		Name:     client.url.String() + "#fingerprint",
+		Data:     data,
+		Metadata: map[string]string{"parent": client.url.String()},
+	}, nil
+}
+
+// faviconURL Get URL of the icon linked by the document, or /favicon.ico
+func (page *httpPage) faviconURL(document *html.Node) *url.URL {
+	if document != nil {
+		for _, link := range findElements(document, atom.Link) {
+			href := strings.TrimSpace(htmlAttr(link, "href"))
+			if href == "" || strings.HasPrefix(href, "data:") {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.ToLower(htmlAttr(link, "rel"))) {
+				if rel == "icon" {
+					if u, err := page.finalURL().Parse(href); err == nil {
+						return u
+					}
+				}
+			}
+		}
+	}
+
+	u, _ := page.finalURL().Parse("/favicon.ico")
+	return u
+}
+
+// technologies Match the page against every fingerprint
+func (page *httpPage) technologies(document *html.Node, hasFavicon bool, faviconHash int32) []Technology {
+	cookies := map[string]string{}
+	for _, cookie := range page.response.Cookies() {
+		cookies[strings.ToLower(cookie.Name)] = cookie.Value
+	}
+	meta := map[string]string{}
+	scripts := []string{}
+	if document != nil {
+		for _, element := range findElements(document, atom.Meta) {
+			if name := htmlAttr(element, "name"); name != "" {
+				meta[strings.ToLower(name)] = htmlAttr(element, "content")
+			}
+		}
+		for _, script := range findElements(document, atom.Script) {
+			if src := htmlAttr(script, "src"); src != "" {
+				scripts = append(scripts, src)
+			}
+		}
+	}
+
+	fingerprintsLock.RLock()
+	defer fingerprintsLock.RUnlock()
+
+	technologies := []Technology{}
+	for _, f := range fingerprints {
+		matched, version := false, ""
+		match := func(re *regexp.Regexp, value string) {
+			submatches := re.FindStringSubmatch(value)
+			if submatches == nil {
+				return
+			}
+			matched = true
+			for _, submatch := range submatches[1:] {
+				if submatch != "" && version == "" {
+					version = submatch
+				}
+			}
+		}
+
+		for name, re := range f.headers {
+			for _, value := range page.response.Header[http.CanonicalHeaderKey(name)] {
+				match(re, value)
+			}
+		}
+		for name, re := range f.cookies {
+			if value, ok := cookies[strings.ToLower(name)]; ok {
+				match(re, value)
+			}
+		}
+		for name, re := range f.meta {
+			if value, ok := meta[strings.ToLower(name)]; ok {
+				match(re, value)
+			}
+		}
+		for _, re := range f.html {
+			match(re, string(page.body))
+		}
+		for _, re := range f.scripts {
+			for _, src := range scripts {
+				match(re, src)
+			}
+		}
+		for _, hash := range f.Favicon {
+			if hasFavicon && hash == faviconHash {
+				matched = true
+			}
+		}
+
+		if matched {
+			technologies = append(technologies, Technology{Name: f.Name, Category: f.Category, Version: version})
+		}
+	}
+
+	sort.Slice(technologies, func(i, j int) bool { return technologies[i].Name < technologies[j].Name })
+	return technologies
+}
+
+// FaviconHash Hash of a favicon like Shodan's http.favicon.hash: mmh3 of the base64 of the icon
+// with a newline after every 76 characters and at the end
+func FaviconHash(data []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	wrapped := &bytes.Buffer{}
+	for len(encoded) > 76 {
+		wrapped.WriteString(encoded[0:76])
+		wrapped.WriteString("\n")
+		encoded = encoded[76:]
+	}
+	wrapped.WriteString(encoded)
+	wrapped.WriteString("\n")
+
+	return int32(murmur3(wrapped.Bytes(), 0))
+}
+
+// murmur3 32 bit MurmurHash3
+func murmur3(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	h := seed
+	length := len(data)
+
+	for ; len(data) >= 4; data = data[4:] {
+		k := binary.LittleEndian.Uint32(data)
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	switch len(data) {
+	case 3:
+		k ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(length)
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
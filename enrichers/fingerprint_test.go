@@ -0,0 +1,145 @@
+package enrichers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMurmur3(t *testing.T) {
+	tests := []struct {
+		data string
+		hash uint32
+	}{
+		{"", 0},
+		{"hello", 0x248bfa47},
+		{"The quick brown fox jumps over the lazy dog", 0x2e4ff723},
+	}
+
+	for _, test := range tests {
+		if hash := murmur3([]byte(test.data), 0); hash != test.hash {
+			t.Errorf("Wrong hash of %q, wanted %x got %x", test.data, test.hash, hash)
+		}
+	}
+}
+
+func TestFaviconHash(t *testing.T) {
+	// Base64 is wrapped every 76 characters with a trailing newline before hashing
+	data := bytes.Repeat([]byte{0xff}, 100)
+	encoded := "////////////////////////////////////////////////////////////////////////////\n" +
+		"/////////////////////////////////////////////////////////w==\n"
+	if hash := FaviconHash(data); hash != int32(murmur3([]byte(encoded), 0)) {
+		t.Errorf("Favicon hash not computed over wrapped base64")
+	}
+}
+
+func TestEmbeddedFingerprints(t *testing.T) {
+	data, err := ioutil.ReadFile("fingerprints.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, fingerprintsJSON) {
+		t.Errorf("fingerprints_json.go is out of date, run go generate")
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	icon := []byte("icon data")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Server", "nginx/1.18.0")
+			w.Header().Set("X-Powered-By", "PHP/7.4.3")
+			w.Header().Set("X-Frame-Options", "DENY")
+			http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "abc"})
+			fmt.Fprint(w, `<html><head><title> My
+				Blog </title><meta name="generator" content="WordPress 5.4.2">
+				<link rel="shortcut icon" href="/static/icon.png">
+				<script src="/js/jquery-3.5.1.min.js"></script></head>
+				<body><img src="/wp-content/uploads/a.png"></body></html>`)
+		case "/static/icon.png":
+			w.Write(icon)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPWithOptions(server.URL+"/", HTTPOptions{Fingerprint: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrichment, err := client.Enrich(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if enrichment.Title != "My Blog" || enrichment.Server != "nginx/1.18.0" || enrichment.PoweredBy != "PHP/7.4.3" {
+		t.Errorf("Wrong title or headers %+v", enrichment)
+	}
+	if enrichment.FaviconURL != server.URL+"/static/icon.png" || enrichment.FaviconHash != FaviconHash(icon) {
+		t.Errorf("Wrong favicon %s %d", enrichment.FaviconURL, enrichment.FaviconHash)
+	}
+	if enrichment.SecurityHeaders["X-Frame-Options"] != "DENY" || len(enrichment.MissingSecurityHeaders) != len(SecurityHeaders)-1 {
+		t.Errorf("Wrong security headers %v %v", enrichment.SecurityHeaders, enrichment.MissingSecurityHeaders)
+	}
+
+	wanted := map[string]string{"nginx": "1.18.0", "PHP": "7.4.3", "WordPress": "5.4.2", "jQuery": "3.5.1"}
+	found := map[string]string{}
+	for _, technology := range enrichment.Technologies {
+		found[technology.Name] = technology.Version
+	}
+	for name, version := range wanted {
+		if v, ok := found[name]; !ok || v != version {
+			t.Errorf("Did not detect %s %s, found %v", name, version, found)
+		}
+	}
+
+	// Fingerprint is read as an item
+	items, err := client.Items(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fingerprint *Item
+	for item := range items {
+		if strings.HasSuffix(item.Name, "#fingerprint") {
+			fingerprint = item
+		}
+	}
+	decoded := &HTTPEnrichment{}
+	if fingerprint == nil || json.Unmarshal(fingerprint.Data, decoded) != nil || decoded.Title != "My Blog" {
+		t.Errorf("Did not read fingerprint item")
+	}
+}
+
+func TestLoadFingerprints(t *testing.T) {
+	defer LoadFingerprints(bytes.NewReader(fingerprintsJSON))
+
+	if err := LoadFingerprints(strings.NewReader(`{"technologies": [{"name": "Bad", "html": ["("]}]}`)); err == nil {
+		t.Errorf("Should not load invalid regex")
+	}
+	if err := LoadFingerprints(strings.NewReader(`{"technologies": [{"name": "Custom", "category": "Test", "headers": {"x-custom": "v([\\d]+)"}}]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Custom", "v2")
+		w.Header().Set("Server", "nginx")
+	}))
+	defer server.Close()
+
+	client, _ := NewHTTP(server.URL)
+	enrichment, err := client.Enrich(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enrichment.Technologies) != 1 || enrichment.Technologies[0] != (Technology{"Custom", "Test", "2"}) {
+		t.Errorf("Did not use loaded fingerprints, got %v", enrichment.Technologies)
+	}
+}
@@ -1,14 +1,22 @@
 package enrichers
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"io"
 	"io/ioutil"
 	"net"
+	"net/textproto"
 	"net/url"
 	"path"
 	"regexp"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/vertoforce/multiregex"
@@ -25,15 +33,56 @@ type FTPClient struct {
 	username     string
 	password     string
 	url          *url.URL
+	options      FTPOptions
 	client       *ftp.ServerConn
+	control      net.Conn // Control connection under client
+	tlsInfo      *TLSInfo
 	reader       io.ReadCloser
 	readerCtx    context.Context
 	readerCancel context.CancelFunc
 }
 
+// FTPOptions Options for the FTP client
+type FTPOptions struct {
+	// ExplicitTLS Upgrade the connection with AUTH TLS before logging in.  ftps:// URLs always use implicit TLS
+	ExplicitTLS bool
+
+	// TLS.  TLSConfig is used as is when set, otherwise one is built from the other fields
+	TLSConfig          *tls.Config
+	InsecureSkipVerify bool
+	RootCAs            *x509.CertPool
+	ClientCertificates []tls.Certificate
+
+	Walk   FTPWalkOptions // How to walk directories when reading or getting all files
+	Filter FileFilter     // Files to read.  Checked against the listing before downloading
+
+	// Concurrency Number of files to transfer at once, each on its own connection.  0 for DefaultFTPConcurrency.
+	// Transfers from one server are also limited by MaxFTPTransfersPerServer
+	Concurrency int
+
+	// ReadLimit Read only the first ReadLimit bytes of each file, aborting the rest of the transfer.  0 for whole files
+	ReadLimit int64
+
+	Archives  *ArchiveOptions // Read the entries of archives and compressed files instead of their bytes.  nil to disable
+	Documents bool            // Also read the text of Office, OpenDocument, and PDF files
+	SQLDumps  bool            // Read .sql, .sql.gz, mysqldump, and pg_dump files as a JSON item per row
+	SQLite    *SQLLimits      // Read SQLite database files as a JSON item per row within these limits.  nil to disable
+
+	SizeWalkTime time.Duration // How long Size walks the server before giving up.  0 for DefaultSizeWalkTime
+}
+
 // NewFTP Connect to FTP server with provided credentials
 func NewFTP(urlString string) (*FTPClient, error) {
-	client := &FTPClient{}
+	return NewFTPWithOptions(urlString, FTPOptions{})
+}
+
+// NewFTPWithOptions Connect to FTP server with provided credentials and options.  ftps:// URLs use implicit TLS
+func NewFTPWithOptions(urlString string, options FTPOptions) (*FTPClient, error) {
+	if err := options.Filter.validate(); err != nil {
+		return nil, err
+	}
+
+	client := &FTPClient{options: options}
 	url, err := url.Parse(urlString)
 	if err != nil {
 		return nil, err
@@ -54,7 +103,22 @@ func NewFTP(urlString string) (*FTPClient, error) {
 
 // Connect to FTP server
 func (client *FTPClient) Connect(ctx context.Context) error {
-	c, err := ftp.Dial(net.JoinHostPort(client.url.Hostname(), client.url.Port()), ftp.DialWithContext(ctx))
+	address := net.JoinHostPort(client.url.Hostname(), client.port())
+	var conn net.Conn
+	var err error
+	dialOptions := []ftp.DialOption{}
+	if client.usesTLS() {
+		config := client.tlsConfig()
+		conn, err = client.dialTLS(ctx, address, config)
+		// Data connections use TLS too
+		dialOptions = append(dialOptions, ftp.DialWithTLS(config))
+	} else {
+		conn, err = (&net.Dialer{Timeout: 30 * time.Second}).DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	c, err := ftp.Dial(address, append(dialOptions, ftp.DialWithNetConn(conn))...)
 	if err != nil {
 		return err
 	}
@@ -65,6 +129,7 @@ func (client *FTPClient) Connect(ctx context.Context) error {
 		return err
 	}
 	client.client = c
+	client.control = conn
 
 	return nil
 }
@@ -76,7 +141,8 @@ func (client *FTPClient) GetIP() net.IP {
 
 // GetPort Get Port of server
 func (client *FTPClient) GetPort() uint16 {
-	return urlToPort(client.url)
+	port, _ := strconv.ParseUint(client.port(), 10, 16)
+	return uint16(port)
 }
 
 // GetConnectString Get connect string
@@ -138,85 +204,197 @@ func (client *FTPClient) ResetReader() error {
 	return nil
 }
 
-// getAllData Reads all files on server.  Open a new connection
+// getAllData Reads all files on server, transferring several at once on new connections.
+// Each file is downloaded before it is written so files are not mixed together
 func (client *FTPClient) getAllData(ctx context.Context) io.ReadCloser {
 	fileDataReader, fileDataWriter := io.Pipe()
 
-	// Make new connection as to not overlap with the master connection
-	ourClient, err := NewFTP(client.url.String())
-	if err != nil {
-		fileDataWriter.Close()
-		return fileDataReader
+	// Only files that may be expanded are kept in memory
+	memorySize := ftpSpoolMemorySize
+	if options := client.expandOptions(); options.archives != nil || options.documents || options.sqlDumps || options.sqlite != nil {
+		memorySize = ftpMaxItemSize
+	}
+
+	go func() {
+		err := client.fileItems(ctx, memorySize, func(item *Item, rest io.Reader) error {
+			if _, err := fileDataWriter.Write(item.Data); err != nil || rest == nil {
+				return err
+			}
+			_, err := io.Copy(fileDataWriter, rest)
+			return err
+		})
+		fileDataWriter.CloseWithError(err)
+	}()
+
+	return fileDataReader
+}
+
+// Items Get every file the filter accepts as an item named by its URL, with its size and modification time in its
+// metadata, expanded as the options say.  Files over 64MB are given with only their first 64MB and truncated in
+// their metadata, and are not expanded
+func (client *FTPClient) Items(ctx context.Context) (chan *Item, error) {
+	if client.client == nil {
+		return nil, errors.New("not connected")
 	}
 
+	items := make(chan *Item)
 	go func() {
-		defer fileDataWriter.Close()
-		defer ourClient.Close()
+		defer close(items)
+		client.fileItems(ctx, ftpMaxItemSize, func(item *Item, rest io.Reader) error {
+			if rest != nil {
+				sniffItem(item)
+				item.Metadata["truncated"] = "true"
+			}
+			select {
+			case items <- item:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
 
-		files, err := ourClient.GetAllFilesInFolder(ctx, ".")
+	return items, nil
+}
+
+// fileItems Download every file the filter accepts, several at once, and call emit with their items one file at a
+// time until it returns an error.  The first memorySize bytes of each file are kept in memory and the rest spooled to
+// disk.  Files that fit are expanded, the others are given as is with their first bytes as Data and rest reading the
+// others
+func (client *FTPClient) fileItems(ctx context.Context, memorySize int, emit func(item *Item, rest io.Reader) error) error {
+	emitLock := sync.Mutex{}
+	return client.forEachFile(ctx, ".", func(pool *ftpPool, file FTPFile) error {
+		spool := &ftpSpool{max: memorySize}
+		defer spool.close()
+		// Files that can not be downloaded are skipped, with what was read of them kept
+		pool.downloadTo(ctx, file.Path, client.options.ReadLimit, spool)
+		rest, err := spool.rest()
 		if err != nil {
-			return
+			return nil
 		}
 
-		for file := range files {
-			fileResp, err := ourClient.client.Retr(file)
-			if err != nil {
-				continue
-			}
+		emitLock.Lock()
+		defer emitLock.Unlock()
+		item := client.fileItem(file, spool.data)
+		if rest != nil {
+			return emit(item, rest)
+		}
+		var emitErr error
+		expandItem(ctx, item, client.expandOptions(), func(item *Item) bool {
+			// Stop expanding once emit fails, such as when the reader was closed
+			emitErr = emit(item, nil)
+			return emitErr == nil
+		})
+		return emitErr
+	})
+}
+
+// expandOptions How to expand the files read
+func (client *FTPClient) expandOptions() expandOptions {
+	return expandOptions{archives: client.options.Archives, documents: client.options.Documents, sqlDumps: client.options.SQLDumps, sqlite: client.options.SQLite}
+}
+
+// fileItem Create an item for a file named by its URL without credentials
+func (client *FTPClient) fileItem(file FTPFile, data []byte) *Item {
+	fileURL := *client.url
+	fileURL.User = nil
+	fileURL.Path = path.Join("/", file.Path)
+
+	item := &Item{Name: fileURL.String(), Data: data, Metadata: map[string]string{"size": strconv.FormatInt(file.Size, 10)}}
+	if !file.ModTime.IsZero() {
+		item.Metadata["modified"] = file.ModTime.Format(time.RFC3339)
+	}
+	return item
+}
+
+// GetTLSInfo Get details of the server's TLS connection and certificates.  Returns the details recorded on connect
+// when connected over TLS, otherwise asks a new connection for TLS.  ErrNoTLS if the server does not support it
+func (client *FTPClient) GetTLSInfo(ctx context.Context) (*TLSInfo, error) {
+	if client.tlsInfo != nil {
+		return client.tlsInfo, nil
+	}
 
-			// TODO: Cancel with context
-			// Ignoring error currently
-			io.Copy(fileDataWriter, fileResp)
-			fileResp.Close()
+	address := net.JoinHostPort(client.url.Hostname(), client.port())
+	if client.url.Scheme == "ftps" {
+		return dialInspectTLS(ctx, address, client.url.Hostname(), nil)
+	}
+	return dialInspectTLS(ctx, address, client.url.Hostname(), ftpAuthTLS)
+}
+
+// ftpAuthTLS Read the greeting and ask the server to start TLS
+func ftpAuthTLS(conn net.Conn) error {
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	if _, _, err := reader.ReadResponse(220); err != nil {
+		return err
+	}
+	if _, err := conn.Write([]byte("AUTH TLS\r\n")); err != nil {
+		return err
+	}
+	if _, _, err := reader.ReadResponse(234); err != nil {
+		if _, ok := err.(*textproto.Error); ok {
+			return ErrNoTLS
 		}
-		fileDataWriter.Close()
-	}()
+		return err
+	}
+	return nil
+}
 
-	return fileDataReader
+// Size Get the total size and number of the files that would be read, walking the server on a new connection for
+// up to SizeWalkTime
+func (client *FTPClient) Size(ctx context.Context) (*ServerSize, error) {
+	walkCtx, cancel := context.WithTimeout(ctx, sizeWalkTime(client.options.SizeWalkTime))
+	defer cancel()
+
+	walkClient, err := NewFTPWithOptions(client.url.String(), client.options)
+	if err == nil {
+		err = walkClient.Connect(walkCtx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer walkClient.client.Quit()
+
+	files, err := walkClient.Walk(walkCtx, ".", client.options.Walk)
+	if err != nil {
+		return nil, err
+	}
+
+	size := &ServerSize{}
+	for file := range files {
+		if !client.options.Filter.Match(file.Path, file.Size, file.ModTime) {
+			continue
+		}
+		size.Items++
+		size.Bytes += uint64(file.Size)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	size.Complete = walkCtx.Err() == nil
+
+	return size, nil
 }
 
-// GetAllFilesInFolder Get all file paths in FTP folder
+// GetAllFilesInFolder Get full paths of all files under the FTP folder, walking with the client's walk options.
+// The walk uses the client's connection, so do not use the client for anything else until the channel is closed
 func (client *FTPClient) GetAllFilesInFolder(ctx context.Context, dir string) (chan string, error) {
-	files := make(chan string)
-
-	if !client.IsConnected() {
-		return nil, errors.New("not connected")
+	walk, err := client.Walk(ctx, dir, client.options.Walk)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get files
+	files := make(chan string)
 	go func() {
 		defer close(files)
 
-		// Get entries in this folder
-		entries, err := client.client.List(dir)
-		if err != nil {
-			return
-		}
-
-		// Iterate over
-		for _, entry := range entries {
-			if entry.Type == ftp.EntryTypeFolder {
-				if entry.Name != "." && entry.Name != ".." {
-					// This is a directory, go recursive
-					filesSub, err := client.GetAllFilesInFolder(ctx, entry.Name)
-					if err != nil {
-						return
-					}
-					for file := range filesSub {
-						select {
-						case files <- entry.Name + "/" + file:
-						case <-ctx.Done():
-							return
-						}
-					}
-				}
-			} else if entry.Type == ftp.EntryTypeFile {
-				// This is a file
-				select {
-				case files <- entry.Name:
-				case <-ctx.Done():
-					return
+		for file := range walk {
+			select {
+			case files <- file.Path:
+			case <-ctx.Done():
+				// Let the walk stop
+				for range walk {
 				}
+				return
 			}
 		}
 	}()
@@ -235,48 +413,49 @@ func (client *FTPClient) GetFilesMatchingRules(ctx context.Context, rules []*reg
 	return matchedFiles, nil
 }
 
-// GetFilesMatchingRulesInDir Get files that have content that matches the rules in the folder
+// GetFilesMatchingRulesInDir Get files that have content that matches the rules in the folder.  Files are checked
+// several at once on new connections
 func (client *FTPClient) GetFilesMatchingRulesInDir(ctx context.Context, dir string, rules []*regexp.Regexp, maxFileDownloadSize, maxFilesToCheck int64) (matchedFiles []string, err error) {
-	checkedFiles := int64(0)
-	return client.getFilesMatchingRulesInDirInner(ctx, dir, &checkedFiles, rules, maxFileDownloadSize, maxFilesToCheck, 0)
-}
-
-func (client *FTPClient) getFilesMatchingRulesInDirInner(ctx context.Context, dir string, checkedFiles *int64, rules []*regexp.Regexp, maxFileDownloadSize, maxFilesToCheck int64, depth int64) ([]string, error) {
-	// Ditch if we are too far down
-	if depth >= maxDepth {
-		return nil, errors.New("max depth exceeded")
-	}
-
-	matchedFiles := []string{}
+	checkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	// Check contents of each file
-	files, err := client.GetAllFilesInFolder(ctx, dir)
-	if err != nil {
-		return nil, err
-	}
+	matchedFiles = []string{}
+	checkedFiles := int64(0)
+	lock := sync.Mutex{}
+	err = client.forEachFile(checkCtx, dir, func(pool *ftpPool, file FTPFile) error {
+		// Check if we already read enough files
+		lock.Lock()
+		if checkedFiles >= maxFilesToCheck {
+			lock.Unlock()
+			cancel()
+			return nil
+		}
+		checkedFiles++
+		lock.Unlock()
 
-	for file := range files {
-		fileData, err := client.client.Retr(path.Join(dir, file))
+		// Files that can not be downloaded are skipped
+		fileData, err := pool.download(checkCtx, file.Path, maxFileDownloadSize)
 		if err != nil {
-			continue
+			return nil
 		}
 
 		// Read data
-		readCtx, cancel := context.WithTimeout(ctx, time.Second*10)
-		matchedRules := multiregex.RuleSet(rules).GetMatchedRulesReader(readCtx, ioutil.NopCloser(io.LimitReader(fileData, maxFileDownloadSize)))
-		cancel()
-		fileData.Close()
-
-		if len(matchedRules) > 0 {
-			matchedFiles = append(matchedFiles, file)
-		}
-
-		(*checkedFiles)++
-		// Check if we already read enough files
-		if *checkedFiles >= maxFilesToCheck {
-			break
+		readCtx, readCancel := context.WithTimeout(checkCtx, time.Second*10)
+		matched := multiregex.RuleSet(rules).MatchesRulesReader(readCtx, ioutil.NopCloser(bytes.NewReader(fileData)))
+		readCancel()
+
+		if matched {
+			lock.Lock()
+			matchedFiles = append(matchedFiles, file.Path)
+			lock.Unlock()
 		}
+		return nil
+	})
+	// Stopping after enough files is not an error
+	if err != nil && (err != context.Canceled || ctx.Err() != nil) {
+		return nil, err
 	}
 
+	sort.Strings(matchedFiles)
 	return matchedFiles, nil
 }
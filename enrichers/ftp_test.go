@@ -88,3 +88,44 @@ func TestGetFilesMatchingRules(t *testing.T) {
 		t.Errorf("Not obeying file limit")
 	}
 }
+
+func TestFTPItems(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{
+		"/backup.zip":       zipArchive(t, archiveFile{"db/dump.sql", []byte("password=secret")}),
+		"/docs/report.pdf":  testPDF(t),
+		"/users.csv":        utf16Data("user,password\nadmin,hunter2", false, true),
+		"/notes/readme.txt": []byte("plain"),
+	}, MLSD: true}
+	client := connectFakeFTP(t, server, FTPOptions{Concurrency: 2, Archives: &ArchiveOptions{}, Documents: true})
+	defer server.Close()
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	items, err := client.Items(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]*Item{}
+	for item := range items {
+		found[item.Name] = item
+	}
+
+	base := "ftp://" + client.url.Host
+	if len(found) != 5 {
+		t.Errorf("Wrong items %v", found)
+	}
+	if item := found[base+"/backup.zip!/db/dump.sql"]; item == nil || string(item.Data) != "password=secret" || item.Metadata["archive"] == "" {
+		t.Errorf("Wrong archive entry %+v", item)
+	}
+	if item := found[base+"/docs/report.pdf#text"]; item == nil || item.Metadata["parent"] != base+"/docs/report.pdf" || item.Metadata["format"] != "pdf" {
+		t.Errorf("Wrong document text %+v", item)
+	}
+	if item := found[base+"/users.csv"]; item == nil || string(item.Data) != "user,password\nadmin,hunter2" || item.Metadata["encoding"] != "utf-16le" {
+		t.Errorf("Wrong transcoded file %+v", item)
+	}
+	item := found[base+"/notes/readme.txt"]
+	if item == nil || item.Metadata["size"] != "5" || item.Metadata["modified"] != fakeFTPModTime.Format(time.RFC3339) || item.Metadata["content-type"] != "text/plain" {
+		t.Errorf("Wrong file %+v", item)
+	}
+}
@@ -0,0 +1,186 @@
+package enrichers
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"sync"
+)
+
+const (
+	// DefaultFTPConcurrency Number of files transferred at once when FTPOptions.Concurrency is not set
+	DefaultFTPConcurrency = 4
+)
+
+// MaxFTPTransfersPerServer Max number of files transferred at once from one server, across every FTPClient.
+// Many servers limit connections per address.  Change before starting any transfers
+var MaxFTPTransfersPerServer = 8
+
+// ftpServerSlots Semaphores limiting transfers per server address
+var ftpServerSlots = struct {
+	sync.Mutex
+	slots map[string]chan struct{}
+}{slots: map[string]chan struct{}{}}
+
+// ftpServerSlot Get the semaphore limiting transfers from the server at the address
+func ftpServerSlot(address string) chan struct{} {
+	ftpServerSlots.Lock()
+	defer ftpServerSlots.Unlock()
+
+	slot, ok := ftpServerSlots.slots[address]
+	if !ok {
+		limit := MaxFTPTransfersPerServer
+		if limit <= 0 {
+			limit = 1
+		}
+		slot = make(chan struct{}, limit)
+		ftpServerSlots.slots[address] = slot
+	}
+	return slot
+}
+
+// ftpPool Bounded pool of logged in connections to one server, each with its own control connection
+type ftpPool struct {
+	client *FTPClient      // Client to copy the URL and options from
+	idle   chan *FTPClient // Connections not in use
+	open   chan struct{}   // One value per open connection
+	server chan struct{}   // Transfers in progress on the server
+}
+
+// newFTPPool Create a pool of at most size connections to the client's server.  Connections are opened when needed
+func newFTPPool(client *FTPClient, size int) *ftpPool {
+	return &ftpPool{
+		client: client,
+		idle:   make(chan *FTPClient, size),
+		open:   make(chan struct{}, size),
+		server: ftpServerSlot(net.JoinHostPort(client.url.Hostname(), client.port())),
+	}
+}
+
+// get Get an idle connection, or open one if the pool is not full, otherwise wait for one
+func (pool *ftpPool) get(ctx context.Context) (*FTPClient, error) {
+	select {
+	case conn := <-pool.idle:
+		return conn, nil
+	default:
+	}
+
+	select {
+	case conn := <-pool.idle:
+		return conn, nil
+	case pool.open <- struct{}{}:
+		conn, err := NewFTPWithOptions(pool.client.url.String(), pool.client.options)
+		if err == nil {
+			err = conn.Connect(ctx)
+		}
+		if err != nil {
+			<-pool.open
+			return nil, err
+		}
+		return conn, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// put Return a connection to the pool.  Broken connections are closed so a new one is opened next time
+func (pool *ftpPool) put(conn *FTPClient, broken bool) {
+	if broken {
+		conn.client.Quit()
+		<-pool.open
+		return
+	}
+	pool.idle <- conn
+}
+
+// close Close every idle connection.  Call once every connection is returned
+func (pool *ftpPool) close() {
+	for {
+		select {
+		case conn := <-pool.idle:
+			pool.put(conn, true)
+		default:
+			return
+		}
+	}
+}
+
+// transfer Run fn on a connection from the pool while holding one of the server's transfer slots.
+// The connection is replaced if fn fails for anything other than an error reply from the server
+func (pool *ftpPool) transfer(ctx context.Context, fn func(conn *FTPClient) error) error {
+	select {
+	case pool.server <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-pool.server }()
+
+	conn, err := pool.get(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(conn)
+	_, replied := err.(*textproto.Error)
+	pool.put(conn, err != nil && !replied)
+	return err
+}
+
+// forEachFile Walk the directory on a new connection and run fn on every file the filter accepts.  Files are
+// handled Concurrency at a time, with the pool's connections, as the walk finds them.  The first error fn returns
+// stops the walk and the other files, and is returned
+func (client *FTPClient) forEachFile(ctx context.Context, dir string, fn func(pool *ftpPool, file FTPFile) error) error {
+	parentCtx := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := client.options.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultFTPConcurrency
+	}
+
+	// Walk on its own connection as to not overlap with the master connection or the transfers
+	walkClient, err := NewFTPWithOptions(client.url.String(), client.options)
+	if err == nil {
+		err = walkClient.Connect(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	defer walkClient.client.Quit()
+
+	files, err := walkClient.Walk(ctx, dir, client.options.Walk)
+	if err != nil {
+		return err
+	}
+
+	pool := newFTPPool(client, concurrency)
+	defer pool.close()
+
+	var fnErr error
+	fnErrOnce := sync.Once{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Keep reading until the walk closes the channel so it can stop
+			for file := range files {
+				if ctx.Err() != nil || !client.options.Filter.Match(file.Path, file.Size, file.ModTime) {
+					continue
+				}
+				if err := fn(pool, file); err != nil {
+					fnErrOnce.Do(func() {
+						fnErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if fnErr != nil {
+		return fnErr
+	}
+	return parentCtx.Err()
+}
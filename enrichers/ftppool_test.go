@@ -0,0 +1,158 @@
+package enrichers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// poolTestingFiles Many one letter files
+func poolTestingFiles() map[string][]byte {
+	files := map[string][]byte{}
+	for i := 0; i < 12; i++ {
+		files[fmt.Sprintf("/dir%d/file.txt", i%3)+fmt.Sprint(i)] = []byte{byte('a' + i)}
+	}
+	return files
+}
+
+func TestFTPConcurrentRead(t *testing.T) {
+	server := &fakeFTPServer{Files: poolTestingFiles(), Delay: 20 * time.Millisecond}
+	client := connectFakeFTP(t, server, FTPOptions{Concurrency: 4})
+	defer server.Close()
+	defer client.Close()
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	letters := strings.Split(string(data), "")
+	sort.Strings(letters)
+	if strings.Join(letters, "") != "abcdefghijkl" {
+		t.Errorf("Wrong data %q", data)
+	}
+
+	if server.maxTransfers < 2 || server.maxTransfers > 4 {
+		t.Errorf("Wrong number of transfers at once: %d", server.maxTransfers)
+	}
+	// Master, walk, and transfer connections each log in
+	if logins := countString(server.received(), "USER user"); logins > 6 {
+		t.Errorf("Opened too many connections: %d", logins)
+	}
+}
+
+func TestFTPServerTransferLimit(t *testing.T) {
+	server := &fakeFTPServer{Files: poolTestingFiles(), Delay: 10 * time.Millisecond}
+	address := startFakeFTPServer(t, server)
+	defer server.Close()
+
+	limit := MaxFTPTransfersPerServer
+	MaxFTPTransfersPerServer = 2
+	defer func() { MaxFTPTransfersPerServer = limit }()
+
+	// Two clients to the same server share the limit
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			client, err := NewFTPWithOptions("ftp://user:pass@"+address, FTPOptions{Concurrency: 4})
+			if err == nil {
+				err = client.Connect(context.Background())
+			}
+			if err != nil {
+				done <- err
+				return
+			}
+			defer client.Close()
+			data, err := ioutil.ReadAll(client)
+			if err == nil && len(data) != 12 {
+				err = fmt.Errorf("read %q", data)
+			}
+			done <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+
+	if server.maxTransfers != 2 {
+		t.Errorf("Wrong number of transfers at once: %d", server.maxTransfers)
+	}
+}
+
+func TestFTPConcurrentMatching(t *testing.T) {
+	files := poolTestingFiles()
+	files["/dir1/secret.txt"] = []byte("password=hunter2")
+	files["/dir2/secret.txt"] = []byte("password=letmein")
+	server := &fakeFTPServer{Files: files}
+	client := connectFakeFTP(t, server, FTPOptions{Concurrency: 3})
+	defer server.Close()
+	defer client.Close()
+
+	rules := []*regexp.Regexp{regexp.MustCompile("password=")}
+	matched, err := client.GetFilesMatchingRules(context.Background(), rules, 1024, 100)
+	if err != nil || !reflect.DeepEqual(matched, []string{"dir1/secret.txt", "dir2/secret.txt"}) {
+		t.Errorf("Wrong matched files %v %v", matched, err)
+	}
+
+	// Stops after checking enough files
+	before := countRetr(server.received())
+	if _, err := client.GetFilesMatchingRules(context.Background(), rules, 1024, 3); err != nil {
+		t.Error(err)
+	}
+	if checked := countRetr(server.received()) - before; checked > 3 {
+		t.Errorf("Checked %d files", checked)
+	}
+}
+
+func TestFTPForEachFileError(t *testing.T) {
+	server := &fakeFTPServer{Files: poolTestingFiles(), Delay: 10 * time.Millisecond}
+	client := connectFakeFTP(t, server, FTPOptions{Concurrency: 2})
+	defer server.Close()
+	defer client.Close()
+
+	// The first error stops the other files
+	stop := errors.New("stop")
+	lock := sync.Mutex{}
+	handled := 0
+	err := client.forEachFile(context.Background(), ".", func(pool *ftpPool, file FTPFile) error {
+		lock.Lock()
+		handled++
+		lock.Unlock()
+		if _, err := pool.download(context.Background(), file.Path, 0); err != nil {
+			return err
+		}
+		return stop
+	})
+	if err != stop || handled > 2 {
+		t.Errorf("Wrong error %v after %d files", err, handled)
+	}
+}
+
+func countString(list []string, s string) int {
+	count := 0
+	for _, str := range list {
+		if str == s {
+			count++
+		}
+	}
+	return count
+}
+
+func countRetr(commands []string) int {
+	count := 0
+	for _, command := range commands {
+		if strings.HasPrefix(command, "RETR ") {
+			count++
+		}
+	}
+	return count
+}
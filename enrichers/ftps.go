@@ -0,0 +1,99 @@
+package enrichers
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFTPPort  = "21"
+	defaultFTPSPort = "990"
+)
+
+// usesTLS Check if the client connects over TLS
+func (client *FTPClient) usesTLS() bool {
+	return client.url.Scheme == "ftps" || client.options.ExplicitTLS
+}
+
+// port Get port from the URL or the default for the scheme
+func (client *FTPClient) port() string {
+	if port := client.url.Port(); port != "" {
+		return port
+	}
+	if client.url.Scheme == "ftps" {
+		return defaultFTPSPort
+	}
+	return defaultFTPPort
+}
+
+// tlsConfig Get TLS config for the control and data connections
+func (client *FTPClient) tlsConfig() *tls.Config {
+	var config *tls.Config
+	if client.options.TLSConfig != nil {
+		config = client.options.TLSConfig.Clone()
+	} else {
+		config = &tls.Config{
+			InsecureSkipVerify: client.options.InsecureSkipVerify,
+			RootCAs:            client.options.RootCAs,
+			Certificates:       client.options.ClientCertificates,
+		}
+	}
+	if config.ServerName == "" {
+		config.ServerName = client.url.Hostname()
+	}
+	// Many servers require data connections to resume the control connection's session
+	if config.ClientSessionCache == nil {
+		config.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	return config
+}
+
+// dialTLS Connect and start TLS, right away for ftps:// or with AUTH TLS.  Records the TLS details.
+// The returned connection replays a greeting after AUTH TLS so it can be handed to ftp.Dial
+func (client *FTPClient) dialTLS(ctx context.Context, address string, config *tls.Config) (net.Conn, error) {
+	conn, err := (&net.Dialer{Timeout: 30 * time.Second}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	implicit := client.url.Scheme == "ftps"
+	if !implicit {
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		if err := ftpAuthTLS(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsHandshake(ctx, tlsConn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	state := tlsConn.ConnectionState()
+	client.tlsInfo = newTLSInfo(&state)
+
+	if implicit {
+		return tlsConn, nil
+	}
+	return &greetedConn{Conn: tlsConn, reader: io.MultiReader(strings.NewReader("220 TLS started\r\n"), tlsConn)}, nil
+}
+
+// greetedConn A connection that reads a greeting before what the server sends
+type greetedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+// Read the greeting then the connection
+func (conn *greetedConn) Read(p []byte) (int, error) {
+	return conn.reader.Read(p)
+}
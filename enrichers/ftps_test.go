@@ -0,0 +1,494 @@
+package enrichers
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFTPServer Minimal FTP server serving files from memory
+type fakeFTPServer struct {
+	Files     map[string][]byte // Absolute path to data.  Directories are implied by the paths
+	Links     map[string]string // Absolute path of a symlink to its target
+	TLSConfig *tls.Config       // Enables AUTH TLS, or implicit TLS if Implicit
+	Implicit  bool
+	MLSD      bool          // Advertise and answer MLSD
+	Delay     time.Duration // Time each RETR takes
+	DropAfter int           // Drop the connections after sending this many bytes of a file, once per file
+	// OneWriteAbort Send the replies to an aborted transfer and to its ABOR in one write
+	OneWriteAbort bool
+
+	listener     net.Listener
+	lock         sync.Mutex
+	commands     []string // Every command received
+	transfers    int      // RETRs in progress
+	maxTransfers int      // Most RETRs in progress at once
+	dropped      map[string]bool
+}
+
+// startFakeFTPServer Start serving.  Close the server when done
+func startFakeFTPServer(t *testing.T, server *fakeFTPServer) string {
+	var err error
+	server.dropped = map[string]bool{}
+	server.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := server.listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server.listener.Addr().String()
+}
+
+// Close Stop accepting connections
+func (server *fakeFTPServer) Close() error {
+	return server.listener.Close()
+}
+
+// received Get every command received
+func (server *fakeFTPServer) received() []string {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return append([]string{}, server.commands...)
+}
+
+// fakeFTPModTime Modification time of every file on the fake server
+var fakeFTPModTime = time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+// follow Resolve symlinks in the path
+func (server *fakeFTPServer) follow(p string) string {
+	for i := 0; i < 10; i++ {
+		resolved := p
+		for link, target := range server.Links {
+			if p == link || strings.HasPrefix(p, link+"/") {
+				if !strings.HasPrefix(target, "/") {
+					target = path.Join(path.Dir(link), target)
+				}
+				resolved = path.Clean(target + strings.TrimPrefix(p, link))
+			}
+		}
+		if resolved == p {
+			break
+		}
+		p = resolved
+	}
+	return p
+}
+
+// list Get the names of the files, directories, and links directly in the directory
+func (server *fakeFTPServer) list(dir string) (files map[string]int, dirs map[string]bool, links map[string]string, ok bool) {
+	files, dirs, links = map[string]int{}, map[string]bool{}, map[string]string{}
+	for link, target := range server.Links {
+		if path.Dir(link) == dir {
+			links[path.Base(link)] = target
+		}
+	}
+	for filePath, data := range server.Files {
+		if filePath == dir {
+			return nil, nil, nil, false
+		}
+		rel := strings.TrimPrefix(filePath, strings.TrimSuffix(dir, "/")+"/")
+		if rel == filePath && dir != "/" {
+			continue
+		}
+		ok = true
+		if slash := strings.Index(rel, "/"); slash != -1 {
+			dirs[rel[0:slash]] = true
+		} else {
+			files[rel] = len(data)
+		}
+	}
+	return files, dirs, links, ok || dir == "/"
+}
+
+func (server *fakeFTPServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	if server.Implicit {
+		conn = tls.Server(conn, server.TLSConfig)
+	}
+	reader := bufio.NewReader(conn)
+	replyLock := sync.Mutex{}
+	reply := func(format string, args ...interface{}) {
+		replyLock.Lock()
+		defer replyLock.Unlock()
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	cwd, protected := "/", false
+	var dataListener net.Listener
+	var offset int64
+	resolve := func(p string) string {
+		if p == "" || p == "." || p == "-a" {
+			return cwd
+		}
+		if !strings.HasPrefix(p, "/") {
+			p = path.Join(cwd, p)
+		}
+		return path.Clean(p)
+	}
+	// Transfers run while commands are read so they can be aborted
+	var transfer, abort chan struct{}
+	var transferConn net.Conn
+	abortLock, abortReplied := sync.Mutex{}, false // The ABOR sends the transfer's reply too
+	sendData := func(data []byte, delay time.Duration, dropAfter int) {
+		if dataListener == nil {
+			reply("425 No data connection")
+			return
+		}
+		dataConn, err := dataListener.Accept()
+		dataListener.Close()
+		dataListener = nil
+		if err != nil {
+			reply("425 No data connection")
+			return
+		}
+		if protected {
+			dataConn = tls.Server(dataConn, server.TLSConfig)
+		}
+		reply("150 Opening data connection")
+
+		done, aborted := make(chan struct{}), make(chan struct{})
+		transfer, abort, transferConn = done, aborted, dataConn
+		go func() {
+			defer close(done)
+			select {
+			case <-time.After(delay):
+			case <-aborted:
+			}
+
+			var err error
+			if dropAfter > 0 {
+				// Lose the connection part way
+				dataConn.Write(data[0:dropAfter])
+				dataConn.Close()
+				conn.Close()
+				return
+			}
+			for len(data) > 0 && err == nil {
+				select {
+				case <-aborted:
+					err = errors.New("aborted")
+				default:
+					chunk := 4096
+					if chunk > len(data) {
+						chunk = len(data)
+					}
+					_, err = dataConn.Write(data[0:chunk])
+					data = data[chunk:]
+				}
+			}
+			dataConn.Close()
+			abortLock.Lock()
+			quiet := abortReplied
+			abortLock.Unlock()
+			if err != nil && quiet {
+				return
+			} else if err != nil {
+				reply("426 Transfer aborted")
+			} else {
+				reply("226 Transfer complete")
+			}
+		}()
+	}
+
+	reply("220 Fake FTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		server.lock.Lock()
+		server.commands = append(server.commands, line)
+		server.lock.Unlock()
+
+		command, arg := line, ""
+		if space := strings.Index(line, " "); space != -1 {
+			command, arg = line[0:space], line[space+1:]
+		}
+		switch strings.ToUpper(command) {
+		case "AUTH":
+			if server.TLSConfig == nil || server.Implicit {
+				reply("502 Not implemented")
+				continue
+			}
+			reply("234 AUTH TLS OK")
+			conn = tls.Server(conn, server.TLSConfig)
+			reader = bufio.NewReader(conn)
+		case "USER":
+			reply("331 Password required")
+		case "PASS":
+			reply("230 Logged in")
+		case "FEAT":
+			if server.MLSD {
+				reply("211-Features:\r\n UTF8\r\n MLST type*;size*;modify*;\r\n211 End")
+			} else {
+				reply("211-Features:\r\n UTF8\r\n211 End")
+			}
+		case "TYPE", "OPTS", "PBSZ":
+			reply("200 OK")
+		case "PROT":
+			protected = arg == "P"
+			reply("200 OK")
+		case "NOOP":
+			reply("200 OK")
+		case "PWD":
+			reply("257 \"%s\"", cwd)
+		case "CWD":
+			if _, _, _, ok := server.list(server.follow(resolve(arg))); !ok {
+				reply("550 No such directory")
+				continue
+			}
+			cwd = resolve(arg)
+			reply("250 OK")
+		case "EPSV":
+			reply("502 Not implemented")
+		case "PASV":
+			dataListener, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply("425 Can not open data connection")
+				continue
+			}
+			port := dataListener.Addr().(*net.TCPAddr).Port
+			reply("227 Entering Passive Mode (127,0,0,1,%d,%d)", port/256, port%256)
+		case "REST":
+			offset, _ = strconv.ParseInt(arg, 10, 64)
+			reply("350 Restarting at %d", offset)
+		case "SIZE":
+			data, ok := server.Files[server.follow(resolve(arg))]
+			if !ok {
+				reply("550 No such file")
+				continue
+			}
+			reply("213 %d", len(data))
+		case "LIST", "MLSD":
+			if strings.ToUpper(command) == "MLSD" && !server.MLSD {
+				reply("500 Unknown command")
+				continue
+			}
+			files, dirs, links, ok := server.list(server.follow(resolve(arg)))
+			if !ok {
+				reply("550 No such directory")
+				continue
+			}
+			sendData(server.listing(strings.ToUpper(command) == "MLSD", files, dirs, links), 0, 0)
+		case "RETR":
+			data, ok := server.Files[server.follow(resolve(arg))]
+			if !ok {
+				reply("550 No such file")
+				continue
+			}
+			if offset > int64(len(data)) {
+				offset = int64(len(data))
+			}
+			server.lock.Lock()
+			dropAfter := 0
+			if server.DropAfter > 0 && int64(len(data))-offset > int64(server.DropAfter) && !server.dropped[arg] {
+				dropAfter = server.DropAfter
+				server.dropped[arg] = true
+			}
+			server.transfers++
+			if server.transfers > server.maxTransfers {
+				server.maxTransfers = server.transfers
+			}
+			server.lock.Unlock()
+			sendData(data[offset:], server.Delay, dropAfter)
+			offset = 0
+			go func(done chan struct{}) {
+				<-done
+				server.lock.Lock()
+				server.transfers--
+				server.lock.Unlock()
+			}(transfer)
+		case "ABOR":
+			if transfer == nil {
+				reply("225 No transfer to abort")
+				continue
+			}
+			select {
+			case <-transfer:
+				reply("225 No transfer to abort")
+			default:
+				abortLock.Lock()
+				abortReplied = server.OneWriteAbort
+				abortLock.Unlock()
+				close(abort)
+				transferConn.Close()
+				<-transfer
+				transfer = nil
+				abortLock.Lock()
+				quiet := abortReplied
+				abortReplied = false
+				abortLock.Unlock()
+				if quiet {
+					reply("426 Transfer aborted\r\n226 Abort successful")
+				} else {
+					reply("226 Abort successful")
+				}
+			}
+			transfer = nil
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+// listing Format a directory listing like LIST or MLSD
+func (server *fakeFTPServer) listing(mlsd bool, files map[string]int, dirs map[string]bool, links map[string]string) []byte {
+	listing := &strings.Builder{}
+	modify := fakeFTPModTime.Format("20060102150405")
+	lsTime := fakeFTPModTime.Format("Jan 02 2006")
+
+	names := []string{}
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if mlsd {
+			fmt.Fprintf(listing, "type=dir;modify=%s; %s\r\n", modify, name)
+		} else {
+			fmt.Fprintf(listing, "drwxr-xr-x 2 owner group 4096 %s %s\r\n", lsTime, name)
+		}
+	}
+
+	names = []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if mlsd {
+			fmt.Fprintf(listing, "type=file;size=%d;modify=%s; %s\r\n", files[name], modify, name)
+		} else {
+			fmt.Fprintf(listing, "-rw-r--r-- 1 owner group %d %s %s\r\n", files[name], lsTime, name)
+		}
+	}
+
+	names = []string{}
+	for name := range links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !mlsd {
+			fmt.Fprintf(listing, "lrwxrwxrwx 1 owner group %d %s %s -> %s\r\n", len(links[name]), lsTime, name, links[name])
+		}
+	}
+
+	return []byte(listing.String())
+}
+
+func TestFTPS(t *testing.T) {
+	certificate := testCertificate(t, "ecdsa", "ftp.test")
+	files := map[string][]byte{"/hello.txt": []byte("hello"), "/world.txt": []byte("world")}
+
+	tests := []struct {
+		scheme  string
+		options FTPOptions
+		server  *fakeFTPServer
+	}{
+		// Explicit
+		{"ftp", FTPOptions{ExplicitTLS: true, InsecureSkipVerify: true, Concurrency: 1}, &fakeFTPServer{Files: files, TLSConfig: &tls.Config{Certificates: []tls.Certificate{certificate}}}},
+		// Implicit
+		{"ftps", FTPOptions{InsecureSkipVerify: true, Concurrency: 1}, &fakeFTPServer{Files: files, TLSConfig: &tls.Config{Certificates: []tls.Certificate{certificate}}, Implicit: true}},
+	}
+
+	for i, test := range tests {
+		address := startFakeFTPServer(t, test.server)
+		defer test.server.Close()
+		client, err := NewFTPWithOptions(test.scheme+"://user:pass@"+address, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Connect(context.Background()); err != nil {
+			t.Errorf("Test %d failed to connect: %v", i, err)
+			continue
+		}
+
+		info, err := client.GetTLSInfo(context.Background())
+		if err != nil || !reflect.DeepEqual(info.Hostnames, []string{"ftp.test"}) {
+			t.Errorf("Test %d did not record TLS info: %v", i, err)
+		}
+
+		data, err := ioutil.ReadAll(client)
+		if err != nil || string(data) != "helloworld" {
+			t.Errorf("Test %d read %q %v", i, data, err)
+		}
+		client.Close()
+
+		if received := test.server.received(); !containsString(received, "PROT P") {
+			t.Errorf("Test %d did not protect data connections: %v", i, received)
+		}
+	}
+}
+
+func TestFTPSVerification(t *testing.T) {
+	server := &fakeFTPServer{TLSConfig: &tls.Config{Certificates: []tls.Certificate{testCertificate(t, "ecdsa", "ftp.test")}}}
+	address := startFakeFTPServer(t, server)
+	defer server.Close()
+
+	client, _ := NewFTPWithOptions("ftp://"+address, FTPOptions{ExplicitTLS: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Connect(ctx); err == nil {
+		t.Errorf("Should not trust self signed certificate")
+	}
+
+	// Server without TLS
+	plain := &fakeFTPServer{}
+	address = startFakeFTPServer(t, plain)
+	defer plain.Close()
+	client, _ = NewFTPWithOptions("ftp://"+address, FTPOptions{ExplicitTLS: true, InsecureSkipVerify: true})
+	if err := client.Connect(ctx); err != ErrNoTLS {
+		t.Errorf("Expected ErrNoTLS, got %v", err)
+	}
+}
+
+func TestFTPPort(t *testing.T) {
+	tests := []struct {
+		url  string
+		port uint16
+	}{
+		{"ftp://localhost", 21},
+		{"ftps://localhost", 990},
+		{"ftps://localhost:2121", 2121},
+	}
+	for _, test := range tests {
+		client, _ := NewFTP(test.url)
+		if port := client.GetPort(); port != test.port {
+			t.Errorf("Wrong port for %s, got %d", test.url, port)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package enrichers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+const (
+	ftpAbortTimeout     = 10 * time.Second // Time to wait for the server to abort a transfer
+	ftpTransferAttempts = 3                // Times to try a file, resuming where the last attempt stopped
+	ftpMaxItemSize      = 64 * 1024 * 1024 // Max bytes of a file kept in memory to expand or give as an item
+	ftpSpoolMemorySize  = 1024 * 1024      // Bytes of a file kept in memory before spooling the rest to disk
+)
+
+// OpenFile Open the file at the path, starting offset bytes in with REST.  Reading stops with ctx's error as soon as
+// ctx is cancelled.  Closing before the end aborts the transfer with ABOR and logs in again on a new connection so the
+// client can still be used.  Do not use the client for anything else until the file is closed
+func (client *FTPClient) OpenFile(ctx context.Context, filePath string, offset int64) (io.ReadCloser, error) {
+	if client.client == nil {
+		return nil, errors.New("not connected")
+	}
+
+	response, err := client.client.RetrFrom(filePath, uint64(offset))
+	if err != nil {
+		return nil, err
+	}
+
+	file := &ftpFileReader{ctx: ctx, client: client, response: response, done: make(chan struct{})}
+	// Interrupt reads when cancelled
+	go func() {
+		select {
+		case <-ctx.Done():
+			response.SetDeadline(time.Now())
+		case <-file.done:
+		}
+	}()
+
+	return file, nil
+}
+
+// ftpFileReader A file being transferred
+type ftpFileReader struct {
+	ctx       context.Context
+	client    *FTPClient
+	response  *ftp.Response
+	eof       bool
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Read from the data connection
+func (file *ftpFileReader) Read(p []byte) (int, error) {
+	if err := file.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := file.response.Read(p)
+	if err == io.EOF {
+		file.eof = true
+	} else if err != nil && file.ctx.Err() != nil {
+		err = file.ctx.Err()
+	}
+	return n, err
+}
+
+// Close Finish the transfer, aborting it if the file was not read to the end
+func (file *ftpFileReader) Close() (err error) {
+	file.closeOnce.Do(func() {
+		close(file.done)
+		if file.eof {
+			err = file.response.Close()
+			return
+		}
+		err = file.client.abort(file.response)
+	})
+	return err
+}
+
+// abort Stop a transfer with ABOR, close its data connection, and replace the control connection.  The transfer and
+// the ABOR both get a reply, and the library may have read either into its buffer already, so the connection can not
+// be trusted to be in step with the server afterwards
+func (client *FTPClient) abort(response *ftp.Response) error {
+	client.control.SetDeadline(time.Now().Add(ftpAbortTimeout))
+	client.control.Write([]byte("ABOR\r\n"))
+	response.Close()
+	client.client.Quit()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ftpAbortTimeout)
+	defer cancel()
+	if err := client.Connect(ctx); err != nil {
+		// Not a reply to the transfer, so the pool drops the connection
+		return fmt.Errorf("reconnecting after abort: %v", err)
+	}
+	return nil
+}
+
+// download Read the file, or only its first limit bytes when limit is over 0.  Transfers that fail part way are
+// resumed on a new connection with REST
+func (pool *ftpPool) download(ctx context.Context, filePath string, limit int64) ([]byte, error) {
+	data := &bytes.Buffer{}
+	_, err := pool.downloadTo(ctx, filePath, limit, data)
+	return data.Bytes(), err
+}
+
+// downloadTo Write the file to w as it is transferred, or only its first limit bytes when limit is over 0.  Transfers
+// that fail part way are resumed on a new connection with REST.  Returns the number of bytes written
+func (pool *ftpPool) downloadTo(ctx context.Context, filePath string, limit int64, w io.Writer) (int64, error) {
+	written := int64(0)
+	var err error
+	for attempt := 0; attempt < ftpTransferAttempts; attempt++ {
+		err = pool.transfer(ctx, func(conn *FTPClient) error {
+			file, err := conn.OpenFile(ctx, filePath, written)
+			if err != nil {
+				return err
+			}
+			var reader io.Reader = file
+			if limit > 0 {
+				reader = io.LimitReader(file, limit-written)
+			}
+			n, err := io.Copy(w, reader)
+			written += n
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			return err
+		})
+
+		// Permanent failures, such as a missing file, are not retried
+		if replyErr, ok := err.(*textproto.Error); err == nil || ctx.Err() != nil || (ok && replyErr.Code >= 500) {
+			break
+		}
+	}
+
+	return written, err
+}
+
+// ftpSpool Holds a downloaded file with its first max bytes in memory and the rest in a temporary file
+type ftpSpool struct {
+	max  int
+	data []byte
+	file *os.File
+}
+
+// Write Add to the data in memory until it is full, then to the temporary file
+func (spool *ftpSpool) Write(p []byte) (int, error) {
+	kept := 0
+	if room := spool.max - len(spool.data); room > 0 && spool.file == nil {
+		kept = room
+		if kept > len(p) {
+			kept = len(p)
+		}
+		spool.data = append(spool.data, p[:kept]...)
+		if kept == len(p) {
+			return kept, nil
+		}
+	}
+
+	if spool.file == nil {
+		file, err := ioutil.TempFile("", "genericenricher-*.ftp")
+		if err != nil {
+			return kept, err
+		}
+		spool.file = file
+	}
+	n, err := spool.file.Write(p[kept:])
+	return kept + n, err
+}
+
+// rest Get a reader of the bytes that did not fit in memory.  nil if they all did
+func (spool *ftpSpool) rest() (io.Reader, error) {
+	if spool.file == nil {
+		return nil, nil
+	}
+	if _, err := spool.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return spool.file, nil
+}
+
+// close Remove the temporary file
+func (spool *ftpSpool) close() {
+	if spool.file != nil {
+		spool.file.Close()
+		os.Remove(spool.file.Name())
+	}
+}
@@ -0,0 +1,186 @@
+package enrichers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// ftpTestingData Data larger than socket buffers so transfers are still running when aborted
+var ftpTestingData = bytes.Repeat([]byte("0123456789"), 1<<17)
+
+func TestFTPOpenFile(t *testing.T) {
+	// Replies to the abort sent apart and in one write
+	for _, oneWrite := range []bool{false, true} {
+		server := &fakeFTPServer{Files: map[string][]byte{"/big": ftpTestingData, "/small": []byte("s")}, OneWriteAbort: oneWrite}
+		client := connectFakeFTP(t, server, FTPOptions{})
+		defer server.Close()
+		defer client.Close()
+
+		tests := []struct {
+			path   string
+			offset int64
+			read   int // Bytes to read before closing.  -1 for all
+			want   []byte
+		}{
+			{"/big", 0, -1, ftpTestingData},
+			{"/big", 5, 3, []byte("567")}, // Abort a running transfer
+			{"/big", 0, 4, []byte("0123")},
+			{"/small", 0, 0, []byte{}}, // Abort a finished transfer
+			{"/small", 0, -1, []byte("s")},
+		}
+
+		for i, test := range tests {
+			file, err := client.OpenFile(context.Background(), test.path, test.offset)
+			if err != nil {
+				t.Fatalf("Test %d with oneWrite=%v: %v", i, oneWrite, err)
+			}
+			var data []byte
+			if test.read == -1 {
+				data, err = ioutil.ReadAll(file)
+			} else {
+				data = make([]byte, test.read)
+				_, err = file.Read(data)
+				if test.read == 0 {
+					// Let the transfer finish
+					time.Sleep(50 * time.Millisecond)
+					err = nil
+				}
+			}
+			if err != nil || !bytes.Equal(data, test.want) {
+				t.Errorf("Test %d with oneWrite=%v read %d bytes %v", i, oneWrite, len(data), err)
+			}
+			if err := file.Close(); err != nil {
+				t.Errorf("Test %d with oneWrite=%v failed to close: %v", i, oneWrite, err)
+			}
+
+			// The connection still works
+			if !client.IsConnected() {
+				t.Fatalf("Test %d with oneWrite=%v broke the connection", i, oneWrite)
+			}
+		}
+
+		if !containsString(server.received(), "REST 5") || !containsString(server.received(), "ABOR") {
+			t.Errorf("Did not resume or abort: %v", server.received())
+		}
+	}
+}
+
+func TestFTPOpenFileCancel(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{"/slow": []byte("slow")}, Delay: 10 * time.Second}
+	client := connectFakeFTP(t, server, FTPOptions{})
+	defer server.Close()
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	file, err := client.OpenFile(ctx, "/slow", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := ioutil.ReadAll(file); err != context.Canceled {
+		t.Errorf("Wrong error %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Errorf("Failed to abort: %v", err)
+	}
+	if time.Since(start) > 5*time.Second || !client.IsConnected() {
+		t.Errorf("Did not stop promptly")
+	}
+}
+
+func TestFTPCloseWhileReading(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{"/slow": []byte("slow")}, Delay: 10 * time.Second}
+	client := connectFakeFTP(t, server, FTPOptions{})
+	defer server.Close()
+
+	if err := client.ResetReader(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ioutil.ReadAll(client)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	client.Close()
+	<-done
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Took %v to stop reading", time.Since(start))
+	}
+}
+
+func TestFTPReadLimit(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{"/a": ftpTestingData, "/b": []byte("b"), "/c": ftpTestingData}}
+	client := connectFakeFTP(t, server, FTPOptions{Concurrency: 1, ReadLimit: 3})
+	defer server.Close()
+	defer client.Close()
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil || string(data) != "012b012" {
+		t.Errorf("Wrong data %q %v", data, err)
+	}
+	if !containsString(server.received(), "ABOR") {
+		t.Errorf("Did not abort")
+	}
+	// Master, walk, and a transfer connection that logs in again after each of the two aborts
+	if logins := countString(server.received(), "USER user"); logins != 5 {
+		t.Errorf("Opened %d connections", logins)
+	}
+}
+
+func TestFTPResume(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{"/big": ftpTestingData}, DropAfter: 1000}
+	client := connectFakeFTP(t, server, FTPOptions{})
+	defer server.Close()
+	defer client.Close()
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil || !bytes.Equal(data, ftpTestingData) {
+		t.Errorf("Read %d bytes %v", len(data), err)
+	}
+	if !containsString(server.received(), "REST 1000") {
+		t.Errorf("Did not resume")
+	}
+}
+
+func TestFTPSpool(t *testing.T) {
+	spool := &ftpSpool{max: 4}
+	defer spool.close()
+	for _, part := range []string{"ab", "cdef", "gh"} {
+		if n, err := spool.Write([]byte(part)); err != nil || n != len(part) {
+			t.Fatalf("Wrote %d %v", n, err)
+		}
+	}
+	rest, err := spool.rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restData, _ := ioutil.ReadAll(rest)
+	if string(spool.data) != "abcd" || string(restData) != "efgh" {
+		t.Errorf("Wrong spool %q %q", spool.data, restData)
+	}
+	name := spool.file.Name()
+	spool.close()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("Did not remove %s", name)
+	}
+
+	// Large files are read whole without holding them in memory
+	large := bytes.Repeat([]byte("0123456789"), ftpSpoolMemorySize/4)
+	server := &fakeFTPServer{Files: map[string][]byte{"/a": []byte("a"), "/large": large, "/z": []byte("z")}}
+	client := connectFakeFTP(t, server, FTPOptions{Concurrency: 1})
+	defer server.Close()
+	defer client.Close()
+	data, err := ioutil.ReadAll(client)
+	if err != nil || !bytes.Equal(data, append(append([]byte("a"), large...), 'z')) {
+		t.Errorf("Read %d bytes %v", len(data), err)
+	}
+}
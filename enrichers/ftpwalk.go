@@ -0,0 +1,133 @@
+package enrichers
+
+import (
+	"context"
+	"errors"
+	"path"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// FTPWalkOptions How to walk directories on an FTP server
+type FTPWalkOptions struct {
+	FollowSymlinks bool // Follow symlinks to files and directories instead of skipping them
+	MaxDepth       int  // Max number of directories deep to go.  0 for the default of 100
+}
+
+// FTPFile A file found walking an FTP server
+type FTPFile struct {
+	Path       string // Path joined with the directory the walk started in
+	Size       int64
+	ModTime    time.Time
+	LinkTarget string // Target if the file was reached through a symlink
+}
+
+// ftpWalker State of a walk
+type ftpWalker struct {
+	ctx      context.Context
+	conn     *ftp.ServerConn
+	options  FTPWalkOptions
+	maxDepth int
+	visited  map[string]bool // Real paths of directories already walked
+	files    chan FTPFile
+}
+
+// Walk Get every file under the directory with full paths, sizes, and modification times.  Directories are listed
+// with MLSD when the server supports it, otherwise with LIST.  Directories already walked, such as ones reached again
+// through a symlink, are skipped.
+// The walk uses the client's connection, so do not use the client for anything else until the channel is closed
+func (client *FTPClient) Walk(ctx context.Context, dir string, options FTPWalkOptions) (chan FTPFile, error) {
+	if !client.IsConnected() {
+		return nil, errors.New("not connected")
+	}
+
+	walker := &ftpWalker{
+		ctx:      ctx,
+		conn:     client.client,
+		options:  options,
+		maxDepth: options.MaxDepth,
+		visited:  map[string]bool{},
+		files:    make(chan FTPFile),
+	}
+	if walker.maxDepth <= 0 {
+		walker.maxDepth = maxDepth
+	}
+
+	// Real path of the directory to detect cycles with absolute symlinks
+	realDir := path.Clean(dir)
+	if !path.IsAbs(realDir) {
+		if cwd, err := client.client.CurrentDir(); err == nil {
+			realDir = path.Join(cwd, realDir)
+		}
+	}
+
+	go func() {
+		defer close(walker.files)
+		walker.walk(dir, realDir, 0)
+	}()
+
+	return walker.files, nil
+}
+
+// walk Walk the directory at dir, which really is at realDir.  Returns false if the walk should stop
+func (walker *ftpWalker) walk(dir, realDir string, depth int) bool {
+	if depth > walker.maxDepth || walker.visited[realDir] {
+		return true
+	}
+	walker.visited[realDir] = true
+
+	entries, err := walker.conn.List(dir)
+	if err != nil {
+		return walker.ctx.Err() == nil
+	}
+
+	for _, entry := range entries {
+		// Some servers list full paths
+		name := path.Base(entry.Name)
+		if name == "." || name == ".." || name == "/" {
+			continue
+		}
+		entryPath, realPath := path.Join(dir, name), path.Join(realDir, name)
+
+		switch entry.Type {
+		case ftp.EntryTypeFolder:
+			if !walker.walk(entryPath, realPath, depth+1) {
+				return false
+			}
+		case ftp.EntryTypeFile:
+			if !walker.send(FTPFile{Path: entryPath, Size: int64(entry.Size), ModTime: entry.Time}) {
+				return false
+			}
+		case ftp.EntryTypeLink:
+			if !walker.options.FollowSymlinks || entry.Target == "" {
+				continue
+			}
+			target := entry.Target
+			if !path.IsAbs(target) {
+				target = path.Join(realDir, target)
+			}
+
+			// A link to a file has a size, a link to a directory does not
+			if size, err := walker.conn.FileSize(entryPath); err == nil {
+				if !walker.send(FTPFile{Path: entryPath, Size: size, ModTime: entry.Time, LinkTarget: entry.Target}) {
+					return false
+				}
+			} else if !walker.walk(entryPath, path.Clean(target), depth+1) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// send Send a file.  Returns false if the walk was cancelled
+func (walker *ftpWalker) send(file FTPFile) bool {
+	select {
+	case walker.files <- file:
+		return true
+	case <-walker.ctx.Done():
+		return false
+	}
+}
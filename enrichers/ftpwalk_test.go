@@ -0,0 +1,144 @@
+package enrichers
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// walkTestingFiles Nested files for walking
+var walkTestingFiles = map[string][]byte{
+	"/a.txt":           []byte("a"),
+	"/dir/b.txt":       []byte("bb"),
+	"/dir/sub/c.txt":   []byte("secret"),
+	"/elsewhere/d.txt": []byte("dddd"),
+}
+
+// connectFakeFTP Start a fake server and connect to it
+func connectFakeFTP(t *testing.T, server *fakeFTPServer, options FTPOptions) *FTPClient {
+	address := startFakeFTPServer(t, server)
+	client, err := NewFTPWithOptions("ftp://user:pass@"+address, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func walkPaths(t *testing.T, client *FTPClient, dir string, options FTPWalkOptions) map[string]FTPFile {
+	files, err := client.Walk(context.Background(), dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]FTPFile{}
+	for file := range files {
+		found[file.Path] = file
+	}
+	return found
+}
+
+func keys(files map[string]FTPFile) []string {
+	paths := []string{}
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestFTPWalk(t *testing.T) {
+	for _, mlsd := range []bool{false, true} {
+		server := &fakeFTPServer{Files: walkTestingFiles, MLSD: mlsd}
+		client := connectFakeFTP(t, server, FTPOptions{})
+
+		files := walkPaths(t, client, ".", FTPWalkOptions{})
+		if paths := keys(files); !reflect.DeepEqual(paths, []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "elsewhere/d.txt"}) {
+			t.Errorf("Wrong paths with mlsd=%v: %v", mlsd, paths)
+		}
+		if file := files["dir/sub/c.txt"]; file.Size != 6 || file.ModTime.Year() != 2006 {
+			t.Errorf("Wrong size or time with mlsd=%v: %+v", mlsd, file)
+		}
+		if mlsd && !files["a.txt"].ModTime.Equal(fakeFTPModTime) {
+			t.Errorf("Wrong MLSD time %v", files["a.txt"].ModTime)
+		}
+		if used := containsString(server.received(), "MLSD ."); used != mlsd {
+			t.Errorf("MLSD used=%v when supported=%v", used, mlsd)
+		}
+
+		// Absolute paths
+		if paths := keys(walkPaths(t, client, "/dir", FTPWalkOptions{})); !reflect.DeepEqual(paths, []string{"/dir/b.txt", "/dir/sub/c.txt"}) {
+			t.Errorf("Wrong absolute paths: %v", paths)
+		}
+
+		// Max depth
+		if paths := keys(walkPaths(t, client, ".", FTPWalkOptions{MaxDepth: 1})); !reflect.DeepEqual(paths, []string{"a.txt", "dir/b.txt", "elsewhere/d.txt"}) {
+			t.Errorf("Not obeying max depth: %v", paths)
+		}
+
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestFTPWalkSymlinks(t *testing.T) {
+	server := &fakeFTPServer{
+		Files: walkTestingFiles,
+		Links: map[string]string{
+			"/dir/loop":     "/dir",          // Cycle
+			"/dir/up":       "..",            // Cycle through a relative link
+			"/dir/link.txt": "sub/c.txt",     // File
+			"/dir/other":    "/elsewhere",    // Directory
+			"/dir/broken":   "/missing/file", // Broken
+		},
+	}
+	client := connectFakeFTP(t, server, FTPOptions{})
+	defer server.Close()
+	defer client.Close()
+
+	if paths := keys(walkPaths(t, client, "/dir", FTPWalkOptions{})); !reflect.DeepEqual(paths, []string{"/dir/b.txt", "/dir/sub/c.txt"}) {
+		t.Errorf("Should skip symlinks: %v", paths)
+	}
+
+	files := walkPaths(t, client, "/dir", FTPWalkOptions{FollowSymlinks: true})
+	if paths := keys(files); !reflect.DeepEqual(paths, []string{"/dir/b.txt", "/dir/link.txt", "/dir/other/d.txt", "/dir/sub/c.txt", "/dir/up/a.txt"}) {
+		t.Errorf("Wrong paths following symlinks: %v", paths)
+	}
+	if file := files["/dir/link.txt"]; file.Size != 6 || file.LinkTarget != "sub/c.txt" {
+		t.Errorf("Wrong linked file %+v", file)
+	}
+}
+
+func TestFTPNestedRead(t *testing.T) {
+	server := &fakeFTPServer{Files: walkTestingFiles}
+	client := connectFakeFTP(t, server, FTPOptions{Concurrency: 1})
+	defer server.Close()
+	defer client.Close()
+
+	files, err := client.GetAllFilesInFolder(context.Background(), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for file := range files {
+		paths = append(paths, file)
+	}
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "elsewhere/d.txt"}) {
+		t.Errorf("Wrong paths %v", paths)
+	}
+
+	matched, err := client.GetFilesMatchingRules(context.Background(), []*regexp.Regexp{regexp.MustCompile("secret")}, 1024, 100)
+	if err != nil || !reflect.DeepEqual(matched, []string{"dir/sub/c.txt"}) {
+		t.Errorf("Wrong matched files %v %v", matched, err)
+	}
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil || string(data) != "secretbbdddda" {
+		t.Errorf("Wrong data %q %v", data, err)
+	}
+}
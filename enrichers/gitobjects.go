@@ -0,0 +1,396 @@
+package enrichers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Git object types as stored in pack files
+const (
+	gitCommit   = 1
+	gitTree     = 2
+	gitBlob     = 3
+	gitTag      = 4
+	gitOfsDelta = 6
+	gitRefDelta = 7
+)
+
+var gitTypeNames = map[int]string{
+	gitCommit: "commit",
+	gitTree:   "tree",
+	gitBlob:   "blob",
+	gitTag:    "tag",
+}
+
+var errMissingDeltaBase = errors.New("missing delta base")
+var errGitObjectTooLarge = errors.New("git object too large")
+
+// gitObject A decompressed git object
+type gitObject struct {
+	objectType string // commit, tree, blob, or tag
+	data       []byte
+}
+
+// gitTreeEntry An entry in a git tree object
+type gitTreeEntry struct {
+	mode string
+	name string
+	hash string
+}
+
+// gitHash Get the hash git would give this object
+func gitHash(objectType string, data []byte) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s %d\x00", objectType, len(data))
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// parseLooseObject Parse a zlib compressed loose object such as .git/objects/ab/cdef...
+func parseLooseObject(compressed []byte) (*gitObject, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := readGitObjectData(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header is "<type> <size>\x00"
+	null := bytes.IndexByte(data, 0)
+	if null == -1 {
+		return nil, errors.New("invalid object header")
+	}
+	header := strings.SplitN(string(data[0:null]), " ", 2)
+	if len(header) != 2 {
+		return nil, errors.New("invalid object header")
+	}
+	size, err := strconv.Atoi(header[1])
+	if err != nil || size != len(data)-null-1 {
+		return nil, errors.New("invalid object size")
+	}
+
+	return &gitObject{objectType: header[0], data: data[null+1:]}, nil
+}
+
+// packEntry An object in a pack file before deltas are resolved
+type packEntry struct {
+	objectType int
+	data       []byte
+	baseOffset int64  // Offset of the base object for ofs deltas
+	baseHash   string // Hash of the base object for ref deltas
+}
+
+// parsePack Parse every object in a pack file.  Ref deltas with bases outside the pack are resolved with getBase
+func parsePack(pack []byte, getBase func(hash string) *gitObject) (map[string]*gitObject, error) {
+	if len(pack) < 12 || string(pack[0:4]) != "PACK" {
+		return nil, errors.New("not a pack file")
+	}
+	if version := binary.BigEndian.Uint32(pack[4:8]); version != 2 && version != 3 {
+		return nil, errors.New("unsupported pack version")
+	}
+	count := binary.BigEndian.Uint32(pack[8:12])
+
+	// Read every entry
+	entries := map[int64]*packEntry{}
+	offsets := []int64{}
+	reader := bytes.NewReader(pack)
+	reader.Seek(12, io.SeekStart)
+	for i := uint32(0); i < count; i++ {
+		offset := int64(len(pack) - reader.Len())
+		entry, err := readPackEntry(reader, offset)
+		if err != nil {
+			return nil, err
+		}
+		entries[offset] = entry
+		offsets = append(offsets, offset)
+	}
+
+	// Resolve deltas.  Ref delta bases may come later in the pack, so keep going while we make progress
+	objects := map[string]*gitObject{}
+	resolved := map[int64]*gitObject{}
+	useGetBase := false
+	var resolve func(offset int64, depth int) (*gitObject, error)
+	resolve = func(offset int64, depth int) (*gitObject, error) {
+		if object, ok := resolved[offset]; ok {
+			return object, nil
+		}
+		entry, ok := entries[offset]
+		if !ok {
+			return nil, errMissingDeltaBase
+		}
+		if depth >= maxDepth {
+			return nil, errors.New("max delta depth exceeded")
+		}
+
+		var base *gitObject
+		switch entry.objectType {
+		case gitOfsDelta:
+			var err error
+			if base, err = resolve(entry.baseOffset, depth+1); err != nil {
+				return nil, err
+			}
+		case gitRefDelta:
+			base = objects[entry.baseHash]
+			if base == nil && useGetBase && getBase != nil {
+				base = getBase(entry.baseHash)
+			}
+			if base == nil {
+				return nil, errMissingDeltaBase
+			}
+		default:
+			object := &gitObject{objectType: gitTypeNames[entry.objectType], data: entry.data}
+			resolved[offset] = object
+			return object, nil
+		}
+
+		data, err := applyDelta(base.data, entry.data)
+		if err != nil {
+			return nil, err
+		}
+		object := &gitObject{objectType: base.objectType, data: data}
+		resolved[offset] = object
+		return object, nil
+	}
+
+	pending := offsets
+	for len(pending) > 0 {
+		unresolved := []int64{}
+		for _, offset := range pending {
+			object, err := resolve(offset, 0)
+			if err == errMissingDeltaBase {
+				unresolved = append(unresolved, offset)
+				continue
+			} else if err != nil {
+				// Skip objects we can not rebuild
+				continue
+			}
+			objects[gitHash(object.objectType, object.data)] = object
+		}
+
+		if len(unresolved) == len(pending) {
+			if useGetBase {
+				break
+			}
+			// Look outside the pack for the rest
+			useGetBase = true
+		}
+		pending = unresolved
+	}
+
+	return objects, nil
+}
+
+// readPackEntry Read a single entry of a pack file starting at offset
+func readPackEntry(reader *bytes.Reader, offset int64) (*packEntry, error) {
+	// Type and size header
+	b, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	entry := &packEntry{objectType: int(b>>4) & 7}
+	for b&0x80 != 0 {
+		if b, err = reader.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch entry.objectType {
+	case gitOfsDelta:
+		// Offset is big endian with an added 1 for every continuation byte
+		b, err = reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		negative := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = reader.ReadByte(); err != nil {
+				return nil, err
+			}
+			negative = ((negative + 1) << 7) | int64(b&0x7f)
+		}
+		entry.baseOffset = offset - negative
+	case gitRefDelta:
+		hash := make([]byte, 20)
+		if _, err := io.ReadFull(reader, hash); err != nil {
+			return nil, err
+		}
+		entry.baseHash = hex.EncodeToString(hash)
+	case gitCommit, gitTree, gitBlob, gitTag:
+	default:
+		return nil, fmt.Errorf("unknown pack object type %d", entry.objectType)
+	}
+
+	// Data is zlib compressed.  bytes.Reader is a ByteReader so zlib does not read past the end of the stream
+	zlibReader, err := zlib.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	entry.data, err = readGitObjectData(zlibReader)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, zlibReader.Close()
+}
+
+// readGitObjectData Read decompressed object data, failing if there is more than maxGitObjectSize
+func readGitObjectData(reader io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, maxGitObjectSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxGitObjectSize {
+		return nil, errGitObjectTooLarge
+	}
+	return data, nil
+}
+
+// applyDelta Rebuild an object from its base and a git delta
+func applyDelta(base, delta []byte) ([]byte, error) {
+	reader := bytes.NewReader(delta)
+	readSize := func() (int, error) {
+		var size uint64
+		for shift := uint(0); shift < 64; shift += 7 {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			size |= uint64(b&0x7f) << shift
+			if b&0x80 == 0 {
+				if size > maxGitObjectSize {
+					return 0, errGitObjectTooLarge
+				}
+				return int(size), nil
+			}
+		}
+		return 0, errors.New("invalid delta size")
+	}
+
+	baseSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != len(base) {
+		return nil, errors.New("delta base size mismatch")
+	}
+	resultSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, resultSize)
+	for reader.Len() > 0 {
+		instruction, _ := reader.ReadByte()
+		if instruction&0x80 != 0 {
+			// Copy from base
+			offset, size := 0, 0
+			for i := uint(0); i < 4; i++ {
+				if instruction&(1<<i) != 0 {
+					b, err := reader.ReadByte()
+					if err != nil {
+						return nil, err
+					}
+					offset |= int(b) << (8 * i)
+				}
+			}
+			for i := uint(0); i < 3; i++ {
+				if instruction&(1<<(4+i)) != 0 {
+					b, err := reader.ReadByte()
+					if err != nil {
+						return nil, err
+					}
+					size |= int(b) << (8 * i)
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) {
+				return nil, errors.New("delta copy out of range")
+			}
+			if len(result)+size > resultSize {
+				return nil, errors.New("delta result size mismatch")
+			}
+			result = append(result, base[offset:offset+size]...)
+		} else if instruction != 0 {
+			// Insert new data
+			if len(result)+int(instruction) > resultSize {
+				return nil, errors.New("delta result size mismatch")
+			}
+			data := make([]byte, instruction)
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return nil, err
+			}
+			result = append(result, data...)
+		} else {
+			return nil, errors.New("invalid delta instruction")
+		}
+	}
+
+	if len(result) != resultSize {
+		return nil, errors.New("delta result size mismatch")
+	}
+	return result, nil
+}
+
+// parseTree Parse the entries of a tree object
+func parseTree(data []byte) ([]gitTreeEntry, error) {
+	entries := []gitTreeEntry{}
+	for len(data) > 0 {
+		// "<mode> <name>\x00<20 byte hash>"
+		space := bytes.IndexByte(data, ' ')
+		null := bytes.IndexByte(data, 0)
+		if space == -1 || null == -1 || space > null || len(data) < null+21 {
+			return nil, errors.New("invalid tree")
+		}
+		entries = append(entries, gitTreeEntry{
+			mode: string(data[0:space]),
+			name: string(data[space+1 : null]),
+			hash: hex.EncodeToString(data[null+1 : null+21]),
+		})
+		data = data[null+21:]
+	}
+	return entries, nil
+}
+
+// parseCommit Parse a commit object
+func parseCommit(hash string, data []byte) GitCommit {
+	commit := GitCommit{Hash: hash}
+
+	headerEnd := bytes.Index(data, []byte("\n\n"))
+	headers := data
+	if headerEnd != -1 {
+		headers = data[0:headerEnd]
+		commit.Message = string(data[headerEnd+2:])
+	}
+	for _, line := range strings.Split(string(headers), "\n") {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "tree":
+			commit.Tree = parts[1]
+		case "parent":
+			commit.Parents = append(commit.Parents, parts[1])
+		case "author":
+			commit.Author = parts[1]
+		case "committer":
+			commit.Committer = parts[1]
+		}
+	}
+
+	return commit
+}
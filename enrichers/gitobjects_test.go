@@ -0,0 +1,208 @@
+package enrichers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// testGitObject Object to write in to a fixture repository
+type testGitObject struct {
+	objectType string
+	data       []byte
+}
+
+func (object testGitObject) hash() string {
+	return gitHash(object.objectType, object.data)
+}
+
+func zlibCompress(data []byte) []byte {
+	compressed := &bytes.Buffer{}
+	writer := zlib.NewWriter(compressed)
+	writer.Write(data)
+	writer.Close()
+	return compressed.Bytes()
+}
+
+// looseObject Encode an object how it is stored in .git/objects
+func looseObject(object testGitObject) []byte {
+	return zlibCompress(append([]byte(fmt.Sprintf("%s %d\x00", object.objectType, len(object.data))), object.data...))
+}
+
+// testTree Encode a tree from name to entry
+func testTree(entries ...gitTreeEntry) testGitObject {
+	data := &bytes.Buffer{}
+	for _, entry := range entries {
+		hash, _ := hex.DecodeString(entry.hash)
+		fmt.Fprintf(data, "%s %s\x00", entry.mode, entry.name)
+		data.Write(hash)
+	}
+	return testGitObject{"tree", data.Bytes()}
+}
+
+// testCommit Encode a commit
+func testCommit(tree, parent, message string) testGitObject {
+	data := "tree " + tree + "\n"
+	if parent != "" {
+		data += "parent " + parent + "\n"
+	}
+	data += "author Test <test@example.com> 1576800000 +0000\ncommitter Test <test@example.com> 1576800000 +0000\n\n" + message
+	return testGitObject{"commit", []byte(data)}
+}
+
+// testDelta Encode a delta that copies the first copyLen bytes of base and inserts the rest of result
+func testDelta(base, result []byte, copyLen int) []byte {
+	delta := &bytes.Buffer{}
+	writeSize := func(size int) {
+		for size >= 0x80 {
+			delta.WriteByte(byte(size&0x7f) | 0x80)
+			size >>= 7
+		}
+		delta.WriteByte(byte(size))
+	}
+	writeSize(len(base))
+	writeSize(len(result))
+
+	// Copy with offset 0 and 1 byte size
+	delta.Write([]byte{0x80 | 0x10, byte(copyLen)})
+	rest := result[copyLen:]
+	for len(rest) > 0 {
+		n := len(rest)
+		if n > 0x7f {
+			n = 0x7f
+		}
+		delta.WriteByte(byte(n))
+		delta.Write(rest[0:n])
+		rest = rest[n:]
+	}
+	return delta.Bytes()
+}
+
+// testPackEntry An entry to write in to a fixture pack
+type testPackEntry struct {
+	objectType int
+	data       []byte
+	baseIndex  int    // Index of the base entry for ofs deltas
+	baseHash   string // Hash of the base for ref deltas
+}
+
+// testPack Encode a pack file
+func testPack(entries ...testPackEntry) []byte {
+	pack := &bytes.Buffer{}
+	pack.WriteString("PACK")
+	binary.Write(pack, binary.BigEndian, uint32(2))
+	binary.Write(pack, binary.BigEndian, uint32(len(entries)))
+
+	offsets := []int{}
+	for i, entry := range entries {
+		offsets = append(offsets, pack.Len())
+
+		// Type and size
+		size := len(entry.data)
+		b := byte(entry.objectType<<4) | byte(size&0x0f)
+		size >>= 4
+		for size > 0 {
+			pack.WriteByte(b | 0x80)
+			b = byte(size & 0x7f)
+			size >>= 7
+		}
+		pack.WriteByte(b)
+
+		switch entry.objectType {
+		case gitOfsDelta:
+			negative := offsets[i] - offsets[entry.baseIndex]
+			encoded := []byte{byte(negative & 0x7f)}
+			for negative >>= 7; negative > 0; negative >>= 7 {
+				negative--
+				encoded = append([]byte{byte(0x80 | negative&0x7f)}, encoded...)
+			}
+			pack.Write(encoded)
+		case gitRefDelta:
+			hash, _ := hex.DecodeString(entry.baseHash)
+			pack.Write(hash)
+		}
+		pack.Write(zlibCompress(entry.data))
+	}
+
+	return pack.Bytes()
+}
+
+func TestParsePack(t *testing.T) {
+	base := testGitObject{"blob", bytes.Repeat([]byte("base data "), 20)}
+	ofsResult := testGitObject{"blob", append(append([]byte{}, base.data[0:50]...), []byte("changed by ofs delta")...)}
+	refResult := testGitObject{"blob", append(append([]byte{}, base.data[0:30]...), []byte("changed by ref delta")...)}
+	external := testGitObject{"blob", []byte("object outside the pack")}
+	externalResult := testGitObject{"blob", []byte("object outside with more")}
+
+	pack := testPack(
+		// Ref delta before its base
+		testPackEntry{objectType: gitRefDelta, data: testDelta(base.data, refResult.data, 30), baseHash: base.hash()},
+		testPackEntry{objectType: gitBlob, data: base.data},
+		testPackEntry{objectType: gitOfsDelta, data: testDelta(base.data, ofsResult.data, 50), baseIndex: 1},
+		testPackEntry{objectType: gitRefDelta, data: testDelta(external.data, externalResult.data, 14), baseHash: external.hash()},
+	)
+
+	objects, err := parsePack(pack, func(hash string) *gitObject {
+		if hash == external.hash() {
+			return &gitObject{external.objectType, external.data}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []testGitObject{base, ofsResult, refResult, externalResult} {
+		object, ok := objects[want.hash()]
+		if !ok {
+			t.Errorf("Missing object %q", want.data)
+			continue
+		}
+		if !bytes.Equal(object.data, want.data) {
+			t.Errorf("Wanted %q got %q", want.data, object.data)
+		}
+	}
+}
+
+func TestParseLooseObject(t *testing.T) {
+	blob := testGitObject{"blob", []byte("hello")}
+	object, err := parseLooseObject(looseObject(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.objectType != "blob" || string(object.data) != "hello" {
+		t.Errorf("Did not parse loose object")
+	}
+	if blob.hash() != "b6fc4c620b67d95f953a5c1c1230aaab5db5a1b0" {
+		t.Errorf("Wrong hash %s", blob.hash())
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello world")
+	// Base size 11, result size 8, copy 5 bytes from 6, insert "!!!"
+	delta := []byte{11, 8, 0x80 | 0x01 | 0x10, 6, 5, 3, '!', '!', '!'}
+	result, err := applyDelta(base, delta)
+	if err != nil || string(result) != "world!!!" {
+		t.Errorf("Wrong result %q %v", result, err)
+	}
+
+	malformed := map[string][]byte{
+		"endless size":       append(bytes.Repeat([]byte{0xff}, 20), 0x01),
+		"huge result":        {11, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+		"wrong base size":    {10, 8, 0x80 | 0x01 | 0x10, 6, 5},
+		"copy past base":     {11, 8, 0x80 | 0x01 | 0x10, 8, 5},
+		"copy past result":   {11, 2, 0x80 | 0x01 | 0x10, 6, 5},
+		"insert past result": {11, 2, 3, '!', '!', '!'},
+		"short result":       {11, 8, 0x80 | 0x01 | 0x10, 6, 5},
+		"missing insert":     {11, 8, 3, '!'},
+	}
+	for name, delta := range malformed {
+		if _, err := applyDelta(base, delta); err == nil {
+			t.Errorf("Applied %s", name)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package enrichers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	maxExternalScriptSize = 10 * 1024 * 1024 // Max size of an external script to download
+	minBase64BlobLength   = 40               // Min length of a base64 string to try to decode
+)
+
+// HTMLExtractOptions Parts of each web page to read as separate items.  Each is named after the page URL,
+// such as http://host/page#text, except external scripts which are named by their own URL
+type HTMLExtractOptions struct {
+	Text            bool // Visible text without tags or entities (#text)
+	Scripts         bool // Each inline <script> (#script-N)
+	ExternalScripts bool // Each <script src> downloaded
+	Comments        bool // Each HTML comment (#comment-N)
+	Forms           bool // Every form and input with names, types, and values (#forms)
+	Encoded         bool // Decoded data: URIs (#data-N) and base64 blobs that decode to text (#base64-N)
+}
+
+// dataURIRegex Matches data: URIs
+var dataURIRegex = regexp.MustCompile(`data:([a-zA-Z0-9!#$&^_.+-]+/[a-zA-Z0-9!#$&^_.+-]+)?((?:;[a-zA-Z0-9_-]+=[^;,"'\s]*)*)(;base64)?,([^"'\s)<>]*)`)
+
+// base64BlobRegex Matches long base64 strings
+var base64BlobRegex = regexp.MustCompile(fmt.Sprintf(`[A-Za-z0-9+/_-]{%d,}={0,2}`, minBase64BlobLength))
+
+// childItem Create an item derived from parent named like parent#part
+func childItem(parent *Item, part string, data []byte) *Item {
+	return &Item{
+		Name:     parent.Name + "#" + part,
+		Data:     data,
+		Metadata: map[string]string{"parent": parent.Name},
+	}
+}
+
+// extractItems Get the parts of the page as items derived from the page item
+func (client *HTTPClient) extractItems(ctx context.Context, page *httpPage, pageItem *Item, options HTMLExtractOptions) []*Item {
+	items := []*Item{}
+
+	if page.isHTML() {
+		document, err := html.Parse(bytes.NewReader(page.body))
+		if err == nil {
+			if options.Text {
+				items = append(items, childItem(pageItem, "text", htmlText(document)))
+			}
+			if options.Scripts || options.ExternalScripts {
+				items = append(items, client.scriptItems(ctx, page, pageItem, document, options)...)
+			}
+			if options.Comments {
+				for i, comment := range htmlComments(document) {
+					items = append(items, childItem(pageItem, fmt.Sprintf("comment-%d", i+1), []byte(comment)))
+				}
+			}
+			if options.Forms {
+				if forms := htmlForms(document); len(forms) > 0 {
+					items = append(items, childItem(pageItem, "forms", forms))
+				}
+			}
+		}
+	}
+
+	if options.Encoded {
+		items = append(items, encodedItems(pageItem, page.body)...)
+	}
+
+	return items
+}
+
+// htmlText Get visible text of a document.  Block elements are put on their own lines
+func htmlText(document *html.Node) []byte {
+	text := &bytes.Buffer{}
+	line := &strings.Builder{}
+	endLine := func() {
+		if words := strings.Fields(line.String()); len(words) > 0 {
+			text.WriteString(strings.Join(words, " "))
+			text.WriteString("\n")
+		}
+		line.Reset()
+	}
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			line.WriteString(node.Data)
+			return
+		case html.ElementNode:
+			switch node.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Head:
+				// Title is the only visible part of head
+				if node.DataAtom == atom.Head {
+					for child := node.FirstChild; child != nil; child = child.NextSibling {
+						if child.DataAtom == atom.Title {
+							walk(child)
+							endLine()
+						}
+					}
+				}
+				return
+			}
+		}
+
+		block := node.Type == html.ElementNode && !isInlineElement(node.DataAtom)
+		if block {
+			endLine()
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if block {
+			endLine()
+		}
+	}
+	walk(document)
+	endLine()
+
+	return text.Bytes()
+}
+
+// isInlineElement Check if an element is displayed inline with the text around it
+func isInlineElement(element atom.Atom) bool {
+	switch element {
+	case atom.A, atom.Abbr, atom.B, atom.Bdi, atom.Bdo, atom.Cite, atom.Code, atom.Data, atom.Dfn, atom.Em,
+		atom.I, atom.Kbd, atom.Label, atom.Mark, atom.Q, atom.S, atom.Samp, atom.Small, atom.Span, atom.Strong,
+		atom.Sub, atom.Sup, atom.Time, atom.U, atom.Var, atom.Font:
+		return true
+	}
+	return false
+}
+
+// scriptItems Get inline and external scripts of the document
+func (client *HTTPClient) scriptItems(ctx context.Context, page *httpPage, pageItem *Item, document *html.Node, options HTMLExtractOptions) []*Item {
+	items := []*Item{}
+	inline := 0
+
+	for _, script := range findElements(document, atom.Script) {
+		src := htmlAttr(script, "src")
+		if src == "" {
+			if !options.Scripts || script.FirstChild == nil {
+				continue
+			}
+			inline++
+			data := &bytes.Buffer{}
+			for child := script.FirstChild; child != nil; child = child.NextSibling {
+				data.WriteString(child.Data)
+			}
+			items = append(items, childItem(pageItem, fmt.Sprintf("script-%d", inline), data.Bytes()))
+			continue
+		}
+
+		if !options.ExternalScripts {
+			continue
+		}
+		scriptURL, err := page.finalURL().Parse(strings.TrimSpace(src))
+		if err != nil || (scriptURL.Scheme != "http" && scriptURL.Scheme != "https") {
+			continue
+		}
+		scriptPage, err := client.fetch(ctx, scriptURL, maxExternalScriptSize)
+		if err != nil || scriptPage.response.StatusCode != 200 {
+			continue
+		}
+		items = append(items, &Item{
+			Name:     scriptURL.String(),
+			Data:     scriptPage.body,
+			Metadata: map[string]string{"parent": pageItem.Name},
+		})
+	}
+
+	return items
+}
+
+// htmlComments Get every comment in the document
+func htmlComments(document *html.Node) []string {
+	comments := []string{}
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.CommentNode && strings.TrimSpace(node.Data) != "" {
+			comments = append(comments, node.Data)
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(document)
+
+	return comments
+}
+
+// htmlForms List every form and the inputs in it, one per line.  Inputs outside of forms are listed last
+func htmlForms(document *html.Node) []byte {
+	forms := &bytes.Buffer{}
+	writeInput := func(input *html.Node) {
+		fmt.Fprintf(forms, "  %s name=%q type=%q value=%q\n", input.Data, htmlAttr(input, "name"), htmlAttr(input, "type"), htmlAttr(input, "value"))
+	}
+
+	inForm := map[*html.Node]bool{}
+	for _, form := range findElements(document, atom.Form) {
+		fmt.Fprintf(forms, "form action=%q method=%q\n", htmlAttr(form, "action"), htmlAttr(form, "method"))
+		for _, input := range findElements(form, atom.Input, atom.Textarea, atom.Select, atom.Button) {
+			inForm[input] = true
+			writeInput(input)
+		}
+	}
+
+	other := []*html.Node{}
+	for _, input := range findElements(document, atom.Input, atom.Textarea, atom.Select) {
+		if !inForm[input] {
+			other = append(other, input)
+		}
+	}
+	if len(other) > 0 {
+		forms.WriteString("inputs outside of forms\n")
+		for _, input := range other {
+			writeInput(input)
+		}
+	}
+
+	return forms.Bytes()
+}
+
+// encodedItems Decode data: URIs and base64 blobs in the data
+func encodedItems(parent *Item, data []byte) []*Item {
+	items := []*Item{}
+
+	// data: URIs
+	for i, match := range dataURIRegex.FindAllSubmatch(data, -1) {
+		var decoded []byte
+		if len(match[3]) > 0 {
+			decoded = decodeBase64(match[4])
+		} else if unescaped, err := url.PathUnescape(string(match[4])); err == nil {
+			decoded = []byte(unescaped)
+		}
+		if len(decoded) > 0 {
+			item := childItem(parent, fmt.Sprintf("data-%d", i+1), decoded)
+			if len(match[1]) > 0 {
+				item.Metadata["content-type"] = string(match[1])
+			}
+			items = append(items, item)
+		}
+	}
+
+	// base64 blobs outside of data: URIs that decode to text
+	withoutURIs := dataURIRegex.ReplaceAll(data, nil)
+	blob := 0
+	for _, match := range base64BlobRegex.FindAll(withoutURIs, -1) {
+		decoded := decodeBase64(match)
+		if len(decoded) == 0 || !isMostlyText(decoded) {
+			continue
+		}
+		blob++
+		items = append(items, childItem(parent, fmt.Sprintf("base64-%d", blob), decoded))
+	}
+
+	return items
+}
+
+// decodeBase64 Decode standard or url base64 with or without padding.  nil if it is not valid
+func decodeBase64(data []byte) []byte {
+	s := strings.TrimRight(string(data), "=")
+	for _, encoding := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err := encoding.DecodeString(s); err == nil {
+			return decoded
+		}
+	}
+	return nil
+}
+
+// isMostlyText Check if data is valid utf8 with few control characters
+func isMostlyText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	control := 0
+	for _, r := range string(data) {
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' {
+			control++
+		}
+	}
+	return control*20 < len(data)
+}
+
+// findElements Get every element of the types under the node in document order
+func findElements(node *html.Node, elements ...atom.Atom) []*html.Node {
+	found := []*html.Node{}
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			for _, element := range elements {
+				if node.DataAtom == element {
+					found = append(found, node)
+					break
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	return found
+}
+
+// htmlAttr Get value of an attribute of the element
+func htmlAttr(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
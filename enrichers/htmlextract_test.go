@@ -0,0 +1,73 @@
+package enrichers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const extractTestingPage = `<!DOCTYPE html><html><head><title>Login &amp; more</title>
+<style>body { color: red; }</style>
+<script>var apiKey = "AKIA1234";</script>
+<script src="/app.js"></script>
+</head><body>
+<!-- TODO: remove admin password hunter2 -->
+<h1>Welcome</h1><p>Please <b>log in</b>.</p>
+<form action="/login" method="post"><input type="hidden" name="csrf" value="abc"><input name="user"></form>
+<input name="search">
+<img src="data:text/plain;base64,c2VjcmV0IGRhdGEgdXJp">
+<div data-config="eyJwYXNzd29yZCI6ICJodW50ZXIyIiwgInVzZXIiOiAiYWRtaW4ifQ=="></div>
+</body></html>`
+
+func TestExtract(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/app.js" {
+			fmt.Fprint(w, `const token = "ghp_secret";`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, extractTestingPage)
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPWithOptions(server.URL+"/", HTTPOptions{Extract: &HTMLExtractOptions{
+		Text: true, Scripts: true, ExternalScripts: true, Comments: true, Forms: true, Encoded: true,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := client.Items(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for item := range items {
+		got[strings.TrimPrefix(item.Name, server.URL)] = string(item.Data)
+	}
+
+	tests := map[string]string{
+		"/#text":      "Login & more\nWelcome\nPlease log in.\n",
+		"/#script-1":  `var apiKey = "AKIA1234";`,
+		"/app.js":     `const token = "ghp_secret";`,
+		"/#comment-1": " TODO: remove admin password hunter2 ",
+		"/#forms": "form action=\"/login\" method=\"post\"\n" +
+			"  input name=\"csrf\" type=\"hidden\" value=\"abc\"\n" +
+			"  input name=\"user\" type=\"\" value=\"\"\n" +
+			"inputs outside of forms\n" +
+			"  input name=\"search\" type=\"\" value=\"\"\n",
+		"/#data-1":   "secret data uri",
+		"/#base64-1": `{"password": "hunter2", "user": "admin"}`,
+	}
+	for name, want := range tests {
+		if got[name] != want {
+			t.Errorf("Wrong %s, wanted %q got %q", name, want, got[name])
+		}
+	}
+	if len(got) != len(tests)+1 {
+		t.Errorf("Wrong number of items, got %v", got)
+	}
+}
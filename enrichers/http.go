@@ -1,25 +1,72 @@
 package enrichers
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 )
 
 // HTTPClient HTTP Client
 type HTTPClient struct {
 	url          *url.URL
+	options      HTTPOptions
+	httpClient   *http.Client
+	page         *httpPage
 	reader       io.ReadCloser
-	response     *http.Response
 	readerCtx    context.Context
 	readerCancel context.CancelFunc
 }
 
+// DefaultHTTPMaxBodySize Bytes of the body of each page read when HTTPOptions.MaxBodySize is not set
+const DefaultHTTPMaxBodySize = 64 * 1024 * 1024
+
+// HTTPOptions Options for the HTTP client
+type HTTPOptions struct {
+	HTTPConfig // Auth, headers, TLS, redirects, proxy, etc
+
+	DumpFormat HTTPDumpFormat // Format of each page when dumping or reading.  Defaults to HTTPDumpRaw
+
+	// MaxBodySize Bytes of the body of the URL and of each crawled page to read.  Longer pages are cut and have
+	// truncated in their metadata.  0 for DefaultHTTPMaxBodySize, -1 for unlimited
+	MaxBodySize int64
+
+	Crawl   *CrawlOptions   // Crawl same-origin links instead of only reading the given URL.  nil to disable
+	Listing *ListingOptions // Read every file if the URL is an open directory listing.  nil to disable
+	Git     bool            // Read every file of an exposed .git folder next to the URL
+	Probe   *ProbeOptions   // Request well known sensitive paths and read every real hit.  nil to disable
+
+	Fingerprint bool // Also read the HTTPEnrichment of the URL as JSON (url#fingerprint)
+
+	Extract *HTMLExtractOptions // Also read parts of each page such as visible text and scripts as separate items.  nil to disable
+
+	Archives  *ArchiveOptions // Read the entries of archives and compressed files instead of their bytes.  nil to disable
+	Documents bool            // Also read the text of Office, OpenDocument, and PDF files (url#text)
+	SQLDumps  bool            // Read .sql, .sql.gz, mysqldump, and pg_dump files as a JSON item per row (url#database.table)
+	SQLite    *SQLLimits      // Read SQLite database files as a JSON item per row (url#table) within these limits.  nil to disable
+}
+
+// httpPage A fetched web page
+type httpPage struct {
+	url       *url.URL
+	response  *http.Response
+	body      []byte
+	truncated bool // The body is longer than what was read
+}
+
 // NewHTTP Create new HTTP client
 func NewHTTP(urlString string) (*HTTPClient, error) {
-	client := &HTTPClient{}
+	return NewHTTPWithOptions(urlString, HTTPOptions{})
+}
+
+// NewHTTPWithOptions Create new HTTP client with options
+func NewHTTPWithOptions(urlString string, options HTTPOptions) (*HTTPClient, error) {
+	client := &HTTPClient{options: options}
 
 	// Parse URL
 	var err error
@@ -28,19 +75,28 @@ func NewHTTP(urlString string) (*HTTPClient, error) {
 		return nil, err
 	}
 
+	client.httpClient, err = newHTTPClient(options.HTTPConfig, client.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Listing != nil {
+		if err := options.Listing.Filter.validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	return client, nil
 }
 
-// Connect and open reader
+// Connect and fetch the page, up to the max body size of the options
 func (client *HTTPClient) Connect(ctx context.Context) error {
-	req, err := http.NewRequest("GET", client.url.String(), nil)
-	req = req.WithContext(ctx)
-	response, err := http.DefaultClient.Do(req)
+	page, err := client.fetch(ctx, client.url, client.maxBodySize())
 	if err != nil {
 		return err
 	}
 
-	client.response = response
+	client.page = page
 	return nil
 }
 
@@ -59,9 +115,9 @@ func (client *HTTPClient) GetConnectString() string {
 	return client.url.String()
 }
 
-// IsConnected Is server connected.  Will attempt to open a connection
+// IsConnected Is server connected
 func (client *HTTPClient) IsConnected() bool {
-	return client.reader != nil
+	return client.page != nil
 }
 
 // Type Returns HTTP
@@ -78,12 +134,14 @@ func (client *HTTPClient) Close() error {
 		if err != nil {
 			return err
 		}
+		client.reader = nil
 	}
 
+	client.page = nil
 	return nil
 }
 
-// Read headers, cookies, then body
+// Read every page in the dump format
 func (client *HTTPClient) Read(p []byte) (n int, err error) {
 	if client.reader == nil {
 		err = client.ResetReader()
@@ -108,53 +166,325 @@ func (client *HTTPClient) ResetReader() error {
 
 	// Start new reader
 	client.readerCtx, client.readerCancel = context.WithCancel(context.Background())
-	reader, err := client.Dump(client.readerCtx)
+	items, err := client.Items(client.readerCtx)
 	if err != nil {
 		return err
 	}
 
-	client.reader = reader
+	client.reader = itemsReader(client.readerCtx, items)
 
 	return nil
 }
 
 // -- HTTP specific functions ---
 
-type httpResponse struct {
-	response *http.Response
+// GetDump Get structured dump of the page
+func (client *HTTPClient) GetDump(ctx context.Context) (*HTTPDump, error) {
+	page, err := client.getPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.dump(), nil
 }
 
-// Dump headers, cookies, then body
-func (client *HTTPClient) Dump(ctx context.Context) (io.ReadCloser, error) {
-	dumpReader, dumpWriter := io.Pipe()
+// GetTLSInfo Get details of the TLS connection and certificates the page came over.  ErrNoTLS if it was not https
+func (client *HTTPClient) GetTLSInfo(ctx context.Context) (*TLSInfo, error) {
+	page, err := client.getPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if page.response.TLS == nil {
+		return nil, ErrNoTLS
+	}
+
+	return newTLSInfo(page.response.TLS), nil
+}
+
+// Size Get the size of the page from its Content-Length along with every file in the directory listing if reading
+// listings.  Incomplete when crawling, reading .git folders, or probing, as the pages they find are not counted
+func (client *HTTPClient) Size(ctx context.Context) (*ServerSize, error) {
+	bodySize, complete, err := client.bodySize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	size := &ServerSize{Bytes: bodySize, Items: 1, Complete: complete && client.options.Crawl == nil && !client.options.Git && client.options.Probe == nil}
+
+	if client.options.Listing != nil {
+		page, err := client.getPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if page.isListing() {
+			listing, err := client.listingSize(ctx, *client.options.Listing)
+			if err != nil {
+				return nil, err
+			}
+			size.Bytes += listing.Bytes
+			size.Items += listing.Items
+			size.Complete = size.Complete && listing.Complete
+		}
+	}
+
+	return size, nil
+}
+
+// bodySize Get the size of the body of the URL without reading it when we can.  Asks with HEAD, then with a GET of
+// its first byte, and only counts the bytes of the body, up to the max body size, if neither gives its length
+func (client *HTTPClient) bodySize(ctx context.Context) (size uint64, complete bool, err error) {
+	if page := client.page; page != nil {
+		if page.response.ContentLength >= 0 {
+			return uint64(page.response.ContentLength), true, nil
+		}
+		return uint64(len(page.body)), !page.truncated, nil
+	}
+
+	req, err := http.NewRequest("HEAD", client.url.String(), nil)
+	if err != nil {
+		return 0, false, err
+	}
+	response, err := client.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, false, err
+	}
+	response.Body.Close()
+	if response.StatusCode < 400 && response.ContentLength >= 0 {
+		return uint64(response.ContentLength), true, nil
+	}
+
+	req, err = http.NewRequest("GET", client.url.String(), nil)
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	response, err = client.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, false, err
+	}
+	defer response.Body.Close()
+	if total, ok := contentRangeTotal(response); ok {
+		return total, true, nil
+	}
+	if response.StatusCode != http.StatusPartialContent && response.ContentLength >= 0 {
+		return uint64(response.ContentLength), true, nil
+	}
+
+	// The server ignored the range and did not say the length
+	var body io.Reader = response.Body
+	maxBytes := client.maxBodySize()
+	if maxBytes >= 0 {
+		body = io.LimitReader(body, maxBytes+1)
+	}
+	read, _ := io.Copy(ioutil.Discard, body)
+	if maxBytes >= 0 && read > maxBytes {
+		return uint64(maxBytes), false, ctx.Err()
+	}
+	return uint64(read), true, ctx.Err()
+}
+
+// contentRangeTotal Get the full length from the Content-Range of a partial response like bytes 0-0/1234
+func contentRangeTotal(response *http.Response) (uint64, bool) {
+	if response.StatusCode != http.StatusPartialContent {
+		return 0, false
+	}
+	contentRange := response.Header.Get("Content-Range")
+	slash := strings.LastIndex(contentRange, "/")
+	if !strings.HasPrefix(contentRange, "bytes ") || slash == -1 {
+		return 0, false
+	}
+	total, err := strconv.ParseUint(contentRange[slash+1:], 10, 64)
+	return total, err == nil
+}
+
+// Items Get every page as an item named by its URL, followed by the fingerprint of the URL and every file
+// in the directory listing, exposed .git folder, and probed paths if enabled.
+// Only the given URL is read unless crawling is enabled.  Every item has its content-type, encoding, and entropy in
+// its metadata, and text is converted to UTF-8
+func (client *HTTPClient) Items(ctx context.Context) (chan *Item, error) {
+	pages, err := client.pageItems(ctx)
+	if err != nil {
+		return nil, err
+	}
 
+	items := make(chan *Item)
 	go func() {
-		defer dumpWriter.Close()
-
-		// TODO: stop on cancel
-
-		// Write headers
-		for name, values := range client.response.Header {
-			dumpWriter.Write([]byte(name))
-			dumpWriter.Write([]byte(":"))
-			for i, value := range values {
-				dumpWriter.Write([]byte(value))
-				if i != len(values)-1 {
-					dumpWriter.Write([]byte(","))
+		defer close(items)
+
+		for page := range pages {
+			select {
+			case items <- page:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if client.options.Fingerprint {
+			if item, err := client.fingerprintItem(ctx); err == nil {
+				select {
+				case items <- item:
+				case <-ctx.Done():
+					return
 				}
 			}
 		}
 
-		// Write cookies
-		for _, cookie := range client.response.Cookies() {
-			dumpWriter.Write([]byte(cookie.Name))
-			dumpWriter.Write([]byte(":"))
-			dumpWriter.Write([]byte(cookie.Value))
+		if client.options.Listing != nil {
+			client.listingItems(ctx, *client.options.Listing, items)
+		}
+		if client.options.Git {
+			client.gitItems(ctx, items)
+		}
+		if client.options.Probe != nil {
+			client.probeItems(ctx, *client.options.Probe, items)
+		}
+	}()
+
+	return expandItems(ctx, items, client.expandOptions()), nil
+}
+
+// pageItems Get the page at the URL, or every crawled page if crawling is enabled
+func (client *HTTPClient) pageItems(ctx context.Context) (chan *Item, error) {
+	if client.options.Crawl != nil {
+		return client.Crawl(ctx, *client.options.Crawl)
+	}
+
+	page, err := client.getPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(chan *Item)
+	go func() {
+		defer close(items)
+
+		for _, item := range client.itemsForPage(ctx, page) {
+			select {
+			case items <- item:
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
+
+	return items, nil
+}
+
+// Dump the page in the dump format
+func (client *HTTPClient) Dump(ctx context.Context) (io.ReadCloser, error) {
+	page, err := client.getPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dumpReader, dumpWriter := io.Pipe()
 
-		// write body
-		io.Copy(dumpWriter, client.response.Body)
+	go func() {
+		dumpWriter.CloseWithError(page.writeDump(dumpWriter, client.options.DumpFormat))
 	}()
 
 	return dumpReader, nil
 }
+
+// getPage Get the page fetched on connect, fetching it if we are not connected
+func (client *HTTPClient) getPage(ctx context.Context) (*httpPage, error) {
+	if client.page == nil {
+		if err := client.Connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return client.page, nil
+}
+
+// maxBodySize Get the bytes of each page to read.  -1 for unlimited
+func (client *HTTPClient) maxBodySize() int64 {
+	switch {
+	case client.options.MaxBodySize == 0:
+		return DefaultHTTPMaxBodySize
+	case client.options.MaxBodySize < 0:
+		return -1
+	default:
+		return client.options.MaxBodySize
+	}
+}
+
+// fetch GET a url and read up to maxBytes of the body.  -1 for unlimited
+func (client *HTTPClient) fetch(ctx context.Context, u *url.URL, maxBytes int64) (*httpPage, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	response, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	// Read a byte more to tell if the body was cut
+	var body io.Reader = response.Body
+	if maxBytes >= 0 {
+		body = io.LimitReader(body, maxBytes+1)
+	}
+	page := &httpPage{url: u, response: response}
+	page.body, err = ioutil.ReadAll(body)
+	if err != nil && len(page.body) == 0 {
+		return nil, err
+	}
+	if maxBytes >= 0 && int64(len(page.body)) > maxBytes {
+		page.body = page.body[:maxBytes]
+		page.truncated = true
+	}
+
+	return page, nil
+}
+
+// itemsForPage Get the item for the page followed by the parts extracted from it
+func (client *HTTPClient) itemsForPage(ctx context.Context, page *httpPage) []*Item {
+	item := client.pageItem(page)
+	if client.options.Extract == nil {
+		return []*Item{item}
+	}
+
+	return append([]*Item{item}, client.extractItems(ctx, page, item, *client.options.Extract)...)
+}
+
+// expandOptions How to expand the items read
+func (client *HTTPClient) expandOptions() expandOptions {
+	return expandOptions{archives: client.options.Archives, documents: client.options.Documents, sqlDumps: client.options.SQLDumps, sqlite: client.options.SQLite}
+}
+
+// pageItem Convert page to an item named by its URL
+func (client *HTTPClient) pageItem(page *httpPage) *Item {
+	item := &Item{
+		Name:     page.url.String(),
+		Data:     page.body,
+		Metadata: map[string]string{"status": strconv.Itoa(page.response.StatusCode)},
+	}
+	if page.truncated {
+		item.Metadata["truncated"] = "true"
+	}
+	// Keep archives, documents, and dumps as they are to be unpacked or read
+	if (client.options.Archives != nil && ArchiveFormat(page.body) != "") || (client.options.Documents && DocumentFormat(page.body) != "") {
+		return item
+	}
+	if client.options.SQLDumps && (IsSQLDump(item.Name, page.body) || isCompressedSQLDump(item.Name, page.body)) {
+		return item
+	}
+	if client.options.SQLite != nil && IsSQLite(page.body) {
+		return item
+	}
+
+	// The dump is text, describe the body instead and dump it as UTF-8
+	info := SniffContent(item.Name, page.body)
+	setContentMetadata(item, info)
+	if info.IsText() {
+		transcoded := *page
+		transcoded.body = TranscodeUTF8(page.body, info.Encoding)
+		page = &transcoded
+	}
+	dump := &bytes.Buffer{}
+	page.writeDump(dump, client.options.DumpFormat)
+	item.Data = dump.Bytes()
+	return item
+}
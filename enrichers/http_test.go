@@ -1,6 +1,7 @@
 package enrichers
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -40,3 +41,35 @@ func TestNewHTTP(t *testing.T) {
 		t.Errorf("Did not read data correctly")
 	}
 }
+
+func TestHTTPMaxBodySize(t *testing.T) {
+	server := testingServer()
+	defer server.Close()
+
+	tests := []struct {
+		maxBodySize int64
+		body        string
+		truncated   string
+	}{
+		{0, "Data", ""},
+		{-1, "Data", ""},
+		{4, "Data", ""},
+		{2, "Da", "true"},
+	}
+	for _, test := range tests {
+		client, err := NewHTTPWithOptions(server.URL, HTTPOptions{MaxBodySize: test.maxBodySize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		items, err := client.Items(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		item := <-items
+		for range items {
+		}
+		if !strings.HasSuffix(string(item.Data), "\n\n"+test.body) || item.Metadata["truncated"] != test.truncated {
+			t.Errorf("Wrong item with max body size %d: %q %v", test.maxBodySize, item.Data, item.Metadata)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package enrichers
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultHTTPTimeout Timeout of each request when HTTPConfig.Timeout is not set
+	DefaultHTTPTimeout = time.Minute
+	// DefaultUserAgent User-Agent sent when HTTPConfig.UserAgent is not set
+	DefaultUserAgent = "genericenricher"
+)
+
+// HTTPConfig Settings for making HTTP requests.  Used by every client that talks HTTP (HTTP, ELK)
+type HTTPConfig struct {
+	// Auth.  Username and password default to the ones in the URL
+	Username      string
+	Password      string
+	UseDigestAuth bool   // Answer digest challenges with the username and password instead of sending basic auth
+	BearerToken   string // Sent as "Authorization: Bearer <token>"
+
+	Headers   http.Header    // Extra headers sent with every request
+	UserAgent string         // Empty for DefaultUserAgent
+	CookieJar http.CookieJar // Jar to keep cookies between requests.  nil to not keep cookies
+
+	MaxRedirects int // Max number of redirects to follow.  0 for the default of 10, -1 to not follow redirects
+
+	// TLS.  TLSConfig is used as is when set, otherwise one is built from the other fields
+	TLSConfig          *tls.Config
+	InsecureSkipVerify bool
+	RootCAs            *x509.CertPool
+	ClientCertificates []tls.Certificate
+
+	Timeout         time.Duration // Timeout of each request including reading the body.  0 for DefaultHTTPTimeout, -1 for none
+	Proxy           string        // Proxy URL such as http://proxy:8080 or socks5://proxy:1080.  Empty for no proxy
+	MaxResponseSize int64         // Max number of body bytes to read from each response.  0 for unlimited
+}
+
+// newHTTPClient Build an http.Client from the config.  Credentials are only sent to the host in u and the allowedHosts,
+// such as the other nodes of a cluster, so they are not leaked to hosts we are redirected to
+func newHTTPClient(config HTTPConfig, u *url.URL, allowedHosts []string) (*http.Client, error) {
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	// TLS
+	if config.TLSConfig != nil {
+		transport.TLSClientConfig = config.TLSConfig
+	} else {
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: config.InsecureSkipVerify,
+			RootCAs:            config.RootCAs,
+			Certificates:       config.ClientCertificates,
+		}
+	}
+
+	// Proxy
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	// Credentials from the URL
+	if u.User != nil && config.Username == "" && config.Password == "" {
+		config.Username = u.User.Username()
+		config.Password, _ = u.User.Password()
+	}
+
+	hosts := map[string]bool{u.Host: true}
+	for _, host := range allowedHosts {
+		hosts[host] = true
+	}
+	client := &http.Client{
+		Transport: &configTransport{config: config, hosts: hosts, transport: transport},
+		Jar:       config.CookieJar,
+	}
+
+	// Timeout
+	switch {
+	case config.Timeout == 0:
+		client.Timeout = DefaultHTTPTimeout
+	case config.Timeout > 0:
+		client.Timeout = config.Timeout
+	}
+
+	// Redirects
+	if config.MaxRedirects != 0 {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if config.MaxRedirects < 0 {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > config.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", config.MaxRedirects)
+			}
+			return nil
+		}
+	}
+
+	return client, nil
+}
+
+// configTransport Adds auth and headers to each request and caps the size of each response
+type configTransport struct {
+	config    HTTPConfig
+	hosts     map[string]bool // Only send credentials to these hosts
+	transport http.RoundTripper
+}
+
+// RoundTrip Send a request
+func (t *configTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Do not modify the caller's request
+	req = cloneRequest(req)
+
+	for name, values := range t.config.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if t.config.UserAgent != "" {
+		req.Header.Set("User-Agent", t.config.UserAgent)
+	} else if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", DefaultUserAgent)
+	}
+
+	sendCredentials := t.hosts[req.URL.Host] && req.Header.Get("Authorization") == ""
+	if sendCredentials {
+		switch {
+		case t.config.BearerToken != "":
+			req.Header.Set("Authorization", "Bearer "+t.config.BearerToken)
+		case !t.config.UseDigestAuth && (t.config.Username != "" || t.config.Password != ""):
+			req.SetBasicAuth(t.config.Username, t.config.Password)
+		}
+	}
+
+	response, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Answer digest challenge
+	challenge := response.Header.Get("WWW-Authenticate")
+	if sendCredentials && t.config.UseDigestAuth && response.StatusCode == http.StatusUnauthorized && strings.HasPrefix(strings.ToLower(challenge), "digest ") {
+		retry := cloneRequest(req)
+		if req.Body != nil && req.GetBody != nil {
+			if retry.Body, err = req.GetBody(); err != nil {
+				return response, nil
+			}
+		} else if req.Body != nil {
+			// Can not send the body again
+			return response, nil
+		}
+		authorization, err := digestAuthorization(challenge, req.Method, req.URL.RequestURI(), t.config.Username, t.config.Password)
+		if err != nil {
+			return response, nil
+		}
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+
+		retry.Header.Set("Authorization", authorization)
+		if response, err = t.transport.RoundTrip(retry); err != nil {
+			return nil, err
+		}
+	}
+
+	// Cap response size
+	if t.config.MaxResponseSize > 0 {
+		response.Body = &limitedReadCloser{io.LimitReader(response.Body, t.config.MaxResponseSize), response.Body}
+	}
+
+	return response, nil
+}
+
+// cloneRequest Shallow copy of a request with its own headers
+func cloneRequest(req *http.Request) *http.Request {
+	clone := new(http.Request)
+	*clone = *req
+	clone.Header = make(http.Header, len(req.Header))
+	for name, values := range req.Header {
+		clone.Header[name] = append([]string{}, values...)
+	}
+	return clone
+}
+
+// limitedReadCloser Reads from a limited reader and closes the underlying body
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// digestAuthorization Build the Authorization header answering a digest challenge (RFC 7616)
+func digestAuthorization(challenge, method, uri, username, password string) (string, error) {
+	params := parseAuthParams(challenge[len("digest "):])
+	realm, nonce := params["realm"], params["nonce"]
+	if nonce == "" {
+		return "", errors.New("digest challenge without nonce")
+	}
+
+	var newHash func() hash.Hash
+	algorithm := params["algorithm"]
+	switch strings.ToUpper(algorithm) {
+	case "", "MD5", "MD5-SESS":
+		newHash = md5.New
+	case "SHA-256", "SHA-256-SESS":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	h := func(data string) string {
+		hasher := newHash()
+		io.WriteString(hasher, data)
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	random := make([]byte, 8)
+	rand.Read(random)
+	cnonce := hex.EncodeToString(random)
+	nc := "00000001"
+
+	ha1 := h(username + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	// Only qop=auth is supported, fall back to the legacy response without qop
+	qop := ""
+	for _, option := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(option) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`, username, realm, nonce, uri)
+	if qop != "" {
+		response := h(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+		authorization += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s", response="%s"`, qop, nc, cnonce, response)
+	} else {
+		authorization += fmt.Sprintf(`, response="%s"`, h(ha1+":"+nonce+":"+ha2))
+	}
+	if algorithm != "" {
+		authorization += ", algorithm=" + algorithm
+	}
+	if opaque, ok := params["opaque"]; ok {
+		authorization += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+
+	return authorization, nil
+}
+
+// parseAuthParams Parse comma separated key=value or key="value" auth parameters
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		equals := strings.Index(s, "=")
+		if equals == -1 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[0:equals]))
+		s = strings.TrimLeft(s[equals+1:], " ")
+
+		value := ""
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end == -1 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if comma := strings.Index(s, ","); comma != -1 {
+			value, s = strings.TrimSpace(s[0:comma]), s[comma:]
+		} else {
+			value, s = strings.TrimSpace(s), ""
+		}
+		params[key] = value
+	}
+	return params
+}
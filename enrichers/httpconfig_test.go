@@ -0,0 +1,254 @@
+package enrichers
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer Writes back the auth, user agent, and custom header of each request
+func echoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "auth=%s ua=%s custom=%s", r.Header.Get("Authorization"), r.Header.Get("User-Agent"), r.Header.Get("X-Custom"))
+	}))
+}
+
+func fetchBody(t *testing.T, urlString string, config HTTPConfig) (string, error) {
+	client, err := NewHTTPWithOptions(urlString, HTTPOptions{HTTPConfig: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := client.fetch(context.Background(), client.url, -1)
+	if err != nil {
+		return "", err
+	}
+	return string(page.body), nil
+}
+
+func TestHTTPConfigHeaders(t *testing.T) {
+	server := echoServer()
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	tests := []struct {
+		url    string
+		config HTTPConfig
+		body   string
+	}{
+		{server.URL, HTTPConfig{}, "auth= ua=genericenricher custom="},
+		{server.URL, HTTPConfig{UserAgent: "agent", Headers: http.Header{"X-Custom": {"value"}}}, "auth= ua=agent custom=value"},
+		{server.URL, HTTPConfig{Username: "user", Password: "pass"}, "auth=Basic dXNlcjpwYXNz ua=genericenricher custom="},
+		{"http://user:pass@" + serverURL.Host, HTTPConfig{}, "auth=Basic dXNlcjpwYXNz ua=genericenricher custom="},
+		{server.URL, HTTPConfig{BearerToken: "token"}, "auth=Bearer token ua=genericenricher custom="},
+	}
+
+	for i, test := range tests {
+		body, err := fetchBody(t, test.url, test.config)
+		if err != nil {
+			t.Errorf("Test %d failed: %v", i, err)
+			continue
+		}
+		if body != test.body {
+			t.Errorf("Test %d failed, wanted %q got %q", i, test.body, body)
+		}
+	}
+}
+
+func TestHTTPConfigDigestAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := parseAuthParams(strings.TrimPrefix(r.Header.Get("Authorization"), "Digest "))
+		h := func(s string) string {
+			sum := md5.Sum([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+		ha1 := h("user:realm:pass")
+		ha2 := h(r.Method + ":" + params["uri"])
+		if params["nonce"] != "abc" || params["response"] != h(ha1+":abc:"+params["nc"]+":"+params["cnonce"]+":auth:"+ha2) {
+			w.Header().Set("WWW-Authenticate", `Digest realm="realm", qop="auth,auth-int", nonce="abc", opaque="xyz"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "authorized")
+	}))
+	defer server.Close()
+
+	body, err := fetchBody(t, server.URL+"/path?q=1", HTTPConfig{Username: "user", Password: "pass", UseDigestAuth: true})
+	if err != nil || body != "authorized" {
+		t.Errorf("Digest auth failed, got %q %v", body, err)
+	}
+	body, _ = fetchBody(t, server.URL, HTTPConfig{Username: "user", Password: "wrong", UseDigestAuth: true})
+	if body == "authorized" {
+		t.Errorf("Digest auth should fail with wrong password")
+	}
+}
+
+func TestHTTPConfigRedirects(t *testing.T) {
+	// Server on another host that records auth
+	other := echoServer()
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1":
+			http.Redirect(w, r, "/2", http.StatusFound)
+		case "/2":
+			http.Redirect(w, r, "/3", http.StatusFound)
+		case "/other":
+			http.Redirect(w, r, other.URL, http.StatusFound)
+		case "/cookie":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+			http.Redirect(w, r, "/check", http.StatusFound)
+		case "/check":
+			cookie, _ := r.Cookie("session")
+			fmt.Fprint(w, cookie)
+		default:
+			fmt.Fprint(w, "end")
+		}
+	}))
+	defer server.Close()
+
+	if body, err := fetchBody(t, server.URL+"/1", HTTPConfig{}); err != nil || body != "end" {
+		t.Errorf("Did not follow redirects")
+	}
+	if _, err := fetchBody(t, server.URL+"/1", HTTPConfig{MaxRedirects: 1}); err == nil {
+		t.Errorf("Not obeying max redirects")
+	}
+	if body, _ := fetchBody(t, server.URL+"/1", HTTPConfig{MaxRedirects: -1}); body == "end" {
+		t.Errorf("Should not follow redirects")
+	}
+
+	// Credentials should not leak to other hosts
+	if body, _ := fetchBody(t, server.URL+"/other", HTTPConfig{Username: "user", Password: "pass"}); !strings.HasPrefix(body, "auth= ") {
+		t.Errorf("Sent credentials to another host: %q", body)
+	}
+
+	// Cookie jar
+	jar, _ := cookiejar.New(nil)
+	if body, _ := fetchBody(t, server.URL+"/cookie", HTTPConfig{CookieJar: jar}); body != "session=abc" {
+		t.Errorf("Did not keep cookies, got %q", body)
+	}
+}
+
+func TestHTTPConfigLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(time.Millisecond * 200)
+		}
+		fmt.Fprint(w, strings.Repeat("a", 1000))
+	}))
+	defer server.Close()
+
+	if body, _ := fetchBody(t, server.URL, HTTPConfig{MaxResponseSize: 10}); len(body) != 10 {
+		t.Errorf("Not obeying response size cap, got %d bytes", len(body))
+	}
+	if _, err := fetchBody(t, server.URL+"/slow", HTTPConfig{Timeout: time.Millisecond * 50}); err == nil {
+		t.Errorf("Not obeying timeout")
+	}
+}
+
+func TestHTTPConfigTLSAndProxy(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secure")
+	}))
+	defer server.Close()
+
+	if _, err := fetchBody(t, server.URL, HTTPConfig{}); err == nil {
+		t.Errorf("Should not trust self signed certificate")
+	}
+	if body, err := fetchBody(t, server.URL, HTTPConfig{InsecureSkipVerify: true}); err != nil || body != "secure" {
+		t.Errorf("Did not skip verification: %v", err)
+	}
+
+	// HTTP proxy receives the full URL
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "proxied "+r.URL.String())
+	}))
+	defer proxy.Close()
+	if body, err := fetchBody(t, "http://example.invalid/page", HTTPConfig{Proxy: proxy.URL}); err != nil || body != "proxied http://example.invalid/page" {
+		t.Errorf("Did not use proxy, got %q %v", body, err)
+	}
+
+	if _, err := NewHTTPWithOptions("http://localhost", HTTPOptions{HTTPConfig: HTTPConfig{Proxy: "ftp://proxy"}}); err == nil {
+		t.Errorf("Should not accept ftp proxy")
+	}
+}
+
+func TestELKOptions(t *testing.T) {
+	server := echoServer()
+	defer server.Close()
+
+	client, err := NewELKWithOptions(server.URL, ELKOptions{HTTPConfig{BearerToken: "token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if !strings.HasPrefix(string(body), "auth=Bearer token") {
+		t.Errorf("ELK client did not use config, got %q", body)
+	}
+}
+
+func TestELKOptionsOtherNodes(t *testing.T) {
+	node := echoServer()
+	defer node.Close()
+	other := echoServer()
+	defer other.Close()
+	nodeURL, _ := url.Parse(node.URL)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_nodes/http" {
+			fmt.Fprintf(w, `{"nodes":{"a":{"http":{"publish_address":"es2/%s"}}}}`, nodeURL.Host)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// Sniffed nodes of the cluster get the credentials, other hosts do not
+	client, err := NewELKWithOptions(server.URL, ELKOptions{HTTPConfig{Username: "user", Password: "pass"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The cluster is not healthy so connecting gives up when the ctx is done, after sniffing the nodes
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	client.Connect(ctx)
+	for _, test := range []struct {
+		url  string
+		auth bool
+	}{{node.URL, true}, {other.URL, false}} {
+		response, err := client.httpClient.Get(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if strings.HasPrefix(string(body), "auth=Basic dXNlcjpwYXNz") != test.auth {
+			t.Errorf("Wrong credentials sent to %s: %q", test.url, body)
+		}
+	}
+
+	for address, host := range map[string]string{
+		"127.0.0.1:9200":          "127.0.0.1:9200",
+		"es1/10.0.0.1:9200":       "10.0.0.1:9200",
+		"inet[/10.0.0.2:9200]":    "10.0.0.2:9200",
+		"inet[es3/10.0.0.3:9200]": "10.0.0.3:9200",
+		"no port":                 "",
+	} {
+		if got := elkNodeHost(address); got != host {
+			t.Errorf("Wrong host of %s: %s", address, got)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package enrichers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	maxSitemaps = 100 // Max number of sitemaps to fetch when following sitemap indexes
+)
+
+// CrawlOptions Options for crawling a website
+type CrawlOptions struct {
+	MaxDepth      int   // How many links deep to follow from the start page.  0 only reads the start page
+	MaxPages      int   // Max number of pages to fetch.  0 for unlimited
+	MaxBytes      int64 // Max number of body bytes to read across all pages.  0 for unlimited
+	RespectRobots bool  // Skip paths disallowed by /robots.txt
+	UseSitemap    bool  // Also crawl pages listed in /sitemap.xml and sitemaps from /robots.txt
+}
+
+// crawlTarget A url waiting to be crawled
+type crawlTarget struct {
+	url   *url.URL
+	depth int
+}
+
+// Crawl Fetch the start page and follow same-origin links, returning every page as an item named by its URL
+func (client *HTTPClient) Crawl(ctx context.Context, options CrawlOptions) (chan *Item, error) {
+	start := normalizeCrawlURL(client.url)
+	if start.Scheme != "http" && start.Scheme != "https" {
+		return nil, errors.New("can only crawl http and https urls")
+	}
+
+	items := make(chan *Item)
+
+	go func() {
+		defer close(items)
+
+		// Rules from robots.txt, and sitemaps listed in robots.txt
+		robots := &robotsRules{}
+		sitemaps := []*url.URL{}
+		if options.RespectRobots || options.UseSitemap {
+			robots, sitemaps = client.getRobots(ctx, start)
+		}
+		if !options.RespectRobots {
+			robots = &robotsRules{}
+		}
+
+		seen := map[string]bool{start.String(): true}
+		queue := []crawlTarget{{start, 0}}
+
+		// Seed with pages from the sitemaps
+		if options.UseSitemap {
+			sitemaps = append(sitemaps, start.ResolveReference(&url.URL{Path: "/sitemap.xml"}))
+			for _, pageURL := range client.getSitemapURLs(ctx, start, sitemaps) {
+				if !seen[pageURL.String()] {
+					seen[pageURL.String()] = true
+					queue = append(queue, crawlTarget{pageURL, 1})
+				}
+			}
+		}
+
+		pages := 0
+		bytesRead := int64(0)
+		for len(queue) > 0 {
+			target := queue[0]
+			queue = queue[1:]
+
+			// Check limits
+			if options.MaxPages > 0 && pages >= options.MaxPages {
+				return
+			}
+			if options.MaxBytes > 0 && bytesRead >= options.MaxBytes {
+				return
+			}
+			if !robots.allowed(target.url) {
+				continue
+			}
+
+			maxBytes := client.maxBodySize()
+			if options.MaxBytes > 0 && (maxBytes < 0 || options.MaxBytes-bytesRead < maxBytes) {
+				maxBytes = options.MaxBytes - bytesRead
+			}
+			page, err := client.fetch(ctx, target.url, maxBytes)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				continue
+			}
+			pages++
+			bytesRead += int64(len(page.body))
+
+			for _, item := range client.itemsForPage(ctx, page) {
+				select {
+				case items <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			// Queue links on this page
+			if target.depth >= options.MaxDepth {
+				continue
+			}
+			for _, link := range page.links() {
+				link = normalizeCrawlURL(link)
+				if !sameOrigin(start, link) || seen[link.String()] {
+					continue
+				}
+				seen[link.String()] = true
+				queue = append(queue, crawlTarget{link, target.depth + 1})
+			}
+		}
+	}()
+
+	return items, nil
+}
+
+// links Get all links on an html page resolved against the page url
+func (page *httpPage) links() []*url.URL {
+	if !page.isHTML() {
+		return nil
+	}
+
+	base := page.finalURL()
+	links := []*url.URL{}
+	tokenizer := html.NewTokenizer(bytes.NewReader(page.body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			for _, attr := range token.Attr {
+				if !isLinkAttr(token.Data, attr.Key) {
+					continue
+				}
+				if token.Data == "base" {
+					if newBase, err := base.Parse(strings.TrimSpace(attr.Val)); err == nil {
+						base = newBase
+					}
+					continue
+				}
+				if link, err := base.Parse(strings.TrimSpace(attr.Val)); err == nil {
+					links = append(links, link)
+				}
+			}
+		}
+	}
+}
+
+// isHTML Check if the page is html by its content type, or by its content if there is no content type
+func (page *httpPage) isHTML() bool {
+	contentType := page.response.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(page.body)
+	}
+	return strings.Contains(strings.ToLower(contentType), "html")
+}
+
+// finalURL Get url of the page after following redirects
+func (page *httpPage) finalURL() *url.URL {
+	if page.response != nil && page.response.Request != nil && page.response.Request.URL != nil {
+		return page.response.Request.URL
+	}
+	return page.url
+}
+
+// isLinkAttr Check if this tag attribute points to another page
+func isLinkAttr(tag, attr string) bool {
+	switch tag {
+	case "a", "area", "link", "base":
+		return attr == "href"
+	case "frame", "iframe":
+		return attr == "src"
+	case "form":
+		return attr == "action"
+	}
+	return false
+}
+
+// normalizeCrawlURL Strip parts of the url that do not change the page so we do not fetch the same page twice
+func normalizeCrawlURL(u *url.URL) *url.URL {
+	normalized := *u
+	normalized.Fragment = ""
+	normalized.Scheme = strings.ToLower(normalized.Scheme)
+	normalized.Host = strings.ToLower(normalized.Host)
+	if normalized.Path == "" {
+		normalized.Path = "/"
+	}
+	return &normalized
+}
+
+// sameOrigin Check if two urls have the same scheme, host, and port
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && a.Hostname() == b.Hostname() && urlToPort(a) == urlToPort(b)
+}
+
+// -- robots.txt and sitemap.xml --
+
+// robotsRules Allow and disallow rules for all user agents from a robots.txt
+type robotsRules struct {
+	allow    []string
+	disallow []string
+}
+
+// allowed Check if a url is allowed to be crawled.  The longest matching rule wins
+func (rules *robotsRules) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	longestAllow, longestDisallow := -1, -1
+	for _, rule := range rules.allow {
+		if strings.HasPrefix(path, rule) && len(rule) > longestAllow {
+			longestAllow = len(rule)
+		}
+	}
+	for _, rule := range rules.disallow {
+		if strings.HasPrefix(path, rule) && len(rule) > longestDisallow {
+			longestDisallow = len(rule)
+		}
+	}
+
+	return longestDisallow < 0 || longestAllow >= longestDisallow
+}
+
+// getRobots Fetch and parse /robots.txt.  Returns empty rules if there is none
+func (client *HTTPClient) getRobots(ctx context.Context, start *url.URL) (*robotsRules, []*url.URL) {
+	robotsURL := start.ResolveReference(&url.URL{Path: "/robots.txt"})
+	page, err := client.fetch(ctx, robotsURL, 512*1024)
+	if err != nil || page.response.StatusCode != http.StatusOK {
+		return &robotsRules{}, nil
+	}
+
+	return parseRobots(robotsURL, page.body)
+}
+
+// parseRobots Parse the rules for all user agents and the sitemaps out of a robots.txt
+func parseRobots(robotsURL *url.URL, data []byte) (*robotsRules, []*url.URL) {
+	rules := &robotsRules{}
+	sitemaps := []*url.URL{}
+
+	// Whether the current group applies to all user agents
+	inGroup := false
+	// Whether the last line was a user-agent line, as consecutive user-agent lines share a group
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.Index(line, "#"); comment != -1 {
+			line = line[0:comment]
+		}
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[0:colon]))
+		value := strings.TrimSpace(line[colon+1:])
+
+		switch key {
+		case "user-agent":
+			if !lastWasAgent {
+				inGroup = false
+			}
+			if value == "*" {
+				inGroup = true
+			}
+			lastWasAgent = true
+			continue
+		case "allow":
+			if inGroup && value != "" {
+				rules.allow = append(rules.allow, value)
+			}
+		case "disallow":
+			if inGroup && value != "" {
+				rules.disallow = append(rules.disallow, value)
+			}
+		case "sitemap":
+			if sitemap, err := robotsURL.Parse(value); err == nil {
+				sitemaps = append(sitemaps, sitemap)
+			}
+		}
+		lastWasAgent = false
+	}
+
+	return rules, sitemaps
+}
+
+// sitemapXML A sitemap or sitemap index
+type sitemapXML struct {
+	URLs     []string `xml:"url>loc"`
+	Sitemaps []string `xml:"sitemap>loc"`
+}
+
+// getSitemapURLs Get all same-origin page urls listed in the sitemaps, following sitemap indexes
+func (client *HTTPClient) getSitemapURLs(ctx context.Context, start *url.URL, sitemaps []*url.URL) []*url.URL {
+	pages := []*url.URL{}
+	seen := map[string]bool{}
+
+	for len(sitemaps) > 0 && len(seen) < maxSitemaps {
+		sitemapURL := sitemaps[0]
+		sitemaps = sitemaps[1:]
+		if seen[sitemapURL.String()] || !sameOrigin(start, normalizeCrawlURL(sitemapURL)) {
+			continue
+		}
+		seen[sitemapURL.String()] = true
+
+		page, err := client.fetch(ctx, sitemapURL, 10*1024*1024)
+		if err != nil || page.response.StatusCode != http.StatusOK {
+			continue
+		}
+		sitemap := sitemapXML{}
+		if xml.Unmarshal(page.body, &sitemap) != nil {
+			continue
+		}
+
+		for _, loc := range sitemap.URLs {
+			if pageURL, err := sitemapURL.Parse(strings.TrimSpace(loc)); err == nil {
+				pageURL = normalizeCrawlURL(pageURL)
+				if sameOrigin(start, pageURL) {
+					pages = append(pages, pageURL)
+				}
+			}
+		}
+		for _, loc := range sitemap.Sitemaps {
+			if indexURL, err := sitemapURL.Parse(strings.TrimSpace(loc)); err == nil {
+				sitemaps = append(sitemaps, indexURL)
+			}
+		}
+	}
+
+	return pages
+}
@@ -0,0 +1,130 @@
+package enrichers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// crawlTestingServer Serves a small site where /page/N links to /page/N+1 and back to /
+func crawlTestingServer(pages int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch {
+		case r.URL.Path == "/":
+			fmt.Fprint(w, `<html><a href="/page/1">one</a><a href="/page/1#top">one again</a><a href="http://example.com/">offsite</a></html>`)
+		case r.URL.Path == "/robots.txt":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\nSitemap: /sitemap.xml\n")
+		case r.URL.Path == "/sitemap.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprint(w, `<urlset><url><loc>/orphan</loc></url></urlset>`)
+		case strings.HasPrefix(r.URL.Path, "/page/"):
+			var n int
+			fmt.Sscanf(r.URL.Path, "/page/%d", &n)
+			if n > pages {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `<html>page %d<a href="%d">next</a><a href="/">home</a><a href="/private">secret</a></html>`, n, n+1)
+		default:
+			fmt.Fprintf(w, "<html>%s</html>", r.URL.Path)
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func crawledURLs(t *testing.T, server *httptest.Server, options CrawlOptions) []string {
+	client, err := NewHTTP(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := client.Crawl(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := []string{}
+	for item := range items {
+		urls = append(urls, strings.TrimPrefix(item.Name, server.URL))
+	}
+	return urls
+}
+
+func TestCrawl(t *testing.T) {
+	server := crawlTestingServer(5)
+	defer server.Close()
+
+	tests := []struct {
+		options CrawlOptions
+		urls    []string
+	}{
+		{CrawlOptions{MaxDepth: 0}, []string{"/"}},
+		{CrawlOptions{MaxDepth: 2}, []string{"/", "/page/1", "/page/2", "/private"}},
+		{CrawlOptions{MaxDepth: 2, RespectRobots: true}, []string{"/", "/page/1", "/page/2"}},
+		{CrawlOptions{MaxDepth: 100, MaxPages: 3}, []string{"/", "/page/1", "/page/2"}},
+		{CrawlOptions{MaxDepth: 1, UseSitemap: true}, []string{"/", "/orphan", "/page/1"}},
+	}
+
+	for i, test := range tests {
+		urls := crawledURLs(t, server, test.options)
+		if fmt.Sprint(urls) != fmt.Sprint(test.urls) {
+			t.Errorf("Test %d failed, wanted %v got %v", i, test.urls, urls)
+		}
+	}
+
+	// Check byte budget
+	urls := crawledURLs(t, server, CrawlOptions{MaxDepth: 100, MaxBytes: 150})
+	if len(urls) == 0 || len(urls) > 2 {
+		t.Errorf("Not obeying byte budget, got %v", urls)
+	}
+}
+
+func TestCrawlRead(t *testing.T) {
+	server := crawlTestingServer(5)
+	defer server.Close()
+
+	client, err := NewHTTPWithOptions(server.URL, HTTPOptions{Crawl: &CrawlOptions{MaxDepth: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "page 5") {
+		t.Errorf("Did not crawl every page")
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	robotsURL, _ := url.Parse("http://a.com/robots.txt")
+	rules, sitemaps := parseRobots(robotsURL, []byte("User-agent: googlebot\nDisallow: /\n\nUser-agent: other\nUser-agent: *\nDisallow: /admin # comment\nAllow: /admin/public\nSitemap: http://a.com/s.xml"))
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/", true},
+		{"/admin", false},
+		{"/admin/secret", false},
+		{"/admin/public/page", true},
+	}
+	for _, test := range tests {
+		pathURL, _ := url.Parse(test.path)
+		if got := rules.allowed(pathURL); got != test.allowed {
+			t.Errorf("Error on path \"%s\"", test.path)
+		}
+	}
+	if len(sitemaps) != 1 || sitemaps[0].String() != "http://a.com/s.xml" {
+		t.Errorf("Did not parse sitemap, got %v", sitemaps)
+	}
+}
@@ -0,0 +1,136 @@
+package enrichers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+//go:generate stringer -type=HTTPDumpFormat
+
+// HTTPDumpFormat Format of each page when dumping or reading an HTTP server
+type HTTPDumpFormat int
+
+// HTTP dump formats
+const (
+	// HTTPDumpRaw Like the HTTP wire format.  The request line with the final URL, the status line,
+	// one header per line sorted by name, a blank line, then the body
+	HTTPDumpRaw HTTPDumpFormat = iota
+	// HTTPDumpJSON An HTTPDump as JSON
+	HTTPDumpJSON
+)
+
+// HTTPDump Structured dump of a response
+type HTTPDump struct {
+	URL        string       `json:"url"`       // URL requested
+	FinalURL   string       `json:"final_url"` // URL after following redirects
+	Proto      string       `json:"proto"`
+	StatusCode int          `json:"status_code"`
+	Status     string       `json:"status"`
+	Headers    http.Header  `json:"headers"`
+	Cookies    []HTTPCookie `json:"cookies"`
+	TLS        *TLSInfo     `json:"tls,omitempty"`
+	Body       string       `json:"body"`
+}
+
+// HTTPCookie A cookie set by a response, with its attributes
+type HTTPCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+	MaxAge   int       `json:"max_age,omitempty"`
+	Secure   bool      `json:"secure"`
+	HTTPOnly bool      `json:"http_only"`
+	SameSite string    `json:"same_site,omitempty"`
+}
+
+// sameSiteNames Names of SameSite cookie attributes
+var sameSiteNames = map[http.SameSite]string{
+	http.SameSiteDefaultMode: "",
+	http.SameSiteLaxMode:     "Lax",
+	http.SameSiteStrictMode:  "Strict",
+	http.SameSiteNoneMode:    "None",
+}
+
+// dump Get structured dump of the page
+func (page *httpPage) dump() *HTTPDump {
+	response := page.response
+	dump := &HTTPDump{
+		URL:        page.url.String(),
+		FinalURL:   page.finalURL().String(),
+		Proto:      response.Proto,
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Headers:    response.Header,
+		Cookies:    []HTTPCookie{},
+		Body:       string(page.body),
+	}
+
+	for _, cookie := range response.Cookies() {
+		dump.Cookies = append(dump.Cookies, HTTPCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Expires:  cookie.Expires,
+			MaxAge:   cookie.MaxAge,
+			Secure:   cookie.Secure,
+			HTTPOnly: cookie.HttpOnly,
+			SameSite: sameSiteNames[cookie.SameSite],
+		})
+	}
+
+	if response.TLS != nil {
+		dump.TLS = newTLSInfo(response.TLS)
+	}
+
+	return dump
+}
+
+// writeDump Write the page in the format
+func (page *httpPage) writeDump(w io.Writer, format HTTPDumpFormat) error {
+	switch format {
+	case HTTPDumpJSON:
+		return json.NewEncoder(w).Encode(page.dump())
+	case HTTPDumpRaw:
+		return page.writeRawDump(w)
+	default:
+		return fmt.Errorf("unknown dump format %s", format)
+	}
+}
+
+// writeRawDump Write the request line, status line, headers, a blank line, then the body
+func (page *httpPage) writeRawDump(w io.Writer) error {
+	response := page.response
+	method := "GET"
+	if response.Request != nil && response.Request.Method != "" {
+		method = response.Request.Method
+	}
+	if _, err := fmt.Fprintf(w, "%s %s\n%s %s\n", method, page.finalURL(), response.Proto, response.Status); err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range response.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range response.Header[name] {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", name, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := w.Write([]byte("\n")); err != nil {
+		return err
+	}
+	_, err := w.Write(page.body)
+	return err
+}
@@ -0,0 +1,88 @@
+package enrichers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func dumpTestingHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/page", http.StatusFound)
+			return
+		}
+		w.Header()["Date"] = nil
+		w.Header().Set("Server", "nginx")
+		w.Header().Set("Content-Type", "text/html")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+		w.Write([]byte("<html>body</html>"))
+	})
+}
+
+func TestDumpRaw(t *testing.T) {
+	server := httptest.NewServer(dumpTestingHandler())
+	defer server.Close()
+
+	client, err := NewHTTP(server.URL + "/redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := client.Dump(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "GET " + server.URL + "/page\n" +
+		"HTTP/1.1 200 OK\n" +
+		"Content-Length: 17\n" +
+		"Content-Type: text/html\n" +
+		"Server: nginx\n" +
+		"Set-Cookie: session=abc; Path=/; HttpOnly; SameSite=Strict\n" +
+		"\n" +
+		"<html>body</html>"
+	if string(dump) != want {
+		t.Errorf("Wrong dump, wanted:\n%s\ngot:\n%s", want, dump)
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	server := httptest.NewTLSServer(dumpTestingHandler())
+	defer server.Close()
+
+	client, err := NewHTTPWithOptions(server.URL+"/redirect", HTTPOptions{
+		HTTPConfig: HTTPConfig{InsecureSkipVerify: true},
+		DumpFormat: HTTPDumpJSON,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dump := HTTPDump{}
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatal(err)
+	}
+	if dump.URL != server.URL+"/redirect" || dump.FinalURL != server.URL+"/page" {
+		t.Errorf("Wrong URLs %s %s", dump.URL, dump.FinalURL)
+	}
+	if dump.StatusCode != 200 || dump.Headers.Get("Server") != "nginx" || dump.Body != "<html>body</html>" {
+		t.Errorf("Wrong response %+v", dump)
+	}
+	if len(dump.Cookies) != 1 || dump.Cookies[0].Name != "session" || !dump.Cookies[0].HTTPOnly || dump.Cookies[0].SameSite != "Strict" {
+		t.Errorf("Wrong cookies %+v", dump.Cookies)
+	}
+	if dump.TLS == nil || dump.TLS.Version == "" || dump.TLS.CipherSuite == "" || len(dump.TLS.Certificates) == 0 {
+		t.Errorf("Wrong TLS info %+v", dump.TLS)
+	}
+}
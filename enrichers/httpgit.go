@@ -0,0 +1,403 @@
+package enrichers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	maxGitObjectSize = 100 * 1024 * 1024 // Max size of a loose object to download, or of any object once decompressed
+	maxGitPackSize   = 500 * 1024 * 1024 // Max size of a pack file to download
+	maxGitCommits    = 10000             // Max number of commits to walk back through
+)
+
+// GitRepository A git repository reconstructed from an exposed .git folder
+type GitRepository struct {
+	URL     *url.URL          // URL of the .git folder
+	Head    string            // Hash of the commit HEAD points to
+	Refs    map[string]string // Ref name such as refs/heads/master to commit hash
+	Commits []GitCommit       // Every commit we could recover, newest first
+	Files   []GitFile         // Files in the tree of HEAD
+}
+
+// GitCommit A commit recovered from a git repository
+type GitCommit struct {
+	Hash      string
+	Tree      string
+	Parents   []string
+	Author    string
+	Committer string
+	Message   string
+}
+
+// GitFile A file recovered from the tree of a commit
+type GitFile struct {
+	Path string
+	Hash string
+	Mode string
+	Data []byte
+}
+
+// gitHashRegex Matches a full commit hash
+var gitHashRegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// gitWellKnownRefs Refs to try when we can not list the refs folder
+var gitWellKnownRefs = []string{
+	"ORIG_HEAD",
+	"FETCH_HEAD",
+	"refs/heads/master",
+	"refs/heads/main",
+	"refs/heads/develop",
+	"refs/heads/dev",
+	"refs/heads/staging",
+	"refs/heads/production",
+	"refs/remotes/origin/HEAD",
+	"refs/remotes/origin/master",
+	"refs/remotes/origin/main",
+	"refs/stash",
+}
+
+// gitFetcher Downloads and caches objects from an exposed .git folder
+type gitFetcher struct {
+	client      *HTTPClient
+	ctx         context.Context
+	url         *url.URL
+	objects     map[string]*gitObject
+	packsLoaded bool
+	packedRefs  map[string]string
+}
+
+// gitURL Get URL of the .git folder next to the client URL
+func (client *HTTPClient) gitURL() *url.URL {
+	gitURL, _ := client.url.Parse(".git/")
+	return gitURL
+}
+
+// HasExposedGit Check if the server exposes a .git folder by looking for a valid .git/HEAD
+func (client *HTTPClient) HasExposedGit(ctx context.Context) bool {
+	fetcher := &gitFetcher{client: client, ctx: ctx, url: client.gitURL(), objects: map[string]*gitObject{}}
+	_, err := fetcher.readRef("HEAD")
+	return err == nil
+}
+
+// GetGitRepository Download an exposed .git folder and reconstruct the commits and the files at HEAD
+func (client *HTTPClient) GetGitRepository(ctx context.Context) (*GitRepository, error) {
+	fetcher := &gitFetcher{client: client, ctx: ctx, url: client.gitURL(), objects: map[string]*gitObject{}}
+
+	head, err := fetcher.readRef("HEAD")
+	if err != nil {
+		return nil, errors.New("no exposed .git folder")
+	}
+
+	repository := &GitRepository{URL: fetcher.url, Head: head, Refs: fetcher.getRefs()}
+
+	// Walk commits from every ref
+	tips := []string{head}
+	for _, hash := range repository.Refs {
+		tips = append(tips, hash)
+	}
+	repository.Commits = fetcher.getCommits(tips)
+
+	// Files at HEAD
+	headObject := fetcher.getObject(head)
+	if headObject == nil || headObject.objectType != "commit" {
+		return repository, nil
+	}
+	commit := parseCommit(head, headObject.data)
+	repository.Files = fetcher.getFiles(commit.Tree, "", 0)
+
+	return repository, nil
+}
+
+// gitItems Reconstruct an exposed .git folder and send every file at HEAD as an item named like http://host/.git!/path,
+// then every commit as an item named like http://host/.git#commit/hash
+func (client *HTTPClient) gitItems(ctx context.Context, items chan *Item) {
+	repository, err := client.GetGitRepository(ctx)
+	if err != nil {
+		return
+	}
+	gitURL := strings.TrimSuffix(repository.URL.String(), "/")
+
+	for _, file := range repository.Files {
+		item := &Item{
+			Name:     gitURL + "!/" + file.Path,
+			Data:     file.Data,
+			Metadata: map[string]string{"hash": file.Hash, "commit": repository.Head},
+		}
+		select {
+		case items <- item:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	for _, commit := range repository.Commits {
+		item := &Item{
+			Name: gitURL + "#commit/" + commit.Hash,
+			Data: commit.text(),
+			Metadata: map[string]string{
+				"hash":      commit.Hash,
+				"tree":      commit.Tree,
+				"parents":   strings.Join(commit.Parents, ","),
+				"author":    commit.Author,
+				"committer": commit.Committer,
+			},
+		}
+		select {
+		case items <- item:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// text Get the commit as text like git cat-file shows it, starting with its hash
+func (commit *GitCommit) text() []byte {
+	text := &bytes.Buffer{}
+	text.WriteString("commit " + commit.Hash + "\ntree " + commit.Tree + "\n")
+	for _, parent := range commit.Parents {
+		text.WriteString("parent " + parent + "\n")
+	}
+	text.WriteString("author " + commit.Author + "\ncommitter " + commit.Committer + "\n\n" + commit.Message)
+	return text.Bytes()
+}
+
+// fetch Get a file in the .git folder.  Returns an error if it does not exist
+func (fetcher *gitFetcher) fetch(name string, maxBytes int64) ([]byte, error) {
+	fileURL, err := fetcher.url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	page, err := fetcher.client.fetch(fetcher.ctx, fileURL, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if page.response.StatusCode != http.StatusOK {
+		return nil, errors.New(page.response.Status)
+	}
+	return page.body, nil
+}
+
+// readRef Get the commit hash of a ref such as HEAD or refs/heads/master, following symbolic refs
+func (fetcher *gitFetcher) readRef(name string) (string, error) {
+	for depth := 0; depth < 10; depth++ {
+		data, err := fetcher.fetch(name, 4096)
+		if err != nil {
+			return fetcher.readPackedRef(name)
+		}
+
+		value := strings.TrimSpace(string(data))
+		if strings.HasPrefix(value, "ref: ") {
+			name = strings.TrimSpace(strings.TrimPrefix(value, "ref: "))
+			continue
+		}
+		// FETCH_HEAD has extra fields after the hash
+		if fields := strings.Fields(value); len(fields) > 0 && gitHashRegex.MatchString(fields[0]) {
+			return fields[0], nil
+		}
+		return "", errors.New("invalid ref")
+	}
+
+	return "", errors.New("too many symbolic refs")
+}
+
+// readPackedRef Look up a ref in packed-refs
+func (fetcher *gitFetcher) readPackedRef(name string) (string, error) {
+	refs := fetcher.getPackedRefs()
+	if hash, ok := refs[name]; ok {
+		return hash, nil
+	}
+	return "", errors.New("ref not found")
+}
+
+// getPackedRefs Parse .git/packed-refs
+func (fetcher *gitFetcher) getPackedRefs() map[string]string {
+	if fetcher.packedRefs != nil {
+		return fetcher.packedRefs
+	}
+	refs := map[string]string{}
+	fetcher.packedRefs = refs
+
+	data, err := fetcher.fetch("packed-refs", 10*1024*1024)
+	if err != nil {
+		return refs
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && gitHashRegex.MatchString(fields[0]) {
+			refs[fields[1]] = fields[0]
+		}
+	}
+
+	return refs
+}
+
+// getRefs Get every ref we can find from packed-refs, listings of the refs folder, and well known ref names
+func (fetcher *gitFetcher) getRefs() map[string]string {
+	refs := map[string]string{}
+	for name, hash := range fetcher.getPackedRefs() {
+		refs[name] = hash
+	}
+
+	names := append([]string{}, gitWellKnownRefs...)
+	// The refs folder may be an open directory listing
+	if refsURL, err := fetcher.url.Parse("refs/"); err == nil {
+		if entries, err := fetcher.client.WalkListing(fetcher.ctx, refsURL.String()); err == nil {
+			for entry := range entries {
+				if !entry.IsDir {
+					names = append(names, strings.TrimPrefix(entry.URL.Path, fetcher.url.Path))
+				}
+			}
+		}
+	}
+
+	for _, name := range names {
+		if _, ok := refs[name]; ok {
+			continue
+		}
+		if hash, err := fetcher.readRef(name); err == nil {
+			refs[name] = hash
+		}
+	}
+
+	return refs
+}
+
+// getObject Get an object by hash from the loose objects or the packs.  nil if we can not find it
+func (fetcher *gitFetcher) getObject(hash string) *gitObject {
+	if !gitHashRegex.MatchString(hash) {
+		return nil
+	}
+	if object, ok := fetcher.objects[hash]; ok {
+		return object
+	}
+
+	// Loose object
+	if data, err := fetcher.fetch("objects/"+hash[0:2]+"/"+hash[2:], maxGitObjectSize); err == nil {
+		if object, err := parseLooseObject(data); err == nil && gitHash(object.objectType, object.data) == hash {
+			fetcher.objects[hash] = object
+			return object
+		}
+	}
+
+	// Packed object
+	if !fetcher.packsLoaded {
+		fetcher.packsLoaded = true
+		fetcher.loadPacks()
+		return fetcher.objects[hash]
+	}
+
+	return nil
+}
+
+// loadPacks Download every pack listed in objects/info/packs or in a listing of objects/pack/
+func (fetcher *gitFetcher) loadPacks() {
+	packs := []string{}
+	if data, err := fetcher.fetch("objects/info/packs", 1024*1024); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 2 && fields[0] == "P" {
+				packs = append(packs, fields[1])
+			}
+		}
+	}
+	if packURL, err := fetcher.url.Parse("objects/pack/"); err == nil && len(packs) == 0 {
+		if entries, err := fetcher.client.GetListing(fetcher.ctx, packURL.String()); err == nil {
+			for _, entry := range entries {
+				if path.Ext(entry.Name) == ".pack" {
+					packs = append(packs, entry.Name)
+				}
+			}
+		}
+	}
+
+	for _, pack := range packs {
+		data, err := fetcher.fetch("objects/pack/"+path.Base(pack), maxGitPackSize)
+		if err != nil {
+			continue
+		}
+		objects, err := parsePack(data, func(hash string) *gitObject {
+			if object, ok := fetcher.objects[hash]; ok {
+				return object
+			}
+			return fetcher.getObject(hash)
+		})
+		if err != nil {
+			continue
+		}
+		for hash, object := range objects {
+			fetcher.objects[hash] = object
+		}
+	}
+}
+
+// getCommits Walk back through the history of each commit, newest first
+func (fetcher *gitFetcher) getCommits(tips []string) []GitCommit {
+	commits := []GitCommit{}
+	seen := map[string]bool{}
+	queue := tips
+
+	for len(queue) > 0 && len(commits) < maxGitCommits {
+		hash := queue[0]
+		queue = queue[1:]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		object := fetcher.getObject(hash)
+		if object == nil || object.objectType != "commit" {
+			continue
+		}
+		commit := parseCommit(hash, object.data)
+		commits = append(commits, commit)
+		queue = append(queue, commit.Parents...)
+	}
+
+	return commits
+}
+
+// getFiles Get every file in a tree, recursing in to sub trees
+func (fetcher *gitFetcher) getFiles(treeHash, dir string, depth int) []GitFile {
+	if depth >= maxDepth {
+		return nil
+	}
+	object := fetcher.getObject(treeHash)
+	if object == nil || object.objectType != "tree" {
+		return nil
+	}
+	entries, err := parseTree(object.data)
+	if err != nil {
+		return nil
+	}
+
+	files := []GitFile{}
+	for _, entry := range entries {
+		entryPath := path.Join(dir, entry.name)
+		switch entry.mode {
+		case "40000":
+			files = append(files, fetcher.getFiles(entry.hash, entryPath, depth+1)...)
+		case "160000":
+			// Submodule, the commit is in another repository
+		default:
+			blob := fetcher.getObject(entry.hash)
+			if blob == nil || blob.objectType != "blob" {
+				continue
+			}
+			files = append(files, GitFile{Path: entryPath, Hash: entry.hash, Mode: entry.mode, Data: blob.data})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
@@ -0,0 +1,137 @@
+package enrichers
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// gitTestingServer Serves a .git folder with two commits.  The latest commit is loose and the rest is packed
+func gitTestingServer() *httptest.Server {
+	readme1 := testGitObject{"blob", []byte("# Project\n")}
+	readme2 := testGitObject{"blob", []byte("# Project\npassword=hunter2\n")}
+	config := testGitObject{"blob", []byte("API_KEY=abc123\n")}
+	configTree := testTree(gitTreeEntry{"100644", "app.env", config.hash()})
+	tree1 := testTree(gitTreeEntry{"100644", "README.md", readme1.hash()})
+	tree2 := testTree(gitTreeEntry{"100644", "README.md", readme2.hash()}, gitTreeEntry{"40000", "config", configTree.hash()})
+	commit1 := testCommit(tree1.hash(), "", "Initial commit\n")
+	commit2 := testCommit(tree2.hash(), commit1.hash(), "Add config\n")
+
+	files := map[string][]byte{
+		"/.git/HEAD":               []byte("ref: refs/heads/master\n"),
+		"/.git/packed-refs":        []byte("# pack-refs with: peeled fully-peeled sorted\n" + commit2.hash() + " refs/heads/master\n"),
+		"/.git/objects/info/packs": []byte("P pack-test.pack\n\n"),
+		"/.git/objects/pack/pack-test.pack": testPack(
+			testPackEntry{objectType: gitCommit, data: commit1.data},
+			testPackEntry{objectType: gitTree, data: tree1.data},
+			testPackEntry{objectType: gitBlob, data: readme1.data},
+			testPackEntry{objectType: gitOfsDelta, data: testDelta(readme1.data, readme2.data, len(readme1.data)), baseIndex: 2},
+			testPackEntry{objectType: gitTree, data: configTree.data},
+		),
+	}
+	for _, object := range []testGitObject{commit2, tree2, config} {
+		hash := object.hash()
+		files["/.git/objects/"+hash[0:2]+"/"+hash[2:]] = looseObject(object)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+}
+
+func TestGetGitRepository(t *testing.T) {
+	server := gitTestingServer()
+	defer server.Close()
+
+	client, err := NewHTTP(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !client.HasExposedGit(context.Background()) {
+		t.Fatalf("Did not detect .git folder")
+	}
+
+	repository, err := client.GetGitRepository(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Commits
+	if len(repository.Commits) != 2 || repository.Commits[0].Hash != repository.Head {
+		t.Errorf("Did not recover commits, got %+v", repository.Commits)
+	} else if repository.Commits[0].Message != "Add config\n" || repository.Commits[1].Message != "Initial commit\n" {
+		t.Errorf("Did not parse commits, got %+v", repository.Commits)
+	}
+	if repository.Refs["refs/heads/master"] != repository.Head {
+		t.Errorf("Did not recover refs, got %v", repository.Refs)
+	}
+
+	// Files
+	if len(repository.Files) != 2 {
+		t.Fatalf("Did not recover files, got %+v", repository.Files)
+	}
+	if repository.Files[0].Path != "README.md" || string(repository.Files[0].Data) != "# Project\npassword=hunter2\n" {
+		t.Errorf("Did not recover README.md, got %+v", repository.Files[0])
+	}
+	if repository.Files[1].Path != "config/app.env" || string(repository.Files[1].Data) != "API_KEY=abc123\n" {
+		t.Errorf("Did not recover config/app.env, got %+v", repository.Files[1])
+	}
+}
+
+func TestReadGit(t *testing.T) {
+	server := gitTestingServer()
+	defer server.Close()
+
+	client, err := NewHTTPWithOptions(server.URL, HTTPOptions{Git: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := client.Items(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	commits := []*Item{}
+	for item := range items {
+		names = append(names, item.Name)
+		if strings.Contains(item.Name, "#commit/") {
+			commits = append(commits, item)
+		}
+	}
+	if len(names) != 5 || names[1] != server.URL+"/.git!/README.md" || names[2] != server.URL+"/.git!/config/app.env" {
+		t.Errorf("Wrong items, got %v", names)
+	}
+	if len(commits) != 2 || commits[0].Name != server.URL+"/.git#commit/"+commits[0].Metadata["hash"] ||
+		!strings.HasSuffix(string(commits[0].Data), "\n\nAdd config\n") || commits[1].Metadata["parents"] != "" ||
+		commits[0].Metadata["parents"] != commits[1].Metadata["hash"] || commits[0].Metadata["author"] == "" {
+		t.Errorf("Wrong commit items, got %v", commits)
+	}
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "API_KEY=abc123") {
+		t.Errorf("Did not read git files")
+	}
+
+	// No .git folder
+	noGit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>soft 404</html>"))
+	}))
+	defer noGit.Close()
+	client, _ = NewHTTP(noGit.URL)
+	if client.HasExposedGit(context.Background()) {
+		t.Errorf("Should not detect .git folder")
+	}
+}
@@ -0,0 +1,356 @@
+package enrichers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// ListingOptions Options for walking open directory listings such as Apache "Index of /" pages
+type ListingOptions struct {
+	Filter FileFilter // Files to read.  At most Filter.MaxSize bytes are read of files with unknown size
+
+	SizeWalkTime time.Duration // How long Size walks the listing before giving up.  0 for DefaultSizeWalkTime
+}
+
+// ListingEntry A file or folder in an open directory listing
+type ListingEntry struct {
+	URL     *url.URL
+	Name    string
+	IsDir   bool
+	Size    int64     // Size as reported by the listing, -1 if unknown
+	ModTime time.Time // Zero if unknown
+}
+
+// listingRegex Matches pages that are directory listings (Apache, nginx, python http.server, IIS)
+var listingRegex = regexp.MustCompile(`(?i)<title>\s*(index of /|directory listing for /)|<h1>\s*index of /|\[to parent directory\]`)
+
+// listingDateFormats Date formats found in directory listings
+var listingDateFormats = []struct {
+	regex   *regexp.Regexp
+	layouts []string
+}{
+	// Apache
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}(:\d{2})?`), []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02T15:04:05"}},
+	// nginx, older Apache
+	{regexp.MustCompile(`\d{2}-[A-Za-z]{3}-\d{4} \d{2}:\d{2}(:\d{2})?`), []string{"02-Jan-2006 15:04", "02-Jan-2006 15:04:05"}},
+	// IIS
+	{regexp.MustCompile(`[A-Za-z]+, [A-Za-z]+ \d{1,2}, \d{4}\s+\d{1,2}:\d{2}\s*[AP]M`), []string{"Monday, January 2, 2006 3:04 PM"}},
+	{regexp.MustCompile(`\d{1,2}/\d{1,2}/\d{4}\s+\d{1,2}:\d{2}(\s*[AP]M)?`), []string{"1/2/2006 3:04 PM", "1/2/2006 15:04"}},
+}
+
+// listingSizeRegex Matches the size column of a listing such as "1234", "1.2K", "-", or "<dir>"
+var listingSizeRegex = regexp.MustCompile(`(?i)(?:^|\s)(\d+(?:\.\d+)?[KMGT]?|<dir>|-)(?:\s|$)`)
+
+// isListing Check if the page is an open directory listing
+func (page *httpPage) isListing() bool {
+	return page.isHTML() && listingRegex.Match(page.body)
+}
+
+// listingEntries Parse the entries out of a directory listing page
+func (page *httpPage) listingEntries() []ListingEntry {
+	dir := page.finalURL()
+	entries := []ListingEntry{}
+	seen := map[string]bool{}
+
+	for _, row := range listingRows(page.body) {
+		entry, ok := parseListingRow(dir, row)
+		if !ok || seen[entry.URL.String()] {
+			continue
+		}
+		seen[entry.URL.String()] = true
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// listingRow A line or table row of a listing with the first link in it and the text around the links
+type listingRow struct {
+	href string
+	text string
+}
+
+// listingRows Split a listing page in to rows.  Rows end at new lines, <br>, <tr>, and <li>
+func listingRows(body []byte) []listingRow {
+	rows := []listingRow{}
+	row := listingRow{}
+	text := &strings.Builder{}
+	inAnchor := false
+
+	endRow := func() {
+		row.text = strings.Join(strings.Fields(text.String()), " ")
+		if row.href != "" {
+			rows = append(rows, row)
+		}
+		row = listingRow{}
+		text.Reset()
+	}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			endRow()
+			return rows
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "br", "tr", "li":
+				endRow()
+			case "td", "th":
+				text.WriteString(" ")
+			case "a":
+				inAnchor = true
+				for _, attr := range token.Attr {
+					if attr.Key == "href" && row.href == "" && isListingHref(attr.Val) {
+						row.href = attr.Val
+					}
+				}
+			}
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "a":
+				inAnchor = false
+			case "tr", "li":
+				endRow()
+			}
+		case html.TextToken:
+			if inAnchor {
+				continue
+			}
+			lines := strings.Split(string(tokenizer.Text()), "\n")
+			for i, line := range lines {
+				if i > 0 {
+					endRow()
+				}
+				text.WriteString(" ")
+				text.WriteString(line)
+			}
+		}
+	}
+}
+
+// isListingHref Check if a link in a listing could point to an entry, rather than a sort order or the parent folder
+func isListingHref(href string) bool {
+	href = strings.TrimSpace(href)
+	switch {
+	case href == "", href == "../", href == "..", href == "./", href == ".":
+		return false
+	case strings.HasPrefix(href, "?"), strings.HasPrefix(href, "#"):
+		return false
+	case strings.HasPrefix(strings.ToLower(href), "javascript:"), strings.HasPrefix(strings.ToLower(href), "mailto:"):
+		return false
+	}
+	return true
+}
+
+// parseListingRow Parse a row of a listing in to an entry.  Returns false if the row does not point to something in this folder
+func parseListingRow(dir *url.URL, row listingRow) (ListingEntry, bool) {
+	entryURL, err := dir.Parse(strings.TrimSpace(row.href))
+	if err != nil {
+		return ListingEntry{}, false
+	}
+	entryURL.RawQuery = ""
+	entryURL.Fragment = ""
+
+	// Make sure it is directly inside this folder
+	dirPath := dir.Path
+	if !strings.HasSuffix(dirPath, "/") {
+		dirPath += "/"
+	}
+	if !sameOrigin(dir, entryURL) || !strings.HasPrefix(entryURL.Path, dirPath) {
+		return ListingEntry{}, false
+	}
+	relative := strings.TrimPrefix(entryURL.Path, dirPath)
+	if relative == "" || strings.Contains(strings.TrimSuffix(relative, "/"), "/") {
+		return ListingEntry{}, false
+	}
+
+	entry := ListingEntry{
+		URL:   entryURL,
+		Name:  strings.TrimSuffix(relative, "/"),
+		IsDir: strings.HasSuffix(relative, "/"),
+		Size:  -1,
+	}
+
+	// Date
+	text := row.text
+	for _, format := range listingDateFormats {
+		match := format.regex.FindString(text)
+		if match == "" {
+			continue
+		}
+		text = strings.Replace(text, match, " ", 1)
+		match = strings.Join(strings.Fields(match), " ")
+		for _, layout := range format.layouts {
+			if modTime, err := time.Parse(layout, match); err == nil {
+				entry.ModTime = modTime
+				break
+			}
+		}
+		break
+	}
+
+	// Size
+	if match := listingSizeRegex.FindStringSubmatch(text); match != nil {
+		switch size := strings.ToLower(match[1]); {
+		case size == "<dir>":
+			entry.IsDir = true
+		case size == "-":
+		case size[len(size)-1] >= '0' && size[len(size)-1] <= '9':
+			if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+				entry.Size = n
+			}
+		default:
+			entry.Size = int64(stringSizeToUint(size + "b"))
+		}
+	}
+	if entry.IsDir {
+		entry.Size = -1
+	}
+
+	return entry, true
+}
+
+// GetListing Get the entries of a single open directory listing.  dir is resolved against the client URL
+func (client *HTTPClient) GetListing(ctx context.Context, dir string) ([]ListingEntry, error) {
+	dirURL, err := client.url.Parse(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := client.fetch(ctx, dirURL, 10*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	if !page.isListing() {
+		return nil, errors.New("not a directory listing")
+	}
+
+	return page.listingEntries(), nil
+}
+
+// WalkListing Recursively get every file and folder in an open directory listing, including sizes and dates.
+// dir is resolved against the client URL
+func (client *HTTPClient) WalkListing(ctx context.Context, dir string) (chan ListingEntry, error) {
+	entries, err := client.GetListing(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(chan ListingEntry)
+
+	go func() {
+		defer close(ret)
+
+		visited := map[string]bool{}
+		client.walkListingInner(ctx, entries, visited, ret, 0)
+	}()
+
+	return ret, nil
+}
+
+// walkListingInner Send each entry and go recursive on folders.  Returns false if canceled
+func (client *HTTPClient) walkListingInner(ctx context.Context, entries []ListingEntry, visited map[string]bool, ret chan ListingEntry, depth int) bool {
+	for _, entry := range entries {
+		select {
+		case ret <- entry:
+		case <-ctx.Done():
+			return false
+		}
+
+		if !entry.IsDir || visited[entry.URL.String()] || depth+1 >= maxDepth {
+			continue
+		}
+		visited[entry.URL.String()] = true
+
+		subEntries, err := client.GetListing(ctx, entry.URL.String())
+		if err != nil {
+			continue
+		}
+		if !client.walkListingInner(ctx, subEntries, visited, ret, depth+1) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// listingSize Get the total size and number of the files in the listing at the client URL that would be read,
+// walking for up to SizeWalkTime.  Incomplete if some files have no size in the listing
+func (client *HTTPClient) listingSize(ctx context.Context, options ListingOptions) (*ServerSize, error) {
+	walkCtx, cancel := context.WithTimeout(ctx, sizeWalkTime(options.SizeWalkTime))
+	defer cancel()
+
+	entries, err := client.WalkListing(walkCtx, client.url.String())
+	if err != nil {
+		return nil, err
+	}
+
+	size := &ServerSize{Complete: true}
+	for entry := range entries {
+		if entry.IsDir || !options.Filter.Match(entry.URL.Path, entry.Size, entry.ModTime) {
+			continue
+		}
+		size.Items++
+		if entry.Size < 0 {
+			size.Complete = false
+			continue
+		}
+		size.Bytes += uint64(entry.Size)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if walkCtx.Err() != nil {
+		size.Complete = false
+	}
+
+	return size, nil
+}
+
+// listingItems Walk the listing at the client URL and read every file as an item named by its URL
+func (client *HTTPClient) listingItems(ctx context.Context, options ListingOptions, items chan *Item) {
+	entries, err := client.WalkListing(ctx, client.url.String())
+	if err != nil {
+		return
+	}
+
+	for entry := range entries {
+		if entry.IsDir || !options.Filter.Match(entry.URL.Path, entry.Size, entry.ModTime) {
+			continue
+		}
+
+		maxBytes := int64(-1)
+		if options.Filter.MaxSize > 0 {
+			maxBytes = options.Filter.MaxSize
+		}
+		page, err := client.fetch(ctx, entry.URL, maxBytes)
+		if err != nil {
+			continue
+		}
+
+		item := &Item{Name: entry.URL.String(), Data: page.body, Metadata: map[string]string{}}
+		if entry.Size >= 0 {
+			item.Metadata["size"] = strconv.FormatInt(entry.Size, 10)
+		}
+		if !entry.ModTime.IsZero() {
+			item.Metadata["modified"] = entry.ModTime.Format(time.RFC3339)
+		}
+
+		select {
+		case items <- item:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package enrichers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var listingPages = map[string]string{
+	// Apache table
+	"apache": `<html><head><title>Index of /apache</title></head><body><h1>Index of /apache</h1>
+<table>
+<tr><th><img src="/icons/blank.gif" alt="[ICO]"></th><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th><th><a href="?C=S;O=A">Size</a></th></tr>
+<tr><td valign="top"><img src="/icons/back.gif" alt="[PARENTDIR]"></td><td><a href="/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td></tr>
+<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="sub/">sub/</a></td><td align="right">2019-12-19 12:00  </td><td align="right">  - </td></tr>
+<tr><td valign="top"><img src="/icons/text.gif" alt="[TXT]"></td><td><a href="notes.txt">notes.txt</a></td><td align="right">2019-12-18 08:30  </td><td align="right">1.5K</td></tr>
+</table></body></html>`,
+	// nginx
+	"nginx": `<html>
+<head><title>Index of /nginx/</title></head>
+<body>
+<h1>Index of /nginx/</h1><hr><pre><a href="../">../</a>
+<a href="sub/">sub/</a>                                               19-Dec-2019 12:00                   -
+<a href="dump.sql">dump.sql</a>                                           18-Dec-2019 08:30                2048
+</pre><hr></body>
+</html>`,
+	// IIS
+	"iis": `<html><head><title>localhost - /iis/</title></head><body><H1>localhost - /iis/</H1><hr>
+<pre><A HREF="/">[To Parent Directory]</A><br><br>  Thursday, December 19, 2019 12:00 PM        &lt;dir&gt; <A HREF="/iis/sub/">sub</A><br>   12/18/2019  8:30 AM         4096 <A HREF="/iis/web.config">web.config</A><br></pre><hr></body></html>`,
+	// python http.server
+	"python": `<!DOCTYPE HTML><html><head><title>Directory listing for /python/</title></head>
+<body><h1>Directory listing for /python/</h1><hr><ul>
+<li><a href="sub/">sub/</a></li>
+<li><a href="app.py">app.py</a></li>
+</ul><hr></body></html>`,
+}
+
+// listingTestingServer Serves each listing page at /<name>/, an empty listing at /<name>/sub/, and file contents
+func listingTestingServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case len(parts) == 1 && listingPages[parts[0]] != "":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, listingPages[parts[0]])
+		case len(parts) == 2 && parts[1] == "sub":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><title>Index of /%s/sub</title><pre><a href="../">../</a>
+<a href="deep.txt">deep.txt</a>  19-Dec-2019 12:00  5
+</pre></html>`, parts[0])
+		default:
+			fmt.Fprintf(w, "contents of %s", r.URL.Path)
+		}
+	}))
+}
+
+func TestGetListing(t *testing.T) {
+	server := listingTestingServer()
+	defer server.Close()
+
+	client, err := NewHTTP(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir     string
+		entries []ListingEntry
+	}{
+		{"/apache/", []ListingEntry{
+			{Name: "sub", IsDir: true, Size: -1, ModTime: time.Date(2019, 12, 19, 12, 0, 0, 0, time.UTC)},
+			{Name: "notes.txt", Size: 1536, ModTime: time.Date(2019, 12, 18, 8, 30, 0, 0, time.UTC)},
+		}},
+		{"/nginx/", []ListingEntry{
+			{Name: "sub", IsDir: true, Size: -1, ModTime: time.Date(2019, 12, 19, 12, 0, 0, 0, time.UTC)},
+			{Name: "dump.sql", Size: 2048, ModTime: time.Date(2019, 12, 18, 8, 30, 0, 0, time.UTC)},
+		}},
+		{"/iis/", []ListingEntry{
+			{Name: "sub", IsDir: true, Size: -1, ModTime: time.Date(2019, 12, 19, 12, 0, 0, 0, time.UTC)},
+			{Name: "web.config", Size: 4096, ModTime: time.Date(2019, 12, 18, 8, 30, 0, 0, time.UTC)},
+		}},
+		{"/python/", []ListingEntry{
+			{Name: "sub", IsDir: true, Size: -1},
+			{Name: "app.py", Size: -1},
+		}},
+	}
+
+	for _, test := range tests {
+		entries, err := client.GetListing(context.Background(), test.dir)
+		if err != nil {
+			t.Errorf("Error on listing \"%s\": %v", test.dir, err)
+			continue
+		}
+		if len(entries) != len(test.entries) {
+			t.Errorf("Error on listing \"%s\", wanted %d entries got %v", test.dir, len(test.entries), entries)
+			continue
+		}
+		for i, entry := range entries {
+			want := test.entries[i]
+			if entry.Name != want.Name || entry.IsDir != want.IsDir || entry.Size != want.Size || !entry.ModTime.Equal(want.ModTime) {
+				t.Errorf("Error on listing \"%s\", wanted %+v got %+v", test.dir, want, entry)
+			}
+		}
+	}
+
+	// Not a listing
+	if _, err := client.GetListing(context.Background(), "/file.txt"); err == nil {
+		t.Errorf("Should not parse a file as a listing")
+	}
+}
+
+func TestWalkListing(t *testing.T) {
+	server := listingTestingServer()
+	defer server.Close()
+
+	client, err := NewHTTP(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := client.WalkListing(context.Background(), "/nginx/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for entry := range entries {
+		paths = append(paths, entry.URL.Path)
+	}
+	if fmt.Sprint(paths) != "[/nginx/sub/ /nginx/sub/deep.txt /nginx/dump.sql]" {
+		t.Errorf("Did not walk listing, got %v", paths)
+	}
+}
+
+func TestReadListing(t *testing.T) {
+	server := listingTestingServer()
+	defer server.Close()
+
+	client, err := NewHTTPWithOptions(server.URL+"/apache/", HTTPOptions{Listing: &ListingOptions{Filter: FileFilter{Extensions: []string{".txt"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "contents of /apache/notes.txt") || !strings.Contains(string(data), "contents of /apache/sub/deep.txt") {
+		t.Errorf("Did not read files in listing")
+	}
+
+	// Check size limit
+	client, err = NewHTTPWithOptions(server.URL+"/nginx/", HTTPOptions{Listing: &ListingOptions{Filter: FileFilter{MaxSize: 100}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "contents of /nginx/dump.sql") || !strings.Contains(string(data), "contents of /nginx/sub/deep.txt") {
+		t.Errorf("Not obeying size limit")
+	}
+
+	// Patterns match the path from the server root when listing a subdirectory
+	client, err = NewHTTPWithOptions(server.URL+"/apache/", HTTPOptions{Listing: &ListingOptions{Filter: FileFilter{Exclude: []string{"/apache/sub/*", "/notes.txt"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "contents of /apache/notes.txt") || strings.Contains(string(data), "contents of /apache/sub/deep.txt") {
+		t.Errorf("Not matching paths from the server root")
+	}
+}
@@ -0,0 +1,244 @@
+package enrichers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProbePaths Well known paths that often expose sensitive data
+var DefaultProbePaths = []string{
+	"/.env",
+	"/.env.local",
+	"/.env.production",
+	"/.git/config",
+	"/.git/HEAD",
+	"/.svn/entries",
+	"/.hg/hgrc",
+	"/.DS_Store",
+	"/.htaccess",
+	"/.htpasswd",
+	"/.npmrc",
+	"/.dockercfg",
+	"/.docker/config.json",
+	"/.aws/credentials",
+	"/.ssh/id_rsa",
+	"/config.json",
+	"/config.yml",
+	"/config.yaml",
+	"/config.php.bak",
+	"/wp-config.php.bak",
+	"/settings.py",
+	"/web.config",
+	"/appsettings.json",
+	"/docker-compose.yml",
+	"/backup.sql",
+	"/backup.zip",
+	"/backup.tar.gz",
+	"/dump.sql",
+	"/database.sql",
+	"/db.sqlite",
+	"/server-status",
+	"/server-info",
+	"/phpinfo.php",
+	"/info.php",
+	"/actuator/env",
+	"/actuator/heapdump",
+	"/actuator/configprops",
+	"/debug/vars",
+	"/debug/pprof/",
+	"/swagger.json",
+	"/api-docs",
+	"/crossdomain.xml",
+	"/id_rsa",
+	"/credentials.json",
+	"/secrets.json",
+}
+
+// ProbeOptions Options for requesting well known sensitive paths
+type ProbeOptions struct {
+	Paths       []string      // Paths to request relative to the server root.  Empty for DefaultProbePaths
+	Concurrency int           // Number of requests to make at once.  0 for 1
+	RateLimit   time.Duration // Minimum time between starting requests.  0 for no limit
+	MaxFileSize int64         // Max number of bytes to read from each hit.  0 for unlimited
+}
+
+// probeBaseline Response to a path that should not exist, used to detect soft 404 pages
+type probeBaseline struct {
+	statusCode  int
+	contentType string
+	body        []byte
+}
+
+// Probe Request each path and return every real hit as an item named by its URL.
+// Responses that look like the server's response to a random path (soft 404s) are skipped
+func (client *HTTPClient) Probe(ctx context.Context, options ProbeOptions) (chan *Item, error) {
+	paths := options.Paths
+	if len(paths) == 0 {
+		paths = DefaultProbePaths
+	}
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	maxBytes := int64(-1)
+	if options.MaxFileSize > 0 {
+		maxBytes = options.MaxFileSize
+	}
+
+	items := make(chan *Item)
+
+	go func() {
+		defer close(items)
+
+		// Limit rate of requests across all workers
+		var ticker *time.Ticker
+		if options.RateLimit > 0 {
+			ticker = time.NewTicker(options.RateLimit)
+			defer ticker.Stop()
+		}
+		wait := func() bool {
+			if ticker == nil {
+				return ctx.Err() == nil
+			}
+			select {
+			case <-ticker.C:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// Get a baseline for each extension, as servers often handle extensions such as .php differently
+		baselines := map[string]*probeBaseline{}
+		for _, probePath := range paths {
+			extension := path.Ext(probePath)
+			if _, ok := baselines[extension]; ok {
+				continue
+			}
+			if !wait() {
+				return
+			}
+			baselines[extension] = client.getProbeBaseline(ctx, extension)
+		}
+
+		// Request every path
+		pathsChan := make(chan string)
+		wg := sync.WaitGroup{}
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for probePath := range pathsChan {
+					probeURL, err := client.url.Parse(probePath)
+					if err != nil {
+						continue
+					}
+					page, err := client.fetch(ctx, probeURL, maxBytes)
+					if err != nil || !page.isProbeHit(baselines[path.Ext(probePath)]) {
+						continue
+					}
+
+					item := &Item{
+						Name:     probeURL.String(),
+						Data:     page.body,
+						Metadata: map[string]string{"status": strconv.Itoa(page.response.StatusCode)},
+					}
+					select {
+					case items <- item:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+
+		for _, probePath := range paths {
+			if !wait() {
+				break
+			}
+			select {
+			case pathsChan <- probePath:
+			case <-ctx.Done():
+			}
+		}
+		close(pathsChan)
+		wg.Wait()
+	}()
+
+	return items, nil
+}
+
+// probeItems Send every probe hit
+func (client *HTTPClient) probeItems(ctx context.Context, options ProbeOptions, items chan *Item) {
+	hits, err := client.Probe(ctx, options)
+	if err != nil {
+		return
+	}
+
+	for hit := range hits {
+		select {
+		case items <- hit:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// getProbeBaseline Request a random path with the extension.  nil if the request fails
+func (client *HTTPClient) getProbeBaseline(ctx context.Context, extension string) *probeBaseline {
+	random := make([]byte, 12)
+	rand.Read(random)
+	randomURL, err := client.url.Parse("/" + hex.EncodeToString(random) + extension)
+	if err != nil {
+		return nil
+	}
+
+	page, err := client.fetch(ctx, randomURL, 1024*1024)
+	if err != nil {
+		return nil
+	}
+
+	return &probeBaseline{
+		statusCode:  page.response.StatusCode,
+		contentType: page.response.Header.Get("Content-Type"),
+		body:        removePath(page.body, randomURL),
+	}
+}
+
+// isProbeHit Check if the page is a real hit rather than an error or a soft 404
+func (page *httpPage) isProbeHit(baseline *probeBaseline) bool {
+	if page.response.StatusCode != http.StatusOK {
+		return false
+	}
+	if baseline == nil || baseline.statusCode != page.response.StatusCode || baseline.contentType != page.response.Header.Get("Content-Type") {
+		return true
+	}
+
+	// Same status and type as a path that does not exist, compare the content
+	body := removePath(page.body, page.url)
+	if bytes.Equal(body, baseline.body) {
+		return false
+	}
+	difference := len(body) - len(baseline.body)
+	if difference < 0 {
+		difference = -difference
+	}
+	return difference > len(baseline.body)/50
+}
+
+// removePath Remove the path of the url from a page, as error pages often include the requested path
+func removePath(body []byte, u *url.URL) []byte {
+	body = bytes.Replace(body, []byte(u.EscapedPath()), nil, -1)
+	body = bytes.Replace(body, []byte(u.Path), nil, -1)
+	return bytes.Replace(body, []byte(strings.TrimPrefix(u.Path, "/")), nil, -1)
+}
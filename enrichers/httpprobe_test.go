@@ -0,0 +1,65 @@
+package enrichers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	files := map[string]string{
+		"/.env":        "DB_PASSWORD=hunter2",
+		"/config.json": `{"key": "value"}`,
+	}
+
+	// Real 404s
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if data, ok := files[r.URL.Path]; ok {
+			fmt.Fprint(w, data)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	// Soft 404s that include the requested path
+	softServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if data, ok := files[r.URL.Path]; ok {
+			fmt.Fprint(w, data)
+			return
+		}
+		fmt.Fprintf(w, "<html><body>Sorry, the page %s could not be found.  Try the home page.</body></html>", r.URL.Path)
+	}))
+	defer softServer.Close()
+
+	paths := []string{"/.env", "/config.json", "/backup.sql", "/server-status", "/phpinfo.php"}
+	for _, testServer := range []*httptest.Server{server, softServer} {
+		client, err := NewHTTP(testServer.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		hits, err := client.Probe(context.Background(), ProbeOptions{Paths: paths, Concurrency: 3, RateLimit: time.Millisecond * 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for hit := range hits {
+			names = append(names, hit.Name)
+		}
+		sort.Strings(names)
+
+		if fmt.Sprint(names) != fmt.Sprint([]string{testServer.URL + "/.env", testServer.URL + "/config.json"}) {
+			t.Errorf("Wrong hits, got %v", names)
+		}
+		// Baselines for "", .json, .sql, and .php then every path
+		if time.Since(start) < time.Millisecond*80 {
+			t.Errorf("Not obeying rate limit")
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Command genembed writes a Go file holding the contents of a data file in a byte slice variable.
+// Usage: genembed <package> <input file> <variable name> <output file>
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) != 5 {
+		fmt.Fprintln(os.Stderr, "usage: genembed <package> <input file> <variable name> <output file>")
+		os.Exit(2)
+	}
+	pkg, input, variable, output := os.Args[1], os.Args[2], os.Args[3], os.Args[4]
+
+	data, err := ioutil.ReadFile(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Raw strings keep the file readable, but can not hold backquotes or carriage returns
+	literal := "`" + string(data) + "`"
+	if strings.ContainsAny(string(data), "`\r") {
+		literal = strconv.Quote(string(data))
+	}
+
+	source := fmt.Sprintf("// Code generated by \"genembed %s %s %s %s\"; DO NOT EDIT.\n\npackage %s\n\nvar %s = []byte(%s)\n",
+		pkg, input, variable, output, pkg, variable, literal)
+	if err := ioutil.WriteFile(output, []byte(source), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
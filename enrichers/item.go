@@ -0,0 +1,128 @@
+package enrichers
+
+import (
+	"context"
+	"io"
+)
+
+// Item A single piece of data read from a server (a web page, a file, etc) along with where it came from
+type Item struct {
+	Name     string            // Where the item came from, such as a URL or file path
+	Data     []byte            // Raw data of the item
+	Metadata map[string]string // Extra information about the item
+}
+
+// itemsReader Returns a reader that streams the data of every item in the channel
+func itemsReader(ctx context.Context, items chan *Item) io.ReadCloser {
+	itemsDataReader, itemsDataWriter := io.Pipe()
+
+	go func() {
+		defer itemsDataWriter.Close()
+
+		for {
+			select {
+			case item, ok := <-items:
+				if !ok {
+					return
+				}
+				if _, err := itemsDataWriter.Write(item.Data); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return itemsDataReader
+}
+
+// expandOptions How to expand items read from a server
+type expandOptions struct {
+	archives  *ArchiveOptions // Unpack archives and compressed files.  nil to disable
+	documents bool            // Add the text of documents as child items
+	sqlDumps  bool            // Replace SQL dumps with their rows
+	sqlite    *SQLLimits      // Replace SQLite databases with their rows within the limits.  nil to disable
+}
+
+// expandItem Replace archives with their entries, add the text of documents as child items, and split SQL dumps and
+// SQLite databases into rows, as the options say, calling emit with every item until it returns false.  Every item is
+// sniffed for its content type, encoding, and entropy, and text is converted to UTF-8.  Returns false if emit did, or
+// if ctx is done while reading a database
+func expandItem(ctx context.Context, item *Item, options expandOptions, emit func(item *Item) bool) bool {
+	items := []*Item{item}
+	// Text of documents is read within the budget left from unpacking the item
+	unpacker := newUnpacker(item, ArchiveOptions{})
+	if options.archives != nil {
+		unpacker = newUnpacker(item, *options.archives)
+		// Documents are zips, read their text instead of their XML
+		unpacker.keepDocuments = options.documents
+		items = unpacker.unpack(item, 0)
+	} else if options.sqlDumps && isCompressedSQLDump(item.Name, item.Data) {
+		items = UnpackItem(item, ArchiveOptions{MaxDepth: 1})
+	}
+
+	emitSniffed := func(item *Item) bool {
+		sniffItem(item)
+		return emit(item)
+	}
+	for _, item := range items {
+		// Databases can be huge, stream their rows
+		if options.sqlite != nil && IsSQLite(item.Data) {
+			opened, more := sqliteItems(ctx, item, *options.sqlite, emitSniffed)
+			if !more {
+				return false
+			}
+			if opened {
+				continue
+			}
+		}
+		sniffItem(item)
+		if options.sqlDumps && item.Metadata["encoding"] != "" && IsSQLDump(item.Name, item.Data) {
+			found, more := sqlDumpItems(item, emitSniffed)
+			if !more {
+				return false
+			}
+			if found {
+				continue
+			}
+		}
+
+		if !emit(item) {
+			return false
+		}
+		if !options.documents {
+			continue
+		}
+		if text := documentItem(item, unpacker); text != nil && !emitSniffed(text) {
+			return false
+		}
+	}
+	return true
+}
+
+// expandItems Expand every item in the channel
+func expandItems(ctx context.Context, items chan *Item, options expandOptions) chan *Item {
+	expanded := make(chan *Item)
+	go func() {
+		defer close(expanded)
+		for item := range items {
+			sent := expandItem(ctx, item, options, func(entry *Item) bool {
+				select {
+				case expanded <- entry:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+			if !sent {
+				// Let the sender stop
+				for range items {
+				}
+				return
+			}
+		}
+	}()
+
+	return expanded
+}
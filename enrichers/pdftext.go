@@ -0,0 +1,309 @@
+package enrichers
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"unicode/utf16"
+)
+
+const (
+	maxPDFDictionarySize = 4096 // Max bytes to look back from a stream for its dictionary
+	pdfTJSpace           = -200 // TJ adjustments further left than this are a space between words
+)
+
+// pdfSkippedStreams Streams that never hold page text
+var pdfSkippedStreams = [][]byte{[]byte("/Image"), []byte("/ObjStm"), []byte("/XRef"), []byte("/FontFile"), []byte("/Metadata")}
+
+// pdfText Get the text shown by the content streams of a PDF.  Only text in standard and simple encodings is
+// readable, fonts with custom encodings come out as their raw codes.  Streams are decompressed within the budget of
+// the unpacker
+func pdfText(data []byte, unpacker *unpacker) []byte {
+	text := &bytes.Buffer{}
+	for _, stream := range pdfStreams(data, unpacker) {
+		if bytes.Contains(stream, []byte("BT")) {
+			pdfContentText(stream, text)
+		}
+	}
+	return text.Bytes()
+}
+
+// pdfStreams Get the decoded data of every stream that could hold page content
+func pdfStreams(data []byte, unpacker *unpacker) [][]byte {
+	streams := [][]byte{}
+	for offset := 0; ; {
+		index := bytes.Index(data[offset:], []byte("stream"))
+		if index == -1 {
+			return streams
+		}
+		keyword := offset + index
+		start := keyword + len("stream")
+		offset = start
+
+		// Skip endstream and streams without an EOL after them
+		if keyword >= 3 && bytes.Equal(data[keyword-3:keyword], []byte("end")) {
+			continue
+		}
+		if bytes.HasPrefix(data[start:], []byte("\r\n")) {
+			start += 2
+		} else if bytes.HasPrefix(data[start:], []byte("\n")) || bytes.HasPrefix(data[start:], []byte("\r")) {
+			start++
+		} else {
+			continue
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end == -1 {
+			return streams
+		}
+		end += start
+		offset = end
+
+		// Dictionary since the start of the object
+		dictionaryStart := start - maxPDFDictionarySize
+		if dictionaryStart < 0 {
+			dictionaryStart = 0
+		}
+		dictionary := data[dictionaryStart:start]
+		if obj := bytes.LastIndex(dictionary, []byte("obj")); obj != -1 {
+			dictionary = dictionary[obj:]
+		}
+
+		if stream, ok := pdfDecodeStream(dictionary, data[start:end], unpacker); ok {
+			streams = append(streams, stream)
+		}
+	}
+}
+
+// pdfDecodeStream Decode the stream if it is not compressed or compressed with Flate
+func pdfDecodeStream(dictionary, stream []byte, unpacker *unpacker) ([]byte, bool) {
+	for _, skipped := range pdfSkippedStreams {
+		if bytes.Contains(dictionary, skipped) {
+			return nil, false
+		}
+	}
+	if !bytes.Contains(dictionary, []byte("/Filter")) {
+		return stream, true
+	}
+	if !bytes.Contains(dictionary, []byte("/FlateDecode")) && !bytes.Contains(dictionary, []byte("/Fl ")) && !bytes.Contains(dictionary, []byte("/Fl]")) {
+		return nil, false
+	}
+
+	var reader io.Reader
+	if zlibReader, err := zlib.NewReader(bytes.NewReader(stream)); err == nil {
+		reader = zlibReader
+	} else {
+		reader = flate.NewReader(bytes.NewReader(stream))
+	}
+	// Streams are often followed by garbage, keep what decoded
+	decoded, _ := unpacker.read(io.LimitReader(reader, maxDocumentPartSize))
+	return decoded, len(decoded) > 0
+}
+
+// pdfContentText Write the text shown by the operators in a content stream
+func pdfContentText(content []byte, text *bytes.Buffer) {
+	operands := [][]byte{}  // Strings of the current operator
+	numbers := []float64{}  // Numbers of the current operator
+	adjustments := []bool{} // In a TJ array, if a space comes before each string
+	inArray := false
+	space := false
+
+	newline := func() {
+		if text.Len() > 0 && !bytes.HasSuffix(text.Bytes(), []byte("\n")) {
+			text.WriteByte('\n')
+		}
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '%':
+			// Comment
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			var s []byte
+			s, i = pdfLiteralString(content, i)
+			operands = append(operands, s)
+			adjustments = append(adjustments, space)
+			space = false
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			// Dictionary of marked content
+			i += 2
+		case c == '<':
+			var s []byte
+			s, i = pdfHexString(content, i)
+			operands = append(operands, s)
+			adjustments = append(adjustments, space)
+			space = false
+		case c == '[':
+			inArray = true
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.':
+			start := i
+			for i < len(content) && ((content[i] >= '0' && content[i] <= '9') || content[i] == '-' || content[i] == '+' || content[i] == '.') {
+				i++
+			}
+			number, err := strconv.ParseFloat(string(content[start:i]), 64)
+			if err == nil {
+				if inArray && number < pdfTJSpace {
+					space = true
+				}
+				numbers = append(numbers, number)
+			}
+		case isPDFRegular(c):
+			start := i
+			for i < len(content) && isPDFRegular(content[i]) {
+				i++
+			}
+			switch string(content[start:i]) {
+			case "Tj", "TJ":
+				for j, operand := range operands {
+					if adjustments[j] && j > 0 {
+						text.WriteByte(' ')
+					}
+					text.Write(pdfDecodeString(operand))
+				}
+			case "'", "\"":
+				newline()
+				for _, operand := range operands {
+					text.Write(pdfDecodeString(operand))
+				}
+			case "T*", "ET", "Tm":
+				newline()
+			case "Td", "TD":
+				if len(numbers) >= 2 && numbers[len(numbers)-1] != 0 {
+					newline()
+				} else {
+					text.WriteByte(' ')
+				}
+			}
+			operands, numbers, adjustments = operands[0:0], numbers[0:0], adjustments[0:0]
+			space = false
+		default:
+			i++
+		}
+	}
+	newline()
+}
+
+// isPDFRegular Check if the byte is part of a name or operator
+func isPDFRegular(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return false
+	}
+	return true
+}
+
+// pdfLiteralString Read the (string) at i.  Returns the string and the index after it
+func pdfLiteralString(content []byte, i int) ([]byte, int) {
+	s := []byte{}
+	depth := 0
+	for i++; i < len(content); i++ {
+		c := content[i]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return s, i + 1
+			}
+			depth--
+		case '\\':
+			i++
+			if i >= len(content) {
+				return s, i
+			}
+			switch escaped := content[i]; escaped {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// Line continuation
+				if escaped == '\r' && i+1 < len(content) && content[i+1] == '\n' {
+					i++
+				}
+				continue
+			default:
+				if escaped >= '0' && escaped <= '7' {
+					// Up to three octal digits
+					value := 0
+					for digits := 0; digits < 3 && i < len(content) && content[i] >= '0' && content[i] <= '7'; digits++ {
+						value = value*8 + int(content[i]-'0')
+						i++
+					}
+					i--
+					c = byte(value)
+				} else {
+					c = escaped
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return s, i
+}
+
+// pdfHexString Read the <hex string> at i.  Returns the string and the index after it
+func pdfHexString(content []byte, i int) ([]byte, int) {
+	digits := []byte{}
+	for i++; i < len(content) && content[i] != '>'; i++ {
+		if c := content[i]; (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s := make([]byte, len(digits)/2)
+	for j := range s {
+		value, _ := strconv.ParseUint(string(digits[j*2:j*2+2]), 16, 8)
+		s[j] = byte(value)
+	}
+	return s, i + 1
+}
+
+// pdfDecodeString Convert a PDF string to UTF-8.  Strings are UTF-16 with a byte order mark, two byte codes that look
+// like UTF-16, or single bytes
+func pdfDecodeString(s []byte) []byte {
+	utf16BE := bytes.HasPrefix(s, []byte{0xfe, 0xff})
+	if utf16BE {
+		s = s[2:]
+	} else if len(s) >= 2 && len(s)%2 == 0 {
+		// Two byte codes of Latin text have a zero high byte
+		utf16BE = true
+		for j := 0; j < len(s); j += 2 {
+			if s[j] != 0 {
+				utf16BE = false
+				break
+			}
+		}
+	}
+
+	if utf16BE {
+		units := make([]uint16, len(s)/2)
+		for j := range units {
+			units[j] = uint16(s[j*2])<<8 | uint16(s[j*2+1])
+		}
+		return []byte(string(utf16.Decode(units)))
+	}
+
+	decoded := make([]rune, len(s))
+	for j, b := range s {
+		decoded[j] = rune(b)
+	}
+	return []byte(string(decoded))
+}
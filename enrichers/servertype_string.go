@@ -14,11 +14,13 @@ func _() {
 	_ = x[SSH-3]
 	_ = x[SQL-4]
 	_ = x[HTTP-5]
+	_ = x[MSSQL-6]
+	_ = x[SQLite-7]
 }
 
-const _ServerType_name = "UnknownELKFTPSSHSQLHTTP"
+const _ServerType_name = "UnknownELKFTPSSHSQLHTTPMSSQLSQLite"
 
-var _ServerType_index = [...]uint8{0, 7, 10, 13, 16, 19, 23}
+var _ServerType_index = [...]uint8{0, 7, 10, 13, 16, 19, 23, 28, 34}
 
 func (i ServerType) String() string {
 	if i < 0 || i >= ServerType(len(_ServerType_index)-1) {
@@ -0,0 +1,30 @@
+package enrichers
+
+import (
+	"errors"
+	"time"
+)
+
+// ServerSize Estimate of how much data a server holds, to budget a scan before starting it
+type ServerSize struct {
+	Bytes uint64 // Bytes of data.  Database statistics and listings are estimates and can be off
+	Items int64  // Approximate number of items such as rows, documents, files, or pages
+
+	// Complete False if the estimate stopped early or left things out, such as a walk that ran out of time or files of
+	// unknown size.  The server holds more than the estimate
+	Complete bool
+}
+
+// DefaultSizeWalkTime How long to walk FTP servers and directory listings when estimating their size
+const DefaultSizeWalkTime = time.Second * 30
+
+// ErrNoSize Returned when getting the size of a server that can not report it
+var ErrNoSize = errors.New("server can not report its size")
+
+// sizeWalkTime Get how long to walk, the default if 0
+func sizeWalkTime(walkTime time.Duration) time.Duration {
+	if walkTime <= 0 {
+		return DefaultSizeWalkTime
+	}
+	return walkTime
+}
@@ -0,0 +1,115 @@
+package enrichers
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLSize(t *testing.T) {
+	db := fakeShopDB()
+	db.results["SELECT TABLE_SCHEMA, SUM(DATA_LENGTH), SUM(TABLE_ROWS) FROM information_schema.TABLES "+
+		"WHERE TABLE_SCHEMA NOT IN ('information_schema', 'mysql', 'performance_schema', 'sys') GROUP BY TABLE_SCHEMA"] = &fakeSQLResult{
+		columns: []string{"TABLE_SCHEMA", "SUM(DATA_LENGTH)", "SUM(TABLE_ROWS)"},
+		rows:    [][]driver.Value{{"shop", []byte("81920"), []byte("5002")}, {"crm", int64(1024), nil}},
+	}
+
+	size, err := newFakeSQLClient(db, "shop", SQLOptions{}).Size(context.Background())
+	if err != nil || !reflect.DeepEqual(size, &ServerSize{Bytes: 81920, Items: 5002, Complete: true}) {
+		t.Errorf("Wrong size %+v %v", size, err)
+	}
+
+	// Every database when none is selected
+	db.results["SELECT DATABASE()"] = &fakeSQLResult{columns: []string{"DATABASE()"}, rows: [][]driver.Value{{nil}}}
+	size, err = newFakeSQLClient(db, "", SQLOptions{}).Size(context.Background())
+	if err != nil || !reflect.DeepEqual(size, &ServerSize{Bytes: 82944, Items: 5002, Complete: true}) {
+		t.Errorf("Wrong size of every database %+v %v", size, err)
+	}
+}
+
+func TestFTPSize(t *testing.T) {
+	server := &fakeFTPServer{Files: walkTestingFiles}
+	client := connectFakeFTP(t, server, FTPOptions{Filter: FileFilter{Exclude: []string{"/elsewhere/*"}}})
+	defer server.Close()
+	defer client.Close()
+
+	size, err := client.Size(context.Background())
+	if err != nil || !reflect.DeepEqual(size, &ServerSize{Bytes: 9, Items: 3, Complete: true}) {
+		t.Errorf("Wrong size %+v %v", size, err)
+	}
+}
+
+func TestHTTPSize(t *testing.T) {
+	server := listingTestingServer()
+	defer server.Close()
+
+	tests := []struct {
+		path    string
+		options HTTPOptions
+		size    ServerSize
+	}{
+		// Only the page
+		{"/apache/", HTTPOptions{}, ServerSize{Bytes: uint64(len(listingPages["apache"])), Items: 1, Complete: true}},
+		// notes.txt and sub/deep.txt
+		{"/apache/", HTTPOptions{Listing: &ListingOptions{}}, ServerSize{Bytes: uint64(len(listingPages["apache"])) + 1536 + 5, Items: 3, Complete: true}},
+		// Sizes of app.py and the sub folder are unknown
+		{"/python/", HTTPOptions{Listing: &ListingOptions{}}, ServerSize{Bytes: uint64(len(listingPages["python"])) + 5, Items: 3}},
+		// Crawled pages are not counted
+		{"/apache/", HTTPOptions{Crawl: &CrawlOptions{}}, ServerSize{Bytes: uint64(len(listingPages["apache"])), Items: 1}},
+	}
+	for _, test := range tests {
+		client, err := NewHTTPWithOptions(server.URL+test.path, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := client.Size(context.Background())
+		if err != nil || !reflect.DeepEqual(*size, test.size) {
+			t.Errorf("Wrong size of %s %+v: %+v %v", test.path, test.options, size, err)
+		}
+	}
+
+	// Without Content-Length
+	chunked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(" second"))
+	}))
+	defer chunked.Close()
+	client, err := NewHTTP(chunked.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, err := client.Size(context.Background()); err != nil || size.Bytes != 12 {
+		t.Errorf("Wrong size %+v %v", size, err)
+	}
+
+	// From HEAD, or the Content-Range of the first byte when HEAD is not allowed, without reading the body
+	file := strings.Repeat("x", 4096)
+	for _, allowHead := range []bool{true, false} {
+		bodies := 0
+		sized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "HEAD" && !allowHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if r.Method == "GET" && r.Header.Get("Range") == "" {
+				bodies++
+			}
+			http.ServeContent(w, r, "file.bin", time.Time{}, strings.NewReader(file))
+		}))
+		client, err := NewHTTP(sized.URL + "/file.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := client.Size(context.Background())
+		if err != nil || size.Bytes != uint64(len(file)) || !size.Complete || bodies != 0 {
+			t.Errorf("Wrong size with HEAD %v: %+v %v, read %d bodies", allowHead, size, err, bodies)
+		}
+		sized.Close()
+	}
+}
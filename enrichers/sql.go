@@ -1,12 +1,18 @@
 package enrichers
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"github.com/go-sql-driver/mysql"
+	"github.com/vertoforce/multiregex"
 	"io"
 	"net"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	// Using go sql driver
@@ -16,15 +22,48 @@ import (
 type SQLClient struct {
 	config       *mysql.Config
 	url          *url.URL
+	options      SQLOptions
 	db           *sql.DB
 	reader       io.ReadCloser
 	readerCtx    context.Context
 	readerCancel context.CancelFunc
 }
 
+// SQLOptions Options for the SQL client
+type SQLOptions struct {
+	DumpFormat SQLDumpFormat // Format of the rows when dumping or reading
+	Delimiter  string        // Between values with SQLDumpDelimited.  Empty for a tab
+
+	// SensitiveOnly Dump only the columns classified as sensitive by GetSchema, along with the primary key, of the
+	// tables that have any.  Avoids reading large columns that do not matter
+	SensitiveOnly bool
+
+	Limits SQLLimits // How much of each table to read and in what chunks.  Every row in one query by default
+}
+
+// SQLColumn A column of a table
+type SQLColumn struct {
+	Name       string
+	Type       string // Database type such as VARCHAR or BLOB
+	Binary     bool   // Holds binary data, such as a BLOB or VARBINARY column
+	PrimaryKey bool   // Part of the primary key
+	Class      string // Class of sensitive data from SQLColumnClasses, such as password or email.  Empty if none
+}
+
+// sqlBinaryTypes Database types of columns holding binary data
+var sqlBinaryTypes = map[string]bool{
+	"BLOB": true, "TINYBLOB": true, "MEDIUMBLOB": true, "LONGBLOB": true, "BINARY": true, "VARBINARY": true,
+	"BIT": true, "GEOMETRY": true,
+}
+
 // NewSQL Create new SQL client
 func NewSQL(urlString string) (*SQLClient, error) {
-	client := &SQLClient{}
+	return NewSQLWithOptions(urlString, SQLOptions{})
+}
+
+// NewSQLWithOptions Create new SQL client with options
+func NewSQLWithOptions(urlString string, options SQLOptions) (*SQLClient, error) {
+	client := &SQLClient{options: options}
 
 	// Parse URL
 	var err error
@@ -143,8 +182,73 @@ func (client *SQLClient) ResetReader() error {
 
 // -- SQL specific functions --
 
-// Dump SQL Dump data of entire database
+// MySQL capability flags used to ask for TLS
+const (
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+)
+
+// GetTLSInfo Get details of the server's TLS connection and certificates by asking a new connection for SSL.
+// ErrNoTLS if the server does not support it
+func (client *SQLClient) GetTLSInfo(ctx context.Context) (*TLSInfo, error) {
+	if client.config.Net != "tcp" {
+		return nil, ErrNoTLS
+	}
+	address := client.config.Addr
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "3306")
+	}
+	host, _, _ := net.SplitHostPort(address)
+
+	return dialInspectTLS(ctx, address, host, mysqlRequestTLS)
+}
+
+// mysqlRequestTLS Read the server's handshake and send an SSL request so the next bytes are the TLS handshake
+func mysqlRequestTLS(conn net.Conn) error {
+	// Handshake packet
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+	if len(payload) > 0 && payload[0] == 0xff {
+		if len(payload) > 3 {
+			return fmt.Errorf("mysql error: %s", payload[3:])
+		}
+		return errors.New("mysql error")
+	}
+
+	// Protocol version, null terminated server version, connection id, auth data, filler, capabilities
+	versionEnd := bytes.IndexByte(payload, 0)
+	if versionEnd == -1 || len(payload) < versionEnd+1+4+8+1+2 {
+		return errors.New("invalid mysql handshake")
+	}
+	capabilitiesStart := versionEnd + 1 + 4 + 8 + 1
+	capabilities := binary.LittleEndian.Uint16(payload[capabilitiesStart:])
+	if capabilities&mysqlClientSSL == 0 {
+		return ErrNoTLS
+	}
+
+	// SSL request: capabilities, max packet size, charset, 23 reserved bytes
+	request := make([]byte, 4+32)
+	request[0], request[3] = 32, header[3]+1
+	binary.LittleEndian.PutUint32(request[4:], mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(request[8:], 16*1024*1024)
+	request[12] = 33 // utf8_general_ci
+	_, err := conn.Write(request)
+	return err
+}
+
+// Dump SQL Dump data of entire database in the dump format of the options
 func (client *SQLClient) Dump(ctx context.Context) (io.ReadCloser, error) {
+	if client.options.DumpFormat != SQLDumpRaw || client.options.SensitiveOnly {
+		return client.dumpRows(ctx)
+	}
+
 	dumpReader, dumpWriter := io.Pipe()
 
 	go func() {
@@ -167,6 +271,51 @@ func (client *SQLClient) Dump(ctx context.Context) (io.ReadCloser, error) {
 	return dumpReader, nil
 }
 
+// Size Get the size of the database's data and its number of rows from information_schema.TABLES, or of every
+// database if none is selected.  Row counts are estimates for InnoDB tables
+func (client *SQLClient) Size(ctx context.Context) (*ServerSize, error) {
+	sizes, err := client.GetDatabaseSizes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if database := client.database(ctx); database != "" {
+		if size, ok := sizes[database]; ok {
+			return size, nil
+		}
+		return &ServerSize{Complete: true}, nil
+	}
+
+	total := &ServerSize{Complete: true}
+	for _, size := range sizes {
+		total.Bytes += size.Bytes
+		total.Items += size.Items
+	}
+	return total, nil
+}
+
+// GetDatabaseSizes Get the size of the data and the number of rows of each database, leaving out system databases
+func (client *SQLClient) GetDatabaseSizes(ctx context.Context) (map[string]*ServerSize, error) {
+	rows, err := client.db.QueryContext(ctx, "SELECT TABLE_SCHEMA, SUM(DATA_LENGTH), SUM(TABLE_ROWS) FROM information_schema.TABLES "+
+		"WHERE TABLE_SCHEMA NOT IN ('information_schema', 'mysql', 'performance_schema', 'sys') GROUP BY TABLE_SCHEMA")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := map[string]*ServerSize{}
+	for rows.Next() {
+		var database string
+		var dataSize, tableRows sql.NullInt64
+		if err := rows.Scan(&database, &dataSize, &tableRows); err != nil {
+			return nil, err
+		}
+		sizes[database] = &ServerSize{Bytes: uint64(dataSize.Int64), Items: tableRows.Int64, Complete: true}
+	}
+
+	return sizes, rows.Err()
+}
+
 // GetTables Get mysql table names
 func (client *SQLClient) GetTables() []string {
 	// TODO: This will panic if connect fails
@@ -186,37 +335,29 @@ func (client *SQLClient) GetTables() []string {
 	return tables
 }
 
-// GetRows Get rows of data in table.  NOTE: tableName is NOT sanitized, it is injected right in to the query
+// GetRows Get rows of data in table within the limits of the options.  NOTE: tableName is NOT sanitized, it is
+// injected right in to the query
 func (client *SQLClient) GetRows(ctx context.Context, tableName string) (columnNames []string, rowsChan chan [][]byte) {
-	rows, err := client.db.Query("SELECT * FROM " + tableName)
+	columns, rows, err := client.tableRows(ctx, tableName, tableName, nil, client.options.Limits)
 	if err != nil {
 		return nil, nil
 	}
 
-	columnNames, err = rows.Columns()
-	if err != nil {
-		return nil, nil
+	columnNames = make([]string, len(columns))
+	for i, column := range columns {
+		columnNames[i] = column.Name
 	}
 
 	rowsChan = make(chan [][]byte)
 
 	go func() {
 		defer close(rowsChan)
-		defer rows.Close()
-
-		row := make([]interface{}, len(columnNames))
-		for i := range columnNames {
-			row[i] = new([]byte)
-		}
-
-		for rows.Next() {
-			rows.Scan(row...)
 
-			// Convert each column to array of bytes
-			rowBytes := make([][]byte, len(row))
-			for i := range row {
-				// Type assert to *[]byte then dereference into []byte
-				rowBytes[i] = *(row[i].(*[]byte))
+		for row := range rows {
+			// Convert each column to array of bytes, nil for NULL
+			rowBytes := make([][]byte, len(row.Values))
+			for i, value := range row.Values {
+				rowBytes[i] = value.Data
 			}
 
 			select {
@@ -224,9 +365,160 @@ func (client *SQLClient) GetRows(ctx context.Context, tableName string) (columnN
 			case <-ctx.Done():
 				return
 			}
-
 		}
 	}()
 
 	return columnNames, rowsChan
 }
+
+// GetTablesMatchingRules Get tables that have rows that match a rule, checking up to maxRowsToCheck rows of each
+// table as JSON.  -1 for unlimited
+func (client *SQLClient) GetTablesMatchingRules(ctx context.Context, rules []*regexp.Regexp, maxRowsToCheck int64) ([]string, error) {
+	matchedTables := []string{}
+	if maxRowsToCheck == 0 {
+		return matchedTables, nil
+	}
+	limits := client.options.Limits
+	if maxRowsToCheck > 0 {
+		limits.MaxRows = maxRowsToCheck
+	}
+
+	// Search rows of each table
+	for _, table := range client.GetTables() {
+		rowsCtx, cancel := context.WithCancel(ctx)
+		_, rows, err := client.tableRows(rowsCtx, table, quoteSQLName(table), nil, limits)
+		if err != nil {
+			cancel()
+			continue
+		}
+
+		// Check all rows
+		for row := range rows {
+			if multiregex.RuleSet(rules).MatchesRules(row.JSON()) {
+				matchedTables = append(matchedTables, table)
+				break
+			}
+		}
+
+		// Stop fetching rows (especially if we broke early)
+		cancel()
+	}
+
+	return matchedTables, ctx.Err()
+}
+
+// dumpRows Dump the rows of every table in the dump format of the options
+func (client *SQLClient) dumpRows(ctx context.Context) (io.ReadCloser, error) {
+	dumpReader, dumpWriter := io.Pipe()
+
+	go func() {
+		// Stop reading rows when the reader is closed
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		writer := newSQLRowWriter(dumpWriter, client.options.DumpFormat, client.options.Delimiter)
+		tables, err := client.dumpColumns(ctx)
+		if err != nil {
+			dumpWriter.CloseWithError(err)
+			return
+		}
+		for _, table := range tables {
+			_, rows, err := client.tableRows(ctx, table.name, quoteSQLName(table.name), table.columns, client.options.Limits)
+			if err != nil {
+				continue
+			}
+			for row := range rows {
+				if err := writer.write(row); err != nil {
+					dumpWriter.CloseWithError(err)
+					return
+				}
+			}
+		}
+		dumpWriter.Close()
+	}()
+
+	return dumpReader, nil
+}
+
+// sqlDumpColumns A table to dump and its columns to read
+type sqlDumpColumns struct {
+	name    string
+	columns []string // nil for every column
+}
+
+// dumpColumns Get the tables and columns to dump.  Every column of every table unless only sensitive columns are
+// dumped
+func (client *SQLClient) dumpColumns(ctx context.Context) ([]sqlDumpColumns, error) {
+	tables := []sqlDumpColumns{}
+	if !client.options.SensitiveOnly {
+		for _, table := range client.GetTables() {
+			tables = append(tables, sqlDumpColumns{name: table})
+		}
+		return tables, nil
+	}
+
+	schema, err := client.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range schema.Tables {
+		if table.Sensitivity == 0 {
+			continue
+		}
+		columns := []string{}
+		for _, column := range table.Columns {
+			if column.PrimaryKey || column.Class != "" {
+				columns = append(columns, column.Name)
+			}
+		}
+		tables = append(tables, sqlDumpColumns{name: table.Name, columns: columns})
+	}
+	return tables, nil
+}
+
+// GetTypedRows Get the columns of the table and its rows with their database, primary key, and values that tell
+// NULL from empty and binary from text.  Rows are read within the limits of the options
+func (client *SQLClient) GetTypedRows(ctx context.Context, tableName string) ([]SQLColumn, chan *SQLRow, error) {
+	return client.tableRows(ctx, tableName, quoteSQLName(tableName), nil, client.options.Limits)
+}
+
+// tableRows Get the columns and rows of the table within the limits, reading only the named columns unless selected
+// is nil.  from is the table as it goes in the query
+func (client *SQLClient) tableRows(ctx context.Context, tableName, from string, selected []string, limits SQLLimits) ([]SQLColumn, chan *SQLRow, error) {
+	table := sqlTable{database: client.database(ctx), name: tableName, from: from, key: client.primaryKey(ctx, tableName)}
+	return readSQLTable(ctx, client.db, &mysqlDialect, table, selected, limits)
+}
+
+// database Get the name of the database in use
+func (client *SQLClient) database(ctx context.Context) string {
+	if client.config.DBName != "" {
+		return client.config.DBName
+	}
+	var database sql.NullString
+	client.db.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&database)
+	return database.String
+}
+
+// primaryKey Get the primary key columns of the table in order.  Empty if it has none
+func (client *SQLClient) primaryKey(ctx context.Context, tableName string) []string {
+	rows, err := client.db.QueryContext(ctx, "SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE "+
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION", tableName)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	key := []string{}
+	for rows.Next() {
+		var column string
+		if rows.Scan(&column) == nil {
+			key = append(key, column)
+		}
+	}
+	return key
+}
+
+// quoteSQLName Quote a table or column name with backticks
+func quoteSQLName(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
@@ -2,9 +2,18 @@ package enrichers
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 func TestNewSQL(t *testing.T) {
@@ -57,3 +66,221 @@ func TestDump(t *testing.T) {
 		fmt.Printf("Read %d bytes\n", read)
 	}
 }
+
+// fakeSQLResult Result of a query to the fake SQL database
+type fakeSQLResult struct {
+	columns []string
+	types   []string // Database type of each column
+	rows    [][]driver.Value
+}
+
+// fakeSQLDB A database/sql driver answering known queries, for testing without a server.  Queries are looked up with
+// their ? or @p1 arguments filled in and quoted.  Like go-mssqldb, which sends bytes as varbinary that do not compare
+// with text and numbers, @p1 arguments can not be bytes
+type fakeSQLDB struct {
+	results map[string]*fakeSQLResult
+	lock    sync.Mutex
+	queries []string
+}
+
+// newFakeSQLClient Create a client that queries the fake database
+func newFakeSQLClient(db *fakeSQLDB, database string, options SQLOptions) *SQLClient {
+	return &SQLClient{db: sql.OpenDB(db), config: &mysql.Config{DBName: database}, options: options}
+}
+
+func (db *fakeSQLDB) Connect(ctx context.Context) (driver.Conn, error) { return &fakeSQLConn{db}, nil }
+func (db *fakeSQLDB) Driver() driver.Driver                            { return db }
+func (db *fakeSQLDB) Open(name string) (driver.Conn, error)            { return &fakeSQLConn{db}, nil }
+
+// query Get the result of the query with its arguments
+func (db *fakeSQLDB) query(query string, args []driver.Value) (driver.Rows, error) {
+	for i, arg := range args {
+		data, binary := arg.([]byte)
+		if binary {
+			arg = string(data)
+		}
+		value := fmt.Sprintf("'%v'", arg)
+		if name := fmt.Sprintf("@p%d", i+1); strings.Contains(query, name) {
+			if binary {
+				return nil, fmt.Errorf("varbinary argument %s of %s", name, query)
+			}
+			query = strings.Replace(query, name, value, -1)
+		} else {
+			query = strings.Replace(query, "?", value, 1)
+		}
+	}
+	db.lock.Lock()
+	db.queries = append(db.queries, query)
+	db.lock.Unlock()
+
+	result, ok := db.results[query]
+	if !ok {
+		return nil, fmt.Errorf("unknown query %s", query)
+	}
+	return &fakeSQLRows{result: result}, nil
+}
+
+type fakeSQLConn struct{ db *fakeSQLDB }
+
+func (conn *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{conn.db, query}, nil
+}
+func (conn *fakeSQLConn) Close() error              { return nil }
+func (conn *fakeSQLConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeSQLStmt struct {
+	db    *fakeSQLDB
+	query string
+}
+
+func (stmt *fakeSQLStmt) Close() error  { return nil }
+func (stmt *fakeSQLStmt) NumInput() int { return -1 }
+func (stmt *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, err := stmt.db.query(stmt.query, args); err != nil {
+		return nil, err
+	}
+	return driver.ResultNoRows, nil
+}
+func (stmt *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return stmt.db.query(stmt.query, args)
+}
+
+type fakeSQLRows struct {
+	result *fakeSQLResult
+	next   int
+}
+
+func (rows *fakeSQLRows) Columns() []string { return rows.result.columns }
+func (rows *fakeSQLRows) Close() error      { return nil }
+func (rows *fakeSQLRows) Next(dest []driver.Value) error {
+	if rows.next >= len(rows.result.rows) {
+		return io.EOF
+	}
+	copy(dest, rows.result.rows[rows.next])
+	rows.next++
+	return nil
+}
+func (rows *fakeSQLRows) ColumnTypeDatabaseTypeName(i int) string {
+	if i < len(rows.result.types) {
+		return rows.result.types[i]
+	}
+	return "VARCHAR"
+}
+
+// fakeShopDB A fake database with a users table and a logs table without a primary key
+func fakeShopDB() *fakeSQLDB {
+	return &fakeSQLDB{results: map[string]*fakeSQLResult{
+		"SHOW TABLES": {columns: []string{"Tables_in_shop"}, rows: [][]driver.Value{{"users"}, {"logs"}}},
+		"SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION": {
+			columns: []string{"COLUMN_NAME"}, rows: [][]driver.Value{{"id"}},
+		},
+		"SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'logs' AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION": {
+			columns: []string{"COLUMN_NAME"},
+		},
+		"SELECT * FROM `users`": {
+			columns: []string{"id", "email", "note", "avatar", "token"},
+			types:   []string{"INT", "VARCHAR", "TEXT", "BLOB", "BLOB"},
+			rows: [][]driver.Value{
+				{int64(1), []byte("admin@example.com"), []byte(""), []byte("\x89PNG\x00\x01"), []byte("abc123")},
+				{int64(2), []byte("bob,\"jr\"@example.com"), nil, nil, []byte("tab\there")},
+			},
+		},
+		"SELECT * FROM `logs`": {
+			columns: []string{"message"},
+			rows:    [][]driver.Value{{[]byte("login failed")}},
+		},
+		"SELECT `id`, `email`, `token` FROM `users`": {
+			columns: []string{"id", "email", "token"},
+			types:   []string{"INT", "VARCHAR", "BLOB"},
+			rows:    [][]driver.Value{{int64(1), []byte("admin@example.com"), []byte("abc123")}},
+		},
+		"SELECT TABLE_NAME, TABLE_ROWS, DATA_LENGTH, INDEX_LENGTH FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE()": {
+			columns: []string{"TABLE_NAME", "TABLE_ROWS", "DATA_LENGTH", "INDEX_LENGTH"},
+			rows:    [][]driver.Value{{"logs", int64(5000), int64(65536), nil}, {"users", int64(2), int64(16384), int64(16384)}},
+		},
+		"SELECT TABLE_NAME, COLUMN_NAME, DATA_TYPE, COLUMN_KEY FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME, ORDINAL_POSITION": {
+			columns: []string{"TABLE_NAME", "COLUMN_NAME", "DATA_TYPE", "COLUMN_KEY"},
+			rows: [][]driver.Value{
+				{"logs", "message", "text", ""},
+				{"users", "id", "int", "PRI"},
+				{"users", "email", "varchar", "UNI"},
+				{"users", "note", "text", ""},
+				{"users", "avatar", "blob", ""},
+				{"users", "token", "blob", ""},
+			},
+		},
+	}}
+}
+
+func TestGetTypedRows(t *testing.T) {
+	client := newFakeSQLClient(fakeShopDB(), "shop", SQLOptions{})
+	columns, rows, err := client.GetTypedRows(context.Background(), "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedColumns := []SQLColumn{
+		{"id", "INT", false, true, ""},
+		{"email", "VARCHAR", false, false, "email"},
+		{"note", "TEXT", false, false, ""},
+		{"avatar", "BLOB", true, false, ""},
+		{"token", "BLOB", true, false, "api_key"},
+	}
+	if !reflect.DeepEqual(columns, expectedColumns) {
+		t.Errorf("Wrong columns %v", columns)
+	}
+
+	all := []*SQLRow{}
+	for row := range rows {
+		all = append(all, row)
+	}
+	if len(all) != 2 {
+		t.Fatalf("Got %d rows", len(all))
+	}
+	first, second := all[0], all[1]
+	if first.FullName() != "shop.users" || !reflect.DeepEqual(first.Key, []string{"id"}) || string(first.Value("id").Data) != "1" {
+		t.Errorf("Wrong row %+v", first)
+	}
+	// Empty is not NULL, BLOBs holding text are text
+	if note := first.Value("note"); note.Null || len(note.Data) != 0 {
+		t.Errorf("Empty note is %+v", note)
+	}
+	if !first.Value("avatar").Binary || first.Value("token").Binary {
+		t.Errorf("Wrong binary detection %+v", first.Values)
+	}
+	if !second.Value("note").Null || !second.Value("avatar").Null {
+		t.Errorf("NULL values are %+v", second.Values)
+	}
+}
+
+func TestSQLDumpFormats(t *testing.T) {
+	tests := []struct {
+		options SQLOptions
+		dump    string
+	}{
+		{SQLOptions{DumpFormat: SQLDumpJSON},
+			`{"db":"shop","table":"users","pk":{"id":"1"},"columns":{"id":"1","email":"admin@example.com","note":"","avatar":"iVBORwAB","token":"abc123"}}` + "\n" +
+				`{"db":"shop","table":"users","pk":{"id":"2"},"columns":{"id":"2","email":"bob,\"jr\"@example.com","note":null,"avatar":null,"token":"tab\there"}}` + "\n" +
+				`{"db":"shop","table":"logs","pk":null,"columns":{"message":"login failed"}}` + "\n"},
+		{SQLOptions{DumpFormat: SQLDumpCSV},
+			"shop.users\nid,email,note,avatar,token\n1,admin@example.com,,iVBORwAB,abc123\n2,\"bob,\"\"jr\"\"@example.com\",\\N,\\N,tab\there\n" +
+				"\nshop.logs\nmessage\nlogin failed\n"},
+		{SQLOptions{DumpFormat: SQLDumpDelimited},
+			"shop.users\nid\temail\tnote\tavatar\ttoken\n1\tadmin@example.com\t\tiVBORwAB\tabc123\n2\tbob,\"jr\"@example.com\t\\N\t\\N\ttab\\there\n" +
+				"\nshop.logs\nmessage\nlogin failed\n"},
+		{SQLOptions{DumpFormat: SQLDumpDelimited, Delimiter: "|"},
+			"shop.users\nid|email|note|avatar|token\n1|admin@example.com||iVBORwAB|abc123\n2|bob,\"jr\"@example.com|\\N|\\N|tab\\there\n" +
+				"\nshop.logs\nmessage\nlogin failed\n"},
+	}
+
+	for _, test := range tests {
+		client := newFakeSQLClient(fakeShopDB(), "shop", test.options)
+		reader, err := client.Dump(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		dump, err := ioutil.ReadAll(reader)
+		if err != nil || string(dump) != test.dump {
+			t.Errorf("Wrong %s dump %v:\n%s", test.options.DumpFormat, err, dump)
+		}
+	}
+}
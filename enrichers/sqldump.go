@@ -0,0 +1,744 @@
+package enrichers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// sqlDumpHeaders Comments at the start of dumps written by common tools
+var sqlDumpHeaders = [][]byte{
+	[]byte("-- MySQL dump"),
+	[]byte("-- MariaDB dump"),
+	[]byte("-- PostgreSQL database dump"),
+	[]byte("-- PostgreSQL database cluster dump"),
+	[]byte("-- phpMyAdmin SQL Dump"),
+	[]byte("-- Adminer"),
+}
+
+// sqlConstraintKeywords Words starting a part of CREATE TABLE that is not a column
+var sqlConstraintKeywords = map[string]bool{
+	"PRIMARY": true, "KEY": true, "INDEX": true, "UNIQUE": true, "CONSTRAINT": true, "FOREIGN": true,
+	"FULLTEXT": true, "SPATIAL": true, "CHECK": true, "EXCLUDE": true, "LIKE": true, "PERIOD": true,
+}
+
+// IsSQLDump Check if the data is a SQL dump from its name (.sql) or the header comment written by mysqldump,
+// pg_dump, and other tools
+func IsSQLDump(name string, data []byte) bool {
+	name = strings.SplitN(strings.SplitN(name, "?", 2)[0], "#", 2)[0]
+	if strings.EqualFold(path.Ext(name), ".sql") {
+		return true
+	}
+
+	start := data
+	if len(start) > sniffLength {
+		start = start[0:sniffLength]
+	}
+	for _, header := range sqlDumpHeaders {
+		if bytes.Contains(start, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompressedSQLDump Check if the data is a gzipped dump named like dump.sql.gz
+func isCompressedSQLDump(name string, data []byte) bool {
+	name = strings.SplitN(strings.SplitN(name, "?", 2)[0], "#", 2)[0]
+	return strings.HasSuffix(strings.ToLower(name), ".sql.gz") && ArchiveFormat(data) == "gzip"
+}
+
+// ReadSQLDump Get every row inserted or copied by a mysqldump or pg_dump plain format dump.  Column names come
+// from the INSERT or COPY statement, or the CREATE TABLE statement before it.  Statements that can not be parsed
+// are skipped
+func ReadSQLDump(ctx context.Context, reader io.Reader) chan *SQLRow {
+	rows := make(chan *SQLRow)
+
+	go func() {
+		defer close(rows)
+
+		newSQLDumpParser(reader).parse(func(row *SQLRow) bool {
+			select {
+			case rows <- row:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	return rows
+}
+
+// sqlDumpItems Call emit with an item per row of the dump as it is parsed, named like dump.sql#database.table with
+// the row as a JSON line, until it returns false.  The table of PostgreSQL rows is given without its schema, which has
+// its own metadata.  found is false if the dump has no rows, more is false if emit returned false
+func sqlDumpItems(dump *Item, emit func(item *Item) bool) (found, more bool) {
+	rowNumbers := map[string]int{}
+	more = true
+	newSQLDumpParser(bytes.NewReader(dump.Data)).parse(func(row *SQLRow) bool {
+		found = true
+		name := row.FullName()
+		rowNumbers[name]++
+
+		item := childItem(dump, name, append(row.JSON(), '\n'))
+		item.Metadata["database"] = row.Database
+		item.Metadata["table"] = row.Table
+		if row.Schema != "" {
+			item.Metadata["schema"] = row.Schema
+			item.Metadata["table"] = strings.TrimPrefix(row.Table, row.Schema+".")
+		}
+		item.Metadata["columns"] = strings.Join(row.Columns, ",")
+		item.Metadata["row"] = strconv.Itoa(rowNumbers[name])
+		more = emit(item)
+		return more
+	})
+	return found, more
+}
+
+// sqlColumnNumber Name of a column the dump did not name, numbered from 1
+func sqlColumnNumber(i int) string {
+	return strconv.Itoa(i + 1)
+}
+
+// sqlDumpParser State of reading the statements of a dump
+type sqlDumpParser struct {
+	reader           *bufio.Reader
+	postgres         bool                     // Double quotes are identifiers and names have schemas
+	backslashEscapes bool                     // Backslashes escape characters in strings
+	database         string                   // Database from the last USE or \connect
+	tables           map[string]*sqlDumpTable // Tables from CREATE TABLE by full name
+}
+
+// sqlDumpTable Columns of a table from its CREATE TABLE statement
+type sqlDumpTable struct {
+	columns []string
+	key     []string // Primary key columns
+}
+
+// newSQLDumpParser Create a parser of the dump.  Dumps are MySQL until they show they are PostgreSQL
+func newSQLDumpParser(reader io.Reader) *sqlDumpParser {
+	return &sqlDumpParser{
+		reader:           bufio.NewReader(reader),
+		backslashEscapes: true,
+		tables:           map[string]*sqlDumpTable{},
+	}
+}
+
+// parse Parse every statement, calling emit with every row until it returns false
+func (parser *sqlDumpParser) parse(emit func(row *SQLRow) bool) {
+	for {
+		statement, err := parser.nextStatement()
+		if len(statement) > 0 && !parser.statement(statement, emit) {
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// nextStatement Read up to the next ; outside of strings and comments.  psql meta commands such as \connect end at
+// the end of their line
+func (parser *sqlDumpParser) nextStatement() ([]byte, error) {
+	statement := []byte{}
+	for {
+		c, err := parser.reader.ReadByte()
+		if err != nil {
+			return statement, err
+		}
+
+		switch {
+		case c == ';':
+			return statement, nil
+		case c == '\'' || c == '"' || c == '`':
+			// E'' strings in PostgreSQL always have escapes
+			escapes := c == '\'' && len(statement) > 0 && (statement[len(statement)-1] == 'E' || statement[len(statement)-1] == 'e')
+			escapes = escapes || (parser.backslashEscapes && c != '`' && !(parser.postgres && c == '"'))
+			statement = append(statement, c)
+			if statement, err = parser.readQuoted(statement, c, escapes); err != nil {
+				return statement, err
+			}
+		case c == '-' && parser.next('-'):
+			line, err := parser.reader.ReadBytes('\n')
+			if bytes.Contains(line, []byte("PostgreSQL database")) {
+				parser.setPostgres()
+			}
+			if err != nil {
+				return statement, err
+			}
+		case c == '#' && !parser.postgres:
+			if _, err := parser.reader.ReadBytes('\n'); err != nil {
+				return statement, err
+			}
+		case c == '/' && parser.next('*'):
+			if err := parser.skipComment(); err != nil {
+				return statement, err
+			}
+		case c == '\\' && len(bytes.TrimSpace(statement)) == 0:
+			line, err := parser.reader.ReadBytes('\n')
+			return append([]byte{'\\'}, bytes.TrimSpace(line)...), err
+		default:
+			statement = append(statement, c)
+		}
+	}
+}
+
+// next Check if the next byte is c and read it if it is
+func (parser *sqlDumpParser) next(c byte) bool {
+	next, err := parser.reader.Peek(1)
+	if err != nil || next[0] != c {
+		return false
+	}
+	parser.reader.ReadByte()
+	return true
+}
+
+// readQuoted Read the rest of a string or quoted name into the statement, including the closing quote
+func (parser *sqlDumpParser) readQuoted(statement []byte, quote byte, escapes bool) ([]byte, error) {
+	for {
+		c, err := parser.reader.ReadByte()
+		if err != nil {
+			return statement, err
+		}
+		statement = append(statement, c)
+		if escapes && c == '\\' {
+			if c, err = parser.reader.ReadByte(); err != nil {
+				return statement, err
+			}
+			statement = append(statement, c)
+		} else if c == quote {
+			// Doubled quotes are a quote
+			if !parser.next(quote) {
+				return statement, nil
+			}
+			statement = append(statement, quote)
+		}
+	}
+}
+
+// skipComment Skip to the end of a /* comment
+func (parser *sqlDumpParser) skipComment() error {
+	star := false
+	for {
+		c, err := parser.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if star && c == '/' {
+			return nil
+		}
+		star = c == '*'
+	}
+}
+
+// setPostgres Switch to PostgreSQL, where backslashes are plain characters in strings
+func (parser *sqlDumpParser) setPostgres() {
+	if !parser.postgres {
+		parser.postgres = true
+		parser.backslashEscapes = false
+	}
+}
+
+// statement Handle a statement, calling emit with its rows.  false if emit asked to stop
+func (parser *sqlDumpParser) statement(statement []byte, emit func(row *SQLRow) bool) bool {
+	// psql meta commands
+	if statement[0] == '\\' {
+		fields := strings.Fields(string(statement))
+		if (fields[0] == `\connect` || fields[0] == `\c`) && len(fields) > 1 {
+			parser.setPostgres()
+			// Options such as -reuse-previous come before the database
+			for _, field := range fields[1:] {
+				if !strings.HasPrefix(field, "-") {
+					// A database name or a connection string like "dbname='shop'"
+					database := strings.Trim(field, `"`)
+					if index := strings.Index(database, "dbname="); index != -1 {
+						database = strings.Fields(database[index+len("dbname="):])[0]
+					}
+					parser.database = strings.Trim(database, `'`)
+					break
+				}
+			}
+		}
+		return true
+	}
+
+	scanner := &sqlScanner{data: statement, parser: parser}
+	switch strings.ToUpper(scanner.word()) {
+	case "USE":
+		parser.database = scanner.identifier()
+	case "SET":
+		setting := strings.ToLower(string(statement))
+		if strings.Contains(setting, "standard_conforming_strings") {
+			parser.setPostgres()
+			parser.backslashEscapes = strings.Contains(setting, "off")
+		}
+	case "CREATE":
+		parser.createTable(scanner)
+	case "INSERT", "REPLACE":
+		return parser.insert(scanner, emit)
+	case "COPY":
+		return parser.copy(scanner, emit)
+	}
+	return true
+}
+
+// table Get the database, schema, and table of a name from a statement.  Only PostgreSQL names have a schema, which is
+// kept in the table too
+func (parser *sqlDumpParser) table(name []string) (database, schema, table string) {
+	if len(name) == 0 {
+		return parser.database, "", ""
+	}
+	if parser.postgres {
+		return parser.database, strings.Join(name[0:len(name)-1], "."), strings.Join(name, ".")
+	}
+	if len(name) >= 2 {
+		return name[len(name)-2], "", name[len(name)-1]
+	}
+	return parser.database, "", name[0]
+}
+
+// createTable Record the columns of a CREATE TABLE statement
+func (parser *sqlDumpParser) createTable(scanner *sqlScanner) {
+	// CREATE [TEMPORARY | UNLOGGED | ...] TABLE [IF NOT EXISTS] name (
+	for {
+		word := strings.ToUpper(scanner.word())
+		if word == "TABLE" {
+			break
+		} else if word == "" {
+			return
+		}
+	}
+	scanner.skipWords("IF", "NOT", "EXISTS")
+	database, _, table := parser.table(scanner.name())
+	if !scanner.consume('(') {
+		return
+	}
+
+	created := &sqlDumpTable{columns: []string{}}
+	for {
+		scanner.skipSpace()
+		quoted := scanner.peek() == '`' || scanner.peek() == '"' || scanner.peek() == '['
+		column := scanner.identifier()
+		if column == "" {
+			break
+		}
+
+		keyword := strings.ToUpper(column)
+		if !quoted && sqlConstraintKeywords[keyword] {
+			// [CONSTRAINT name] PRIMARY KEY (columns)
+			if keyword == "CONSTRAINT" {
+				scanner.identifier()
+				keyword = strings.ToUpper(scanner.word())
+			}
+			if keyword == "PRIMARY" && strings.ToUpper(scanner.word()) == "KEY" {
+				created.key = scanner.columnList()
+			}
+			scanner.expression()
+		} else {
+			created.columns = append(created.columns, column)
+			// Type and constraints of the column
+			if definition := scanner.expression(); bytes.Contains(bytes.ToUpper(definition), []byte("PRIMARY KEY")) {
+				created.key = []string{column}
+			}
+		}
+
+		if !scanner.consume(',') {
+			break
+		}
+	}
+	parser.tables[database+"."+table] = created
+}
+
+// columns Get the columns of the table from its CREATE TABLE statement, unless the statement names them
+func (parser *sqlDumpParser) columns(database, table string, named []string) (columns, key []string) {
+	created := parser.tables[database+"."+table]
+	if created == nil {
+		return named, nil
+	}
+	if named == nil {
+		return created.columns, created.key
+	}
+	return named, created.key
+}
+
+// insert Emit the rows of an INSERT statement
+func (parser *sqlDumpParser) insert(scanner *sqlScanner, emit func(row *SQLRow) bool) bool {
+	// INSERT [LOW_PRIORITY | DELAYED | HIGH_PRIORITY] [IGNORE] [INTO] name [(columns)] VALUES (...), (...)
+	scanner.skipWords("LOW_PRIORITY", "DELAYED", "HIGH_PRIORITY", "IGNORE", "INTO")
+	database, schema, table := parser.table(scanner.name())
+	columns, key := parser.columns(database, table, scanner.columnList())
+	if word := strings.ToUpper(scanner.word()); word != "VALUES" && word != "VALUE" {
+		return true
+	}
+
+	for scanner.consume('(') {
+		values := []SQLValue{}
+		for {
+			values = append(values, scanner.value())
+			if !scanner.consume(',') {
+				break
+			}
+		}
+		if !scanner.consume(')') {
+			return true
+		}
+		if !emit(newSQLRow(database, schema, table, columns, key, values)) {
+			return false
+		}
+		scanner.consume(',')
+	}
+	return true
+}
+
+// copy Emit the rows of a COPY ... FROM stdin statement, which are the lines after it up to \.
+func (parser *sqlDumpParser) copy(scanner *sqlScanner, emit func(row *SQLRow) bool) bool {
+	database, schema, table := parser.table(scanner.name())
+	columns, key := parser.columns(database, table, scanner.columnList())
+	if strings.ToUpper(scanner.word()) != "FROM" || strings.ToUpper(scanner.word()) != "STDIN" {
+		return true
+	}
+	parser.setPostgres()
+
+	// Rest of the statement's line
+	if _, err := parser.reader.ReadBytes('\n'); err != nil {
+		return true
+	}
+	for {
+		line, err := parser.reader.ReadBytes('\n')
+		line = bytes.TrimRight(line, "\r\n")
+		if bytes.Equal(line, []byte(`\.`)) || (err != nil && len(line) == 0) {
+			return true
+		}
+
+		values := []SQLValue{}
+		for _, field := range bytes.Split(line, []byte{'\t'}) {
+			values = append(values, copyValue(field))
+		}
+		if !emit(newSQLRow(database, schema, table, columns, key, values)) {
+			return false
+		}
+		if err != nil {
+			return true
+		}
+	}
+}
+
+// newSQLRow Create a row, numbering the columns if there are no names for all of them
+func newSQLRow(database, schema, table string, columns, key []string, values []SQLValue) *SQLRow {
+	if len(columns) < len(values) {
+		named := append([]string{}, columns...)
+		for i := len(columns); i < len(values); i++ {
+			named = append(named, sqlColumnNumber(i))
+		}
+		columns = named
+	}
+	return &SQLRow{Database: database, Schema: schema, Table: table, Columns: columns, Key: key, Values: values}
+}
+
+// copyValue Decode a field of COPY text format.  \N is NULL and bytea values like \\x89504e47 are binary
+func copyValue(field []byte) SQLValue {
+	if bytes.Equal(field, []byte(`\N`)) {
+		return newSQLValue(nil, false)
+	}
+	if bytes.HasPrefix(field, []byte(`\\x`)) {
+		if decoded, err := hex.DecodeString(string(field[3:])); err == nil {
+			return newSQLValue(decoded, true)
+		}
+	}
+	return newSQLValue(unescapeCopy(field), false)
+}
+
+// unescapeCopy Decode the backslash escapes of a field of COPY text format
+func unescapeCopy(field []byte) []byte {
+	if bytes.IndexByte(field, '\\') == -1 {
+		return field
+	}
+
+	value := make([]byte, 0, len(field))
+	for i := 0; i < len(field); i++ {
+		if field[i] != '\\' || i+1 >= len(field) {
+			value = append(value, field[i])
+			continue
+		}
+		i++
+		switch c := field[i]; c {
+		case 'b':
+			value = append(value, '\b')
+		case 'f':
+			value = append(value, '\f')
+		case 'n':
+			value = append(value, '\n')
+		case 'r':
+			value = append(value, '\r')
+		case 't':
+			value = append(value, '\t')
+		case 'v':
+			value = append(value, '\v')
+		case 'x':
+			// One or two hex digits
+			end := i + 1
+			for end < len(field) && end < i+3 && isHexDigit(field[end]) {
+				end++
+			}
+			number, _ := strconv.ParseUint(string(field[i+1:end]), 16, 8)
+			value = append(value, byte(number))
+			i = end - 1
+		default:
+			if c >= '0' && c <= '7' {
+				// One to three octal digits
+				end := i
+				for end < len(field) && end < i+3 && field[end] >= '0' && field[end] <= '7' {
+					end++
+				}
+				number, _ := strconv.ParseUint(string(field[i:end]), 8, 8)
+				value = append(value, byte(number))
+				i = end - 1
+			} else {
+				value = append(value, c)
+			}
+		}
+	}
+	return value
+}
+
+// isHexDigit Check if the byte is a hex digit
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// sqlScanner Reads the parts of one statement
+type sqlScanner struct {
+	data   []byte
+	pos    int
+	parser *sqlDumpParser
+}
+
+// peek Get the next byte, 0 at the end
+func (scanner *sqlScanner) peek() byte {
+	if scanner.pos >= len(scanner.data) {
+		return 0
+	}
+	return scanner.data[scanner.pos]
+}
+
+// skipSpace Skip whitespace
+func (scanner *sqlScanner) skipSpace() {
+	for scanner.pos < len(scanner.data) {
+		switch scanner.data[scanner.pos] {
+		case ' ', '\t', '\r', '\n', '\f':
+			scanner.pos++
+		default:
+			return
+		}
+	}
+}
+
+// consume Skip whitespace and read c if it is next
+func (scanner *sqlScanner) consume(c byte) bool {
+	scanner.skipSpace()
+	if scanner.peek() != c {
+		return false
+	}
+	scanner.pos++
+	return true
+}
+
+// word Read a bare word such as a keyword, name, or number
+func (scanner *sqlScanner) word() string {
+	scanner.skipSpace()
+	start := scanner.pos
+	for scanner.pos < len(scanner.data) {
+		c := scanner.data[scanner.pos]
+		if !(c == '_' || c == '$' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80) {
+			break
+		}
+		scanner.pos++
+	}
+	return string(scanner.data[start:scanner.pos])
+}
+
+// skipWords Skip any of the words, in any order
+func (scanner *sqlScanner) skipWords(words ...string) {
+	for {
+		start := scanner.pos
+		word := strings.ToUpper(scanner.word())
+		skipped := false
+		for _, skip := range words {
+			if word == skip {
+				skipped = true
+			}
+		}
+		if !skipped {
+			scanner.pos = start
+			return
+		}
+	}
+}
+
+// identifier Read a name that may be quoted with backticks, double quotes, or brackets
+func (scanner *sqlScanner) identifier() string {
+	scanner.skipSpace()
+	switch quote := scanner.peek(); quote {
+	case '`', '"':
+		return string(scanner.quoted(false))
+	case '[':
+		end := bytes.IndexByte(scanner.data[scanner.pos:], ']')
+		if end == -1 {
+			return ""
+		}
+		name := scanner.data[scanner.pos+1 : scanner.pos+end]
+		scanner.pos += end + 1
+		return string(name)
+	}
+	return scanner.word()
+}
+
+// name Read a name made of identifiers separated by dots such as shop.users
+func (scanner *sqlScanner) name() []string {
+	name := []string{}
+	for {
+		part := scanner.identifier()
+		if part == "" {
+			return name
+		}
+		name = append(name, part)
+		if scanner.peek() != '.' {
+			return name
+		}
+		scanner.pos++
+	}
+}
+
+// columnList Read a list of column names in parentheses.  nil if there is none
+func (scanner *sqlScanner) columnList() []string {
+	if !scanner.consume('(') {
+		return nil
+	}
+	columns := []string{}
+	for {
+		columns = append(columns, scanner.identifier())
+		if !scanner.consume(',') {
+			break
+		}
+	}
+	scanner.consume(')')
+	return columns
+}
+
+// quoted Read a quoted string or name and get its value
+func (scanner *sqlScanner) quoted(escapes bool) []byte {
+	quote := scanner.data[scanner.pos]
+	scanner.pos++
+	value := []byte{}
+	for scanner.pos < len(scanner.data) {
+		c := scanner.data[scanner.pos]
+		scanner.pos++
+		if escapes && c == '\\' && scanner.pos < len(scanner.data) {
+			value = append(value, sqlUnescape(scanner.data[scanner.pos]))
+			scanner.pos++
+			continue
+		}
+		if c == quote {
+			if scanner.peek() != quote {
+				return value
+			}
+			scanner.pos++
+		}
+		value = append(value, c)
+	}
+	return value
+}
+
+// sqlUnescape Get the character of a MySQL backslash escape
+func sqlUnescape(c byte) byte {
+	switch c {
+	case '0':
+		return 0
+	case 'b':
+		return '\b'
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'Z':
+		return 0x1a
+	}
+	return c
+}
+
+// expression Read up to the next , or ) outside of parentheses and strings and get it without surrounding space
+func (scanner *sqlScanner) expression() []byte {
+	start := scanner.pos
+	depth := 0
+	for scanner.pos < len(scanner.data) {
+		switch c := scanner.data[scanner.pos]; c {
+		case '\'', '"', '`':
+			scanner.quoted(c == '\'' && scanner.parser.backslashEscapes)
+			continue
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return bytes.TrimSpace(scanner.data[start:scanner.pos])
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return bytes.TrimSpace(scanner.data[start:scanner.pos])
+			}
+		}
+		scanner.pos++
+	}
+	return bytes.TrimSpace(scanner.data[start:scanner.pos])
+}
+
+// value Read a value in a VALUES list.  Strings are unquoted, including ones with a prefix such as _binary or E and
+// ones with a cast such as ::jsonb, and hex blobs are decoded as binary.  Other values, such as numbers and function
+// calls, are as written
+func (scanner *sqlScanner) value() SQLValue {
+	scanner.skipSpace()
+	start := scanner.pos
+	if scanner.peek() == '\'' || (scanner.peek() == '"' && !scanner.parser.postgres) {
+		value := scanner.quoted(scanner.parser.backslashEscapes)
+		scanner.expression()
+		return newSQLValue(value, false)
+	}
+
+	word := scanner.word()
+	if strings.HasPrefix(word, "_") {
+		// Character set introducers such as _binary can have space before the string
+		scanner.skipSpace()
+	}
+	if word != "" && scanner.peek() == '\'' {
+		prefix := strings.ToUpper(word)
+		value := scanner.quoted(scanner.parser.backslashEscapes || prefix == "E")
+		scanner.expression()
+		if prefix == "X" {
+			if decoded, err := hex.DecodeString(string(value)); err == nil {
+				return newSQLValue(decoded, true)
+			}
+		}
+		return newSQLValue(value, prefix == "_BINARY")
+	}
+
+	scanner.pos = start
+	value := scanner.expression()
+	if strings.EqualFold(string(value), "NULL") {
+		return newSQLValue(nil, false)
+	}
+	// Blobs dumped with --hex-blob
+	if bytes.HasPrefix(value, []byte("0x")) {
+		if decoded, err := hex.DecodeString(string(value[2:])); err == nil {
+			return newSQLValue(decoded, true)
+		}
+	}
+	return newSQLValue(append([]byte{}, value...), false)
+}
@@ -0,0 +1,186 @@
+package enrichers
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mysqlDump = "-- MySQL dump 10.13  Distrib 8.0.19, for Linux (x86_64)\n" +
+	"--\n" +
+	"-- Host: localhost    Database: shop\n" +
+	"/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;\n" +
+	"/*!40101 SET NAMES utf8mb4 */;\n" +
+	"\n" +
+	"USE `shop`;\n" +
+	"DROP TABLE IF EXISTS `users`;\n" +
+	"CREATE TABLE `users` (\n" +
+	"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+	"  `email` varchar(255) DEFAULT NULL,\n" +
+	"  `password` char(60) NOT NULL COMMENT 'bcrypt; never plain',\n" +
+	"  `balance` decimal(10,2) DEFAULT '0.00',\n" +
+	"  `avatar` blob,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE KEY `email` (`email`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+	"\n" +
+	"LOCK TABLES `users` WRITE;\n" +
+	"/*!40000 ALTER TABLE `users` DISABLE KEYS */;\n" +
+	"INSERT INTO `users` VALUES (1,'admin@example.com','$2y$10$abc;def',12.50,0x89504E47),(2,'o\\'brien@example.com','it''s\\nsecret',-3.00,NULL);\n" +
+	"/*!40000 ALTER TABLE `users` ENABLE KEYS */;\n" +
+	"UNLOCK TABLES;\n" +
+	"# Other databases\n" +
+	"INSERT IGNORE INTO `logs`.`events` (`time`, `message`) VALUES (NOW(), _binary 'login -- failed');\n" +
+	"INSERT INTO unknown VALUES (1, \"double\");\n"
+
+const postgresDump = "--\n" +
+	"-- PostgreSQL database dump\n" +
+	"--\n" +
+	"SET standard_conforming_strings = on;\n" +
+	"\\connect -reuse-previous=on \"dbname='crm'\"\n" +
+	"CREATE TABLE public.customers (\n" +
+	"    id integer NOT NULL,\n" +
+	"    name text,\n" +
+	"    notes text,\n" +
+	"    CONSTRAINT customers_pkey PRIMARY KEY (id)\n" +
+	");\n" +
+	"COPY public.customers (id, name, notes) FROM stdin;\n" +
+	"1\tAlice\tcard 4111\\t1111\n" +
+	"2\tBob\t\\N\n" +
+	"\\.\n" +
+	"INSERT INTO public.customers VALUES (3, 'C:\\path', E'tab\\there', '{\"a\": 1}'::jsonb);\n"
+
+// dumpRows Read the rows of a dump as JSON
+func dumpRows(t *testing.T, dump string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows := []string{}
+	for row := range ReadSQLDump(ctx, strings.NewReader(dump)) {
+		rows = append(rows, string(row.JSON()))
+	}
+	return rows
+}
+
+func TestReadSQLDump(t *testing.T) {
+	rows := dumpRows(t, mysqlDump)
+	expected := []string{
+		`{"db":"shop","table":"users","pk":{"id":"1"},"columns":{"id":"1","email":"admin@example.com","password":"$2y$10$abc;def","balance":"12.50","avatar":"iVBORw=="}}`,
+		`{"db":"shop","table":"users","pk":{"id":"2"},"columns":{"id":"2","email":"o'brien@example.com","password":"it's\nsecret","balance":"-3.00","avatar":null}}`,
+		`{"db":"logs","table":"events","pk":null,"columns":{"time":"NOW()","message":"login -- failed"}}`,
+		`{"db":"shop","table":"unknown","pk":null,"columns":{"1":"1","2":"double"}}`,
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Wrong MySQL rows:\n%s", strings.Join(rows, "\n"))
+	}
+
+	rows = dumpRows(t, postgresDump)
+	expected = []string{
+		`{"db":"crm","table":"public.customers","pk":{"id":"1"},"columns":{"id":"1","name":"Alice","notes":"card 4111\t1111"}}`,
+		`{"db":"crm","table":"public.customers","pk":{"id":"2"},"columns":{"id":"2","name":"Bob","notes":null}}`,
+		`{"db":"crm","table":"public.customers","pk":{"id":"3"},"columns":{"id":"3","name":"C:\\path","notes":"tab\there","4":"{\"a\": 1}"}}`,
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Wrong PostgreSQL rows:\n%s", strings.Join(rows, "\n"))
+	}
+}
+
+func TestSQLDumpItems(t *testing.T) {
+	// Rows are given as they are parsed, and the schema of PostgreSQL tables has its own metadata
+	items := []*Item{}
+	found, more := sqlDumpItems(&Item{Name: "crm.sql", Data: []byte(postgresDump)}, func(item *Item) bool {
+		items = append(items, item)
+		return len(items) < 2
+	})
+	if !found || more || len(items) != 2 {
+		t.Fatalf("Wrong items %v %v %v", items, found, more)
+	}
+	metadata := items[1].Metadata
+	if items[1].Name != "crm.sql#crm.public.customers" || metadata["database"] != "crm" || metadata["schema"] != "public" || metadata["table"] != "customers" || metadata["row"] != "2" {
+		t.Errorf("Wrong item %s %v", items[1].Name, metadata)
+	}
+
+	items = expandedItems(&Item{Name: "shop.sql", Data: []byte(mysqlDump)}, expandOptions{sqlDumps: true})
+	if metadata := items[0].Metadata; len(items) != 4 || metadata["database"] != "shop" || metadata["table"] != "users" || metadata["schema"] != "" {
+		t.Errorf("Wrong MySQL items %v", items)
+	}
+	if found, _ := sqlDumpItems(&Item{Name: "empty.sql", Data: []byte("-- nothing")}, func(item *Item) bool { return true }); found {
+		t.Errorf("Found rows in an empty dump")
+	}
+}
+
+func TestIsSQLDump(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		dump bool
+	}{
+		{"backup.sql", "", true},
+		{"http://host/db/BACKUP.SQL?download=1", "", true},
+		{"backup", mysqlDump, true},
+		{"backup", postgresDump, true},
+		{"notes.txt", "INSERT INTO users VALUES (1)", false},
+	}
+	for _, test := range tests {
+		if IsSQLDump(test.name, []byte(test.data)) != test.dump {
+			t.Errorf("Wrong detection of %s", test.name)
+		}
+	}
+}
+
+func TestFTPSQLDumps(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{
+		"/backups/shop.sql.gz": gzipData(t, "", []byte(mysqlDump)),
+		"/crm.sql":             utf16Data(postgresDump, false, true),
+	}}
+	client := connectFakeFTP(t, server, FTPOptions{Concurrency: 1, SQLDumps: true})
+	defer server.Close()
+	defer client.Close()
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []string{`"email":"admin@example.com"`, `"table":"events"`, `"notes":"card 4111\t1111"`} {
+		if !strings.Contains(string(data), row) {
+			t.Errorf("Missing %s in %s", row, data)
+		}
+	}
+}
+
+func TestHTTPSQLDumps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mysqlDump))
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPWithOptions(server.URL+"/dump.sql", HTTPOptions{SQLDumps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	items, err := client.Items(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for item := range items {
+		names = append(names, item.Name+" "+item.Metadata["row"]+" "+item.Metadata["columns"])
+	}
+	expected := []string{
+		server.URL + "/dump.sql#shop.users 1 id,email,password,balance,avatar",
+		server.URL + "/dump.sql#shop.users 2 id,email,password,balance,avatar",
+		server.URL + "/dump.sql#logs.events 1 time,message",
+		server.URL + "/dump.sql#shop.unknown 1 1,2",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Wrong items %v", names)
+	}
+}
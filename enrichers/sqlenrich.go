@@ -0,0 +1,152 @@
+package enrichers
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// SQLEnrichmentVariables Server variables read by SQLClient.Enrich that tell how bad an exposure is
+var SQLEnrichmentVariables = []string{
+	"secure_file_priv", // Where LOAD DATA INFILE and SELECT INTO OUTFILE can read and write.  Empty for anywhere
+	"local_infile",
+	"plugin_dir",
+	"datadir",
+	"general_log",
+	"general_log_file",
+	"log_bin",
+	"have_ssl",
+	"require_secure_transport",
+	"skip_name_resolve",
+	"hostname",
+	"version_compile_os",
+}
+
+// SQLEnrichment What we learned about a SQL server and the account we are logged in as
+type SQLEnrichment struct {
+	Version        string `json:"version"`         // @@version such as 8.0.19 or 10.4.12-MariaDB
+	VersionComment string `json:"version_comment"` // @@version_comment such as MySQL Community Server - GPL
+	Flavor         string `json:"flavor"`          // MySQL, MariaDB, Percona, Aurora, or TiDB
+
+	User   string   `json:"user"`   // Account the server matched us to such as root@%
+	Grants []string `json:"grants"` // SHOW GRANTS of the account
+
+	AllPrivileges bool `json:"all_privileges"`  // Granted ALL PRIVILEGES on every database
+	FilePrivilege bool `json:"file_privilege"`  // Can read and write files on the server within secure_file_priv
+	CanReadHashes bool `json:"can_read_hashes"` // Can read the password hashes of every account from mysql.user
+
+	Variables map[string]string `json:"variables"` // Values of SQLEnrichmentVariables the server has
+}
+
+// Enrich Fingerprint the server and enumerate the privileges of the account over the open connection
+func (client *SQLClient) Enrich(ctx context.Context) (*SQLEnrichment, error) {
+	if client.db == nil {
+		return nil, errors.New("not connected")
+	}
+
+	enrichment := &SQLEnrichment{Grants: []string{}, Variables: map[string]string{}}
+	err := client.db.QueryRowContext(ctx, "SELECT @@version, @@version_comment").Scan(&enrichment.Version, &enrichment.VersionComment)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only Aurora has this variable
+	var auroraVersion string
+	aurora := client.db.QueryRowContext(ctx, "SELECT @@aurora_version").Scan(&auroraVersion) == nil
+	enrichment.Flavor = sqlFlavor(enrichment.Version, enrichment.VersionComment, aurora)
+
+	client.db.QueryRowContext(ctx, "SELECT CURRENT_USER()").Scan(&enrichment.User)
+
+	if rows, err := client.db.QueryContext(ctx, "SHOW GRANTS"); err == nil {
+		for rows.Next() {
+			var grant string
+			if rows.Scan(&grant) == nil {
+				enrichment.Grants = append(enrichment.Grants, grant)
+			}
+		}
+		rows.Close()
+	}
+	for _, grant := range enrichment.Grants {
+		privileges, on := sqlGrantPrivileges(grant)
+		if on != "*.*" {
+			continue
+		}
+		for _, privilege := range privileges {
+			switch privilege {
+			case "ALL", "ALL PRIVILEGES":
+				enrichment.AllPrivileges = true
+				enrichment.FilePrivilege = true
+			case "FILE":
+				enrichment.FilePrivilege = true
+			}
+		}
+	}
+
+	// The hashes are in authentication_string since MySQL 5.7 and in Password before
+	for _, column := range []string{"authentication_string", "Password"} {
+		var hash []byte
+		err := client.db.QueryRowContext(ctx, "SELECT "+column+" FROM mysql.user LIMIT 1").Scan(&hash)
+		if err == nil {
+			enrichment.CanReadHashes = true
+			break
+		}
+	}
+
+	if rows, err := client.db.QueryContext(ctx, sqlVariablesQuery(SQLEnrichmentVariables)); err == nil {
+		for rows.Next() {
+			var name, value string
+			if rows.Scan(&name, &value) == nil {
+				enrichment.Variables[name] = value
+			}
+		}
+		rows.Close()
+	}
+
+	return enrichment, nil
+}
+
+// sqlFlavor Get which MySQL compatible server it is from its version and version comment
+func sqlFlavor(version, versionComment string, aurora bool) string {
+	switch {
+	case strings.Contains(version, "TiDB"):
+		return "TiDB"
+	case strings.Contains(version, "MariaDB") || strings.Contains(versionComment, "MariaDB"):
+		return "MariaDB"
+	case aurora:
+		return "Aurora"
+	case strings.Contains(versionComment, "Percona") || strings.Contains(version, "Percona"):
+		return "Percona"
+	default:
+		return "MySQL"
+	}
+}
+
+// sqlGrantPrivileges Get the privileges of a grant like GRANT SELECT, FILE ON *.* TO `user`@`%` and what they are on
+func sqlGrantPrivileges(grant string) (privileges []string, on string) {
+	upper := strings.ToUpper(grant)
+	if !strings.HasPrefix(upper, "GRANT ") {
+		return nil, ""
+	}
+	onIndex := strings.Index(upper, " ON ")
+	if onIndex == -1 {
+		return nil, ""
+	}
+	target := strings.Fields(grant[onIndex+len(" ON "):])
+	if len(target) == 0 {
+		return nil, ""
+	}
+
+	for _, privilege := range strings.Split(upper[len("GRANT "):onIndex], ",") {
+		privileges = append(privileges, strings.TrimSpace(privilege))
+	}
+	return privileges, strings.Replace(target[0], "`", "", -1)
+}
+
+// sqlVariablesQuery Get the query for the values of the variables
+func sqlVariablesQuery(variables []string) string {
+	quoted := make([]string, len(variables))
+	for i, variable := range variables {
+		quoted[i] = "'" + variable + "'"
+	}
+	return "SHOW VARIABLES WHERE Variable_name IN (" + strings.Join(quoted, ", ") + ")"
+}
@@ -0,0 +1,86 @@
+package enrichers
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestSQLFlavor(t *testing.T) {
+	tests := []struct {
+		version        string
+		versionComment string
+		aurora         bool
+		flavor         string
+	}{
+		{"8.0.19", "MySQL Community Server - GPL", false, "MySQL"},
+		{"10.4.12-MariaDB-1:10.4.12+maria~bionic", "mariadb.org binary distribution", false, "MariaDB"},
+		{"5.7.29-32", "Percona Server (GPL), Release 32, Revision 56bce88", false, "Percona"},
+		{"5.7.12", "MySQL Community Server (GPL)", true, "Aurora"},
+		{"5.7.25-TiDB-v4.0.0", "TiDB Server (Apache License 2.0) Community Edition, MySQL 5.7 compatible", false, "TiDB"},
+	}
+	for _, test := range tests {
+		if flavor := sqlFlavor(test.version, test.versionComment, test.aurora); flavor != test.flavor {
+			t.Errorf("%s is %s instead of %s", test.version, flavor, test.flavor)
+		}
+	}
+}
+
+func TestSQLGrantPrivileges(t *testing.T) {
+	privileges, on := sqlGrantPrivileges("GRANT SELECT, FILE ON *.* TO `app`@`%`")
+	if !reflect.DeepEqual(privileges, []string{"SELECT", "FILE"}) || on != "*.*" {
+		t.Errorf("Wrong grant %v on %s", privileges, on)
+	}
+	privileges, on = sqlGrantPrivileges("GRANT ALL PRIVILEGES ON `shop`.* TO 'app'@'localhost'")
+	if !reflect.DeepEqual(privileges, []string{"ALL PRIVILEGES"}) || on != "shop.*" {
+		t.Errorf("Wrong grant %v on %s", privileges, on)
+	}
+	if privileges, _ := sqlGrantPrivileges("GRANT PROXY"); privileges != nil {
+		t.Errorf("Wrong grant %v", privileges)
+	}
+}
+
+func TestSQLEnrich(t *testing.T) {
+	db := fakeShopDB()
+	db.results["SELECT @@version, @@version_comment"] = &fakeSQLResult{
+		columns: []string{"@@version", "@@version_comment"},
+		rows:    [][]driver.Value{{"5.7.29-32", "Percona Server (GPL), Release 32, Revision 56bce88"}},
+	}
+	db.results["SELECT CURRENT_USER()"] = &fakeSQLResult{columns: []string{"CURRENT_USER()"}, rows: [][]driver.Value{{"app@%"}}}
+	db.results["SHOW GRANTS"] = &fakeSQLResult{columns: []string{"Grants for app@%"}, rows: [][]driver.Value{
+		{"GRANT FILE ON *.* TO 'app'@'%'"},
+		{"GRANT ALL PRIVILEGES ON `shop`.* TO 'app'@'%'"},
+	}}
+	db.results["SELECT Password FROM mysql.user LIMIT 1"] = &fakeSQLResult{columns: []string{"Password"}, rows: [][]driver.Value{{"*81F5E21E35407D884A6CD4A731AEBFB6AF209E1B"}}}
+	db.results[sqlVariablesQuery(SQLEnrichmentVariables)] = &fakeSQLResult{columns: []string{"Variable_name", "Value"}, rows: [][]driver.Value{
+		{"secure_file_priv", ""},
+		{"local_infile", "ON"},
+	}}
+
+	enrichment, err := newFakeSQLClient(db, "shop", SQLOptions{}).Enrich(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &SQLEnrichment{
+		Version:        "5.7.29-32",
+		VersionComment: "Percona Server (GPL), Release 32, Revision 56bce88",
+		Flavor:         "Percona",
+		User:           "app@%",
+		Grants:         []string{"GRANT FILE ON *.* TO 'app'@'%'", "GRANT ALL PRIVILEGES ON `shop`.* TO 'app'@'%'"},
+		FilePrivilege:  true,
+		CanReadHashes:  true,
+		Variables:      map[string]string{"secure_file_priv": "", "local_infile": "ON"},
+	}
+	if !reflect.DeepEqual(enrichment, expected) {
+		t.Errorf("Wrong enrichment %+v", enrichment)
+	}
+
+	// Nothing beyond the version when everything else is denied
+	db = fakeShopDB()
+	db.results["SELECT @@version, @@version_comment"] = &fakeSQLResult{columns: []string{"@@version", "@@version_comment"}, rows: [][]driver.Value{{"8.0.19", "MySQL Community Server - GPL"}}}
+	enrichment, err = newFakeSQLClient(db, "shop", SQLOptions{}).Enrich(context.Background())
+	if err != nil || enrichment.Flavor != "MySQL" || enrichment.CanReadHashes || len(enrichment.Grants) != 0 {
+		t.Errorf("Wrong enrichment %+v %v", enrichment, err)
+	}
+}
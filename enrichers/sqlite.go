@@ -0,0 +1,429 @@
+package enrichers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	// Using pure go SQLite driver
+	_ "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteClient SQLite database file client.  The file is opened read only
+type SQLiteClient struct {
+	path         string // Path of the file on disk
+	name         string // Where the file came from, such as its path or the URL it was read from
+	temporary    bool   // The file was written from data and is removed on close
+	options      SQLOptions
+	db           *sql.DB
+	reader       io.ReadCloser
+	readerCtx    context.Context
+	readerCancel context.CancelFunc
+}
+
+// sqliteMagic Header every SQLite 3 database file starts with
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// sqliteURIEscaper Escapes the characters of a path that mean something in a file: URI
+var sqliteURIEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// sqliteDialect SQLite.  There is no server to kill queries on, and the driver only interrupts the first step of a
+// query when its ctx is canceled, possibly after the database was closed, so queries are interrupted here instead
+var sqliteDialect = sqlDialect{
+	quote:       quoteSQLiteName,
+	placeholder: func(i int) string { return "?" },
+	binaryTypes: map[string]bool{"BLOB": true},
+	random:      "(ABS(RANDOM()) / 9223372036854775807.0)",
+	rowCompares: true,
+	textKeys:    true,
+	interrupt:   sqliteInterrupt,
+}
+
+// NewSQLite Create new SQLite client of a database file path or a URL like sqlite:///var/db/app.db
+func NewSQLite(path string) (*SQLiteClient, error) {
+	return NewSQLiteWithOptions(path, SQLOptions{})
+}
+
+// NewSQLiteWithOptions Create new SQLite client with options.  SensitiveOnly is not supported
+func NewSQLiteWithOptions(path string, options SQLOptions) (*SQLiteClient, error) {
+	if strings.HasPrefix(path, "sqlite://") {
+		path = strings.TrimPrefix(path, "sqlite://")
+	} else {
+		path = strings.TrimPrefix(path, "sqlite:")
+	}
+	if path == "" {
+		return nil, errors.New("no database file")
+	}
+
+	return &SQLiteClient{path: path, name: path, options: options}, nil
+}
+
+// NewSQLiteFromData Create new SQLite client of a database file read from somewhere else, such as a file from an FTP
+// server.  name is where it came from.  The data is written to a temporary file that is removed on Close
+func NewSQLiteFromData(name string, data []byte, options SQLOptions) (*SQLiteClient, error) {
+	if !IsSQLite(data) {
+		return nil, errors.New("not a SQLite database")
+	}
+
+	file, err := ioutil.TempFile("", "genericenricher-*.sqlite")
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &SQLiteClient{path: file.Name(), name: name, temporary: true, options: options}, nil
+}
+
+// IsSQLite Does the data start with the header of a SQLite 3 database file
+func IsSQLite(data []byte) bool {
+	return bytes.HasPrefix(data, sqliteMagic)
+}
+
+// Connect Open the database file read only and check it is a database
+func (client *SQLiteClient) Connect(ctx context.Context) error {
+	db, err := sql.Open("sqlite", "file:"+sqliteURIEscaper.Replace(client.path)+"?mode=ro")
+	if err != nil {
+		return err
+	}
+
+	// Open doesn't open the file, and the file is only read on the first query.  Queries are run without ctx, see
+	// sqliteDialect
+	var tables int
+	err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&tables)
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	client.db = db
+	return nil
+}
+
+// GetIP SQLite files have no IP
+func (client *SQLiteClient) GetIP() net.IP {
+	return nil
+}
+
+// GetPort SQLite files have no port
+func (client *SQLiteClient) GetPort() uint16 {
+	return 0
+}
+
+// GetConnectString Get where the file came from
+func (client *SQLiteClient) GetConnectString() string {
+	return client.name
+}
+
+// IsConnected Is the file open
+func (client *SQLiteClient) IsConnected() bool {
+	if client.db == nil {
+		return false
+	}
+	return client.db.Ping() == nil
+}
+
+// Type Returns SQLite
+func (client *SQLiteClient) Type() ServerType {
+	return SQLite
+}
+
+// Close the file, removing it if it was written from data
+func (client *SQLiteClient) Close() error {
+	// Stop current reader
+	if client.reader != nil {
+		client.readerCancel()
+		err := client.reader.Close()
+		if err != nil {
+			return err
+		}
+		client.reader = nil
+	}
+
+	var err error
+	if client.db != nil {
+		err = client.db.Close()
+		client.db = nil
+	}
+	if client.temporary {
+		if removeErr := os.Remove(client.path); err == nil && !os.IsNotExist(removeErr) {
+			err = removeErr
+		}
+	}
+	return err
+}
+
+// Read every table in the dump format
+func (client *SQLiteClient) Read(p []byte) (n int, err error) {
+	if client.reader == nil {
+		err = client.ResetReader()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return client.reader.Read(p)
+}
+
+// ResetReader reset reader back to initial state
+func (client *SQLiteClient) ResetReader() error {
+	// Stop current reader
+	if client.reader != nil {
+		client.readerCancel()
+		err := client.reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Start new reader
+	client.readerCtx, client.readerCancel = context.WithCancel(context.Background())
+	reader, err := client.Dump(client.readerCtx)
+	if err != nil {
+		return err
+	}
+
+	client.reader = reader
+
+	return nil
+}
+
+// -- SQLite specific functions --
+
+// Dump Dump data of every table in the dump format of the options
+func (client *SQLiteClient) Dump(ctx context.Context) (io.ReadCloser, error) {
+	dumpReader, dumpWriter := io.Pipe()
+
+	go func() {
+		// Stop reading rows when the reader is closed
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		writer := newSQLRowWriter(dumpWriter, client.options.DumpFormat, client.options.Delimiter)
+		for _, table := range client.GetTables() {
+			_, rows, err := client.GetTypedRows(ctx, table)
+			if err != nil {
+				continue
+			}
+			for row := range rows {
+				if err := writer.write(row); err != nil {
+					dumpWriter.CloseWithError(err)
+					return
+				}
+			}
+		}
+		dumpWriter.Close()
+	}()
+
+	return dumpReader, nil
+}
+
+// database Get the name rows are given as their database, the base name of the file
+func (client *SQLiteClient) database() string {
+	name := client.name
+	if index := strings.IndexAny(name, "?#"); index != -1 && strings.Contains(name, "://") {
+		name = name[:index]
+	}
+	return path.Base(name)
+}
+
+// GetTables Get the tables of the database from sqlite_master, without the internal sqlite_ tables
+func (client *SQLiteClient) GetTables() []string {
+	tables := []string{}
+	if client.db == nil {
+		return tables
+	}
+	rows, err := client.db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\' ORDER BY name")
+	if err != nil {
+		return tables
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		if rows.Scan(&table) == nil {
+			tables = append(tables, table)
+		}
+	}
+
+	return tables
+}
+
+// GetRows Get rows of data in table within the limits of the options
+func (client *SQLiteClient) GetRows(ctx context.Context, tableName string) (columnNames []string, rowsChan chan [][]byte) {
+	columns, rows, err := client.GetTypedRows(ctx, tableName)
+	if err != nil {
+		return nil, nil
+	}
+
+	columnNames = make([]string, len(columns))
+	for i, column := range columns {
+		columnNames[i] = column.Name
+	}
+
+	rowsChan = make(chan [][]byte)
+
+	go func() {
+		defer close(rowsChan)
+
+		for row := range rows {
+			// Convert each column to array of bytes, nil for NULL
+			rowBytes := make([][]byte, len(row.Values))
+			for i, value := range row.Values {
+				rowBytes[i] = value.Data
+			}
+
+			select {
+			case rowsChan <- rowBytes:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return columnNames, rowsChan
+}
+
+// GetTypedRows Get the columns of the table and its rows with their primary key and values that tell NULL from empty
+// and binary from text.  Rows are read within the limits of the options
+func (client *SQLiteClient) GetTypedRows(ctx context.Context, tableName string) ([]SQLColumn, chan *SQLRow, error) {
+	if client.db == nil {
+		return nil, nil, errors.New("not connected")
+	}
+	table := sqlTable{database: client.database(), name: tableName, from: quoteSQLiteName(tableName), key: client.primaryKey(tableName)}
+	return readSQLTable(ctx, client.db, &sqliteDialect, table, nil, client.options.Limits)
+}
+
+// primaryKey Get the primary key columns of the table in order.  Empty if it has none
+func (client *SQLiteClient) primaryKey(tableName string) []string {
+	rows, err := client.db.Query("SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk", tableName)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	key := []string{}
+	for rows.Next() {
+		var column string
+		if rows.Scan(&column) == nil {
+			key = append(key, column)
+		}
+	}
+	return key
+}
+
+// Size Get the size of the file and the number of rows of every table
+func (client *SQLiteClient) Size(ctx context.Context) (*ServerSize, error) {
+	if client.db == nil {
+		return nil, errors.New("not connected")
+	}
+	info, err := os.Stat(client.path)
+	if err != nil {
+		return nil, err
+	}
+
+	size := &ServerSize{Bytes: uint64(info.Size()), Complete: true}
+	for _, table := range client.GetTables() {
+		if ctx.Err() != nil {
+			size.Complete = false
+			break
+		}
+		var rows int64
+		if err := client.db.QueryRow("SELECT COUNT(*) FROM " + quoteSQLiteName(table)).Scan(&rows); err != nil {
+			size.Complete = false
+			continue
+		}
+		size.Items += rows
+	}
+
+	return size, ctx.Err()
+}
+
+// sqliteItems Call emit with a JSON item per row of every table of the SQLite database item, named like item#table,
+// until it returns false or ctx is done.  Rows of each table are read within the limits.  opened is false if the
+// database can not be read, more is false if emit returned false or ctx is done
+func sqliteItems(ctx context.Context, database *Item, limits SQLLimits, emit func(item *Item) bool) (opened, more bool) {
+	client, err := NewSQLiteFromData(database.Name, database.Data, SQLOptions{Limits: limits})
+	if err != nil {
+		return false, true
+	}
+	defer client.Close()
+	if err := client.Connect(ctx); err != nil {
+		return false, true
+	}
+	// Stop reading rows when emit does
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, table := range client.GetTables() {
+		_, rows, err := client.GetTypedRows(ctx, table)
+		if err != nil {
+			continue
+		}
+		rowNumber := 0
+		for row := range rows {
+			rowNumber++
+			item := childItem(database, table, append(row.JSON(), '\n'))
+			item.Metadata["database"] = row.Database
+			item.Metadata["table"] = row.Table
+			item.Metadata["columns"] = strings.Join(row.Columns, ",")
+			item.Metadata["row"] = strconv.Itoa(rowNumber)
+			if !emit(item) {
+				// Close the database once the query stopped
+				cancel()
+				for range rows {
+				}
+				return true, false
+			}
+		}
+	}
+	return true, ctx.Err() == nil
+}
+
+// sqliteInterrupt Get a function that interrupts the query running on the connection.  The driver does not export
+// its handle of the database, so it is read from the connection
+func sqliteInterrupt(conn *sql.Conn) (func(), error) {
+	var handle uintptr
+	err := conn.Raw(func(driverConn interface{}) error {
+		value := reflect.ValueOf(driverConn)
+		if value.Kind() == reflect.Ptr {
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Struct {
+			return errors.New("unknown SQLite connection")
+		}
+		db := value.FieldByName("db")
+		if db.Kind() != reflect.Uintptr || db.Uint() == 0 {
+			return errors.New("unknown SQLite connection")
+		}
+		handle = uintptr(db.Uint())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite3_interrupt may be called from any goroutine while the connection is open, and does not use the TLS
+	return func() { sqlite3.Xsqlite3_interrupt(nil, handle) }, nil
+}
+
+// quoteSQLiteName Quote a table or column name with double quotes
+func quoteSQLiteName(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
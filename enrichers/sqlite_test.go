@@ -0,0 +1,292 @@
+package enrichers
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sqliteShop Statements of a small shop database
+const sqliteShop = `CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, password TEXT, avatar BLOB);
+INSERT INTO users VALUES (1, 'admin@example.com', 'hunter2', x'89504e47');
+INSERT INTO users VALUES (2, 'bob@example.com', NULL, NULL);
+CREATE TABLE "order lines" (sku TEXT, quantity INTEGER, price REAL);
+INSERT INTO "order lines" VALUES ('A-1', 2, 12.5);`
+
+// writeSQLiteShop Write the shop database to a file in a new directory.  Remove the directory when done
+func writeSQLiteShop(t *testing.T) (dir, file string) {
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file = filepath.Join(dir, "shop.db")
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(sqliteShop); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir, file
+}
+
+// readSQLiteShop Get the bytes of the shop database file
+func readSQLiteShop(t *testing.T) []byte {
+	dir, file := writeSQLiteShop(t)
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestNewSQLite(t *testing.T) {
+	for _, path := range []string{"sqlite:///var/db/shop.db", "sqlite:/var/db/shop.db", "/var/db/shop.db"} {
+		client, err := NewSQLite(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.path != "/var/db/shop.db" || client.database() != "shop.db" || client.Type() != SQLite || client.IsConnected() {
+			t.Errorf("Wrong client of %s: %s %s", path, client.path, client.database())
+		}
+	}
+	if _, err := NewSQLite("sqlite://"); err == nil {
+		t.Errorf("Accepted no file")
+	}
+	if DetectServerType("sqlite:///var/db/shop.db") != SQLite {
+		t.Errorf("Did not detect SQLite")
+	}
+}
+
+func TestSQLiteDump(t *testing.T) {
+	dir, file := writeSQLiteShop(t)
+	defer os.RemoveAll(dir)
+
+	expected := `{"db":"shop.db","table":"order lines","pk":null,"columns":{"sku":"A-1","quantity":"2","price":"12.5"}}` + "\n" +
+		`{"db":"shop.db","table":"users","pk":{"id":"1"},"columns":{"id":"1","email":"admin@example.com","password":"hunter2","avatar":"iVBORw=="}}` + "\n" +
+		`{"db":"shop.db","table":"users","pk":{"id":"2"},"columns":{"id":"2","email":"bob@example.com","password":null,"avatar":null}}` + "\n"
+
+	for _, limits := range []SQLLimits{{}, {ChunkSize: 1}} {
+		client, err := NewSQLiteWithOptions(file, SQLOptions{DumpFormat: SQLDumpJSON, Limits: limits})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if tables := client.GetTables(); !reflect.DeepEqual(tables, []string{"order lines", "users"}) {
+			t.Errorf("Wrong tables %v", tables)
+		}
+		dump, err := ioutil.ReadAll(client)
+		if err != nil || string(dump) != expected {
+			t.Errorf("Wrong dump with %+v %v:\n%s", limits, err, dump)
+		}
+		client.Close()
+	}
+}
+
+func TestSQLiteGetTypedRows(t *testing.T) {
+	dir, file := writeSQLiteShop(t)
+	defer os.RemoveAll(dir)
+	client, err := NewSQLiteWithOptions(file, SQLOptions{Limits: SQLLimits{MaxRows: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	columns, rows, err := client.GetTypedRows(context.Background(), "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []SQLColumn{
+		{Name: "id", Type: "INTEGER", PrimaryKey: true},
+		{Name: "email", Type: "TEXT", Class: "email"},
+		{Name: "password", Type: "TEXT", Class: "password"},
+		{Name: "avatar", Type: "BLOB", Binary: true},
+	}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Wrong columns %+v", columns)
+	}
+	read := 0
+	for row := range rows {
+		read++
+		if string(row.Value("email").Data) != "admin@example.com" {
+			t.Errorf("Wrong row %+v", row)
+		}
+	}
+	if read != 1 {
+		t.Errorf("Read %d rows", read)
+	}
+
+	size, err := client.Size(context.Background())
+	if err != nil || size.Items != 3 || size.Bytes == 0 || !size.Complete {
+		t.Errorf("Wrong size %+v %v", size, err)
+	}
+}
+
+func TestSQLiteCancel(t *testing.T) {
+	dir, file := writeSQLiteShop(t)
+	defer os.RemoveAll(dir)
+	client, err := NewSQLite(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// Finds a row and then never another, so the driver does not interrupt it itself
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	errs := make(chan error)
+	go func() {
+		query, err := newSQLQuery(ctx, client.db, &sqliteDialect, "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT i FROM n WHERE i = 1 OR i < 0")
+		if err == nil {
+			for query.Next() {
+			}
+			err = query.Err()
+			query.Close()
+		}
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Errorf("Query was not interrupted")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Query was not interrupted")
+	}
+
+	// The connection can still be used
+	_, rows, err := client.GetTypedRows(context.Background(), "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for range rows {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Read %d rows after interrupting", count)
+	}
+}
+
+func TestNewSQLiteFromData(t *testing.T) {
+	if _, err := NewSQLiteFromData("shop.db", []byte("not a database"), SQLOptions{}); err == nil {
+		t.Errorf("Accepted data that is not a database")
+	}
+
+	client, err := NewSQLiteFromData("ftp://127.0.0.1/backups/shop.db", readSQLiteShop(t), SQLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if client.database() != "shop.db" || client.GetConnectString() != "ftp://127.0.0.1/backups/shop.db" {
+		t.Errorf("Wrong names %s %s", client.database(), client.GetConnectString())
+	}
+	path := client.path
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Did not remove %s", path)
+	}
+}
+
+func TestIsSQLite(t *testing.T) {
+	if !IsSQLite(readSQLiteShop(t)) {
+		t.Errorf("Did not detect database")
+	}
+	if IsSQLite([]byte("SQLite format 2")) || IsSQLite([]byte(mysqlDump)) {
+		t.Errorf("Detected a database that is not")
+	}
+}
+
+func TestFTPSQLite(t *testing.T) {
+	server := &fakeFTPServer{Files: map[string][]byte{
+		"/backups/shop.db": readSQLiteShop(t),
+	}}
+	client := connectFakeFTP(t, server, FTPOptions{Concurrency: 1, SQLite: &SQLLimits{}})
+	defer server.Close()
+	defer client.Close()
+
+	data, err := ioutil.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []string{`"email":"admin@example.com"`, `"table":"order lines"`, `"avatar":"iVBORw=="`} {
+		if !strings.Contains(string(data), row) {
+			t.Errorf("Missing %s in %s", row, data)
+		}
+	}
+}
+
+func TestHTTPSQLite(t *testing.T) {
+	database := readSQLiteShop(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(database)
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPWithOptions(server.URL+"/shop.db", HTTPOptions{SQLite: &SQLLimits{MaxRows: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	items, err := client.Items(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for item := range items {
+		names = append(names, item.Name+" "+item.Metadata["row"]+" "+item.Metadata["columns"])
+	}
+	expected := []string{
+		server.URL + "/shop.db#order lines 1 sku,quantity,price",
+		server.URL + "/shop.db#users 1 id,email,password,avatar",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Wrong items %v", names)
+	}
+}
+
+func TestExpandSQLite(t *testing.T) {
+	database := &Item{Name: "shop.db", Data: readSQLiteShop(t)}
+
+	// Stops reading rows when emit does
+	names := []string{}
+	more := expandItem(context.Background(), database, expandOptions{sqlite: &SQLLimits{}}, func(item *Item) bool {
+		names = append(names, item.Name)
+		return len(names) < 2
+	})
+	if more || !reflect.DeepEqual(names, []string{"shop.db#order lines", "shop.db#users"}) {
+		t.Errorf("Wrong items %v %v", names, more)
+	}
+
+	// Kept as it is without the option
+	items := expandedItems(database, expandOptions{})
+	if len(items) != 1 || items[0].Name != "shop.db" {
+		t.Errorf("Wrong items %v", items)
+	}
+}
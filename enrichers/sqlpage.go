@@ -0,0 +1,429 @@
+package enrichers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLLimits How much of each table to read, like maxDocsToCheck of ELKClient.GetIndicesMatchingRules.  Zero values
+// are unlimited
+type SQLLimits struct {
+	MaxRows  int64 // Rows to read of each table
+	MaxBytes int64 // Bytes of values to read of each table.  Reading stops after the row that reaches it
+
+	// SampleRate Fraction of rows to read at random, between 0 and 1, picked by the server.  The server still scans
+	// the table, so combine it with MaxRows to stop early
+	SampleRate float64
+
+	// ChunkSize Rows to read per query.  Tables with a primary key are read in key order, each query starting after the
+	// key of the last row.  Other tables are read with OFFSET, which gets slower as it goes
+	ChunkSize int64
+}
+
+// sqlKillTimeout How long to wait to kill a canceled query on the server
+const sqlKillTimeout = time.Second * 5
+
+// sqlInterruptInterval How often to interrupt a canceled query until it stops
+const sqlInterruptInterval = time.Millisecond * 100
+
+// sqlDialect How a kind of SQL server quotes names, pages through tables, and kills queries
+type sqlDialect struct {
+	quote       func(name string) string
+	placeholder func(i int) string // Placeholder of the ith argument, from 1
+	binaryTypes map[string]bool    // Database types of columns holding binary data
+
+	connectionID string // Query for the id of the connection.  Empty if the driver cancels queries on the server itself
+	kill         string // Query to kill the query running on a connection, with %d for its id
+	random       string // Expression of a random number from 0 to 1, different for each row
+
+	top         bool // Limit with TOP and page with OFFSET FETCH instead of LIMIT and OFFSET
+	rowCompares bool // Compares rows like (a, b) > (1, 2)
+	textKeys    bool // Bind keys of text and number columns as strings, as bytes compare apart from them
+
+	// interrupt Get a function that stops the query running on the connection, for drivers that can not be trusted
+	// to stop queries themselves when ctx is canceled.  Their queries run without ctx and are interrupted until the
+	// rows are closed.  nil to leave it to the driver
+	interrupt func(conn *sql.Conn) (func(), error)
+}
+
+// mysqlDialect MySQL and compatible servers
+var mysqlDialect = sqlDialect{
+	quote:        quoteSQLName,
+	placeholder:  func(i int) string { return "?" },
+	binaryTypes:  sqlBinaryTypes,
+	connectionID: "SELECT CONNECTION_ID()",
+	kill:         "KILL QUERY %d",
+	random:       "RAND()",
+	rowCompares:  true,
+}
+
+// sqlTable A table to read and what we know about it
+type sqlTable struct {
+	database string
+	name     string   // Name of the table in rows, with the schema if there is one
+	from     string   // Table as it goes in the query
+	key      []string // Primary key columns.  Empty if it has none
+}
+
+// readSQLTable Get the columns and rows of the table within the limits, reading only the named columns unless
+// selected is nil.  Each page is queried on its own connection so canceling ctx kills it on the server
+func readSQLTable(ctx context.Context, db *sql.DB, dialect *sqlDialect, table sqlTable, selected []string, limits SQLLimits) ([]SQLColumn, chan *SQLRow, error) {
+	pager := &sqlPager{dialect: dialect, from: table.from, columns: "*", key: table.key, limits: limits}
+	if selected != nil {
+		quoted := make([]string, len(selected))
+		for i, column := range selected {
+			quoted[i] = dialect.quote(column)
+		}
+		pager.columns = strings.Join(quoted, ", ")
+	}
+	pageSize := pager.limit()
+	query, args := pager.query()
+	rows, err := newSQLQuery(ctx, db, dialect, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		rows.Close()
+		return nil, nil, err
+	}
+
+	columns := make([]SQLColumn, len(columnTypes))
+	names := make([]string, len(columnTypes))
+	for i, columnType := range columnTypes {
+		names[i] = columnType.Name()
+		columns[i] = SQLColumn{Name: names[i], Type: strings.ToUpper(columnType.DatabaseTypeName())}
+		columns[i].Binary = dialect.binaryTypes[columns[i].Type]
+		columns[i].Class = ClassifySQLColumn(names[i], columns[i].Type)
+		for _, keyColumn := range table.key {
+			if keyColumn == names[i] {
+				columns[i].PrimaryKey = true
+			}
+		}
+	}
+	pager.textKey = make([]bool, len(table.key))
+	for i, keyColumn := range table.key {
+		for _, column := range columns {
+			if column.Name == keyColumn {
+				pager.textKey[i] = !column.Binary
+			}
+		}
+	}
+
+	rowsChan := make(chan *SQLRow)
+	go func() {
+		defer close(rowsChan)
+		defer func() {
+			if rows != nil {
+				rows.Close()
+			}
+		}()
+
+		scanned := make([]interface{}, len(columns))
+		for i := range scanned {
+			scanned[i] = new(interface{})
+		}
+		for {
+			// Read the page
+			read := int64(0)
+			for rows.Next() {
+				if err := rows.Scan(scanned...); err != nil {
+					return
+				}
+				row := &SQLRow{Database: table.database, Table: table.name, Columns: names, Key: table.key, Values: make([]SQLValue, len(columns))}
+				for i := range scanned {
+					row.Values[i] = newSQLValue(sqlValueBytes(columns[i].Type, *(scanned[i].(*interface{}))), columns[i].Binary)
+				}
+				read++
+
+				select {
+				case rowsChan <- row:
+				case <-ctx.Done():
+					return
+				}
+				if !pager.add(row) {
+					return
+				}
+			}
+
+			// Stop at the last page
+			if limits.ChunkSize == 0 || read < pageSize || rows.Err() != nil {
+				return
+			}
+			rows.Close()
+			pageSize = pager.limit()
+			query, args := pager.query()
+			if rows, err = newSQLQuery(ctx, db, dialect, query, args...); err != nil {
+				return
+			}
+		}
+	}()
+
+	return columns, rowsChan, nil
+}
+
+// sqlValueBytes Get the bytes of a value as the driver returned it, nil for NULL.  Numbers and dates are as text
+func sqlValueBytes(columnType string, value interface{}) []byte {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		// MSSQL sends GUIDs with the first three groups little endian
+		if columnType == "UNIQUEIDENTIFIER" && len(value) == 16 {
+			guid := []byte{value[3], value[2], value[1], value[0], value[5], value[4], value[7], value[6]}
+			guid = append(guid, value[8:]...)
+			text := strings.ToUpper(hex.EncodeToString(guid))
+			return []byte(text[0:8] + "-" + text[8:12] + "-" + text[12:16] + "-" + text[16:20] + "-" + text[20:])
+		}
+		return value
+	case string:
+		return []byte(value)
+	case int64:
+		return []byte(strconv.FormatInt(value, 10))
+	case float64:
+		return []byte(strconv.FormatFloat(value, 'g', -1, 64))
+	case bool:
+		if value {
+			return []byte("1")
+		}
+		return []byte("0")
+	case time.Time:
+		return []byte(value.Format("2006-01-02 15:04:05.999999999"))
+	default:
+		return []byte(fmt.Sprint(value))
+	}
+}
+
+// sqlPager Builds the queries to read a table in pages within the limits
+type sqlPager struct {
+	dialect *sqlDialect
+	from    string   // Table as it goes in the query
+	columns string   // Columns to select
+	key     []string // Primary key to page by.  Pages use OFFSET without one
+	textKey []bool   // Key columns that are not binary
+	limits  SQLLimits
+
+	rows  int64      // Rows read so far
+	bytes int64      // Bytes of values read so far
+	after []SQLValue // Key of the last row read
+}
+
+// query Get the query and its arguments for the next page
+func (pager *sqlPager) query() (string, []interface{}) {
+	dialect := pager.dialect
+	limit := pager.limit()
+	byKey := pager.limits.ChunkSize > 0 && len(pager.key) > 0
+	byOffset := pager.limits.ChunkSize > 0 && !byKey
+
+	query := "SELECT "
+	if dialect.top && limit > 0 && !byOffset {
+		query += "TOP " + strconv.FormatInt(limit, 10) + " "
+	}
+	query += pager.columns + " FROM " + pager.from
+
+	conditions := []string{}
+	args := []interface{}{}
+	if pager.limits.SampleRate > 0 && pager.limits.SampleRate < 1 {
+		conditions = append(conditions, dialect.random+" < "+strconv.FormatFloat(pager.limits.SampleRate, 'f', -1, 64))
+	}
+	if byKey && pager.after != nil {
+		conditions = append(conditions, pager.afterCondition(&args))
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if byKey {
+		query += " ORDER BY " + pager.keyColumns()
+	}
+	switch {
+	case dialect.top && byOffset:
+		query += fmt.Sprintf(" ORDER BY (SELECT NULL) OFFSET %d ROWS FETCH NEXT %d ROWS ONLY", pager.rows, limit)
+	case !dialect.top:
+		if limit > 0 {
+			query += " LIMIT " + strconv.FormatInt(limit, 10)
+		}
+		if byOffset {
+			query += " OFFSET " + strconv.FormatInt(pager.rows, 10)
+		}
+	}
+
+	return query, args
+}
+
+// afterCondition Get the condition for rows after the key of the last row, adding its arguments
+func (pager *sqlPager) afterCondition(args *[]interface{}) string {
+	dialect := pager.dialect
+	argument := func(i int) string {
+		if dialect.textKeys && i < len(pager.textKey) && pager.textKey[i] {
+			*args = append(*args, string(pager.after[i].Data))
+		} else {
+			*args = append(*args, pager.after[i].Data)
+		}
+		return dialect.placeholder(len(*args))
+	}
+
+	if len(pager.key) == 1 {
+		return dialect.quote(pager.key[0]) + " > " + argument(0)
+	}
+	if dialect.rowCompares {
+		placeholders := make([]string, len(pager.key))
+		for i := range pager.after {
+			placeholders[i] = argument(i)
+		}
+		return "(" + pager.keyColumns() + ") > (" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	// (a > 1 OR (a = 1 AND b > 2))
+	terms := make([]string, len(pager.key))
+	for i := range pager.key {
+		parts := []string{}
+		for j := 0; j < i; j++ {
+			parts = append(parts, dialect.quote(pager.key[j])+" = "+argument(j))
+		}
+		parts = append(parts, dialect.quote(pager.key[i])+" > "+argument(i))
+		terms[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// keyColumns Get the quoted primary key columns separated by commas
+func (pager *sqlPager) keyColumns() string {
+	quoted := make([]string, len(pager.key))
+	for i, column := range pager.key {
+		quoted[i] = pager.dialect.quote(column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// limit Get the number of rows to read in the next page.  0 for unlimited
+func (pager *sqlPager) limit() int64 {
+	limit := pager.limits.ChunkSize
+	if pager.limits.MaxRows > 0 {
+		remaining := pager.limits.MaxRows - pager.rows
+		if limit == 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+	return limit
+}
+
+// add Count a row that was read.  Returns false once a limit is reached
+func (pager *sqlPager) add(row *SQLRow) bool {
+	pager.rows++
+	for _, value := range row.Values {
+		pager.bytes += int64(len(value.Data))
+	}
+	if len(pager.key) > 0 {
+		pager.after = make([]SQLValue, len(pager.key))
+		for i, column := range pager.key {
+			pager.after[i] = row.Value(column)
+		}
+	}
+
+	if pager.limits.MaxRows > 0 && pager.rows >= pager.limits.MaxRows {
+		return false
+	}
+	if pager.limits.MaxBytes > 0 && pager.bytes >= pager.limits.MaxBytes {
+		return false
+	}
+	return true
+}
+
+// sqlQuery Rows of a query running on its own connection
+type sqlQuery struct {
+	*sql.Rows
+	conn     *sql.Conn
+	done     chan struct{} // Closed when the rows are closed
+	finished chan struct{} // Closed when we stopped watching for the context to be canceled
+}
+
+// newSQLQuery Run a query on its own connection.  If ctx is canceled before the rows are closed the query is killed on
+// the server, which otherwise keeps running it until it next writes to the dropped connection
+func newSQLQuery(ctx context.Context, db *sql.DB, dialect *sqlDialect, query string, args ...interface{}) (*sqlQuery, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &sqlQuery{conn: conn, done: make(chan struct{}), finished: make(chan struct{})}
+
+	queryCtx := ctx
+	var id int64
+	if dialect.interrupt != nil {
+		interrupt, err := dialect.interrupt(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		queryCtx = context.Background()
+		go result.interruptOnCancel(ctx, interrupt)
+	} else if dialect.connectionID != "" && conn.QueryRowContext(ctx, dialect.connectionID).Scan(&id) == nil {
+		go result.killOnCancel(ctx, db, fmt.Sprintf(dialect.kill, id))
+	} else {
+		close(result.finished)
+	}
+
+	result.Rows, err = conn.QueryContext(queryCtx, query, args...)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	return result, nil
+}
+
+// killOnCancel Run the kill query on another connection if ctx is canceled before the rows are closed
+func (query *sqlQuery) killOnCancel(ctx context.Context, db *sql.DB, kill string) {
+	defer close(query.finished)
+
+	select {
+	case <-ctx.Done():
+	case <-query.done:
+		if ctx.Err() == nil {
+			return
+		}
+	}
+
+	killCtx, cancel := context.WithTimeout(context.Background(), sqlKillTimeout)
+	defer cancel()
+	db.ExecContext(killCtx, kill)
+}
+
+// interruptOnCancel Interrupt the connection once ctx is canceled and again every sqlInterruptInterval until the rows
+// are closed, as an interrupt before a query starts is forgotten
+func (query *sqlQuery) interruptOnCancel(ctx context.Context, interrupt func()) {
+	defer close(query.finished)
+
+	select {
+	case <-ctx.Done():
+	case <-query.done:
+		return
+	}
+
+	ticker := time.NewTicker(sqlInterruptInterval)
+	defer ticker.Stop()
+	for {
+		interrupt()
+		select {
+		case <-ticker.C:
+		case <-query.done:
+			return
+		}
+	}
+}
+
+// Close the rows and give back the connection once we stopped watching it, so we never kill a query reusing it or
+// interrupt a closed connection
+func (query *sqlQuery) Close() error {
+	close(query.done)
+	<-query.finished
+
+	if query.Rows != nil {
+		query.Rows.Close()
+	}
+	return query.conn.Close()
+}
@@ -0,0 +1,135 @@
+package enrichers
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLPagerQuery(t *testing.T) {
+	pager := &sqlPager{dialect: &mysqlDialect, from: "`users`", columns: "*", key: []string{"org", "id"}, limits: SQLLimits{MaxRows: 5, ChunkSize: 2, SampleRate: 0.25}}
+	query, args := pager.query()
+	if query != "SELECT * FROM `users` WHERE RAND() < 0.25 ORDER BY `org`, `id` LIMIT 2" || len(args) != 0 {
+		t.Errorf("Wrong first page %s %v", query, args)
+	}
+
+	row := &SQLRow{Columns: []string{"org", "id"}, Values: []SQLValue{{Data: []byte("a")}, {Data: []byte("7")}}}
+	pager.add(row)
+	pager.add(row)
+	query, args = pager.query()
+	if query != "SELECT * FROM `users` WHERE RAND() < 0.25 AND (`org`, `id`) > (?, ?) ORDER BY `org`, `id` LIMIT 2" ||
+		!reflect.DeepEqual(args, []interface{}{[]byte("a"), []byte("7")}) {
+		t.Errorf("Wrong next page %s %v", query, args)
+	}
+
+	// The last page stops at MaxRows
+	pager.add(row)
+	pager.add(row)
+	if query, _ := pager.query(); !strings.HasSuffix(query, "LIMIT 1") {
+		t.Errorf("Wrong last page %s", query)
+	}
+
+	// Tables without a key are paged with OFFSET
+	pager = &sqlPager{dialect: &mysqlDialect, from: "`logs`", columns: "`message`", limits: SQLLimits{ChunkSize: 100}}
+	pager.add(row)
+	if query, _ := pager.query(); query != "SELECT `message` FROM `logs` LIMIT 100 OFFSET 1" {
+		t.Errorf("Wrong offset page %s", query)
+	}
+}
+
+// fakePagedDB A fake database whose users table is read in pages of one row
+func fakePagedDB() *fakeSQLDB {
+	db := fakeShopDB()
+	users := db.results["SELECT * FROM `users`"]
+	db.results["SELECT * FROM `users` ORDER BY `id` LIMIT 1"] = &fakeSQLResult{columns: users.columns, types: users.types, rows: users.rows[:1]}
+	db.results["SELECT * FROM `users` WHERE `id` > '1' ORDER BY `id` LIMIT 1"] = &fakeSQLResult{columns: users.columns, types: users.types, rows: users.rows[1:]}
+	db.results["SELECT * FROM `users` WHERE `id` > '2' ORDER BY `id` LIMIT 1"] = &fakeSQLResult{columns: users.columns, types: users.types}
+	db.results["SELECT * FROM `users` LIMIT 1"] = &fakeSQLResult{columns: users.columns, types: users.types, rows: users.rows[:1]}
+	return db
+}
+
+// typedRowIDs Read the ids of the users rows
+func typedRowIDs(t *testing.T, client *SQLClient) []string {
+	_, rows, err := client.GetTypedRows(context.Background(), "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for row := range rows {
+		ids = append(ids, string(row.Value("id").Data))
+	}
+	return ids
+}
+
+func TestSQLLimits(t *testing.T) {
+	tests := []struct {
+		limits SQLLimits
+		ids    []string
+	}{
+		{SQLLimits{}, []string{"1", "2"}},
+		{SQLLimits{ChunkSize: 1}, []string{"1", "2"}},
+		{SQLLimits{MaxRows: 1}, []string{"1"}},
+		{SQLLimits{MaxBytes: 10}, []string{"1"}},
+	}
+	for _, test := range tests {
+		client := newFakeSQLClient(fakePagedDB(), "shop", SQLOptions{Limits: test.limits})
+		if ids := typedRowIDs(t, client); !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("Read %v with %+v", ids, test.limits)
+		}
+	}
+
+	// The legacy rows are limited too
+	client := newFakeSQLClient(fakePagedDB(), "shop", SQLOptions{Limits: SQLLimits{MaxRows: 1}})
+	_, rows := client.GetRows(context.Background(), "`users`")
+	count := 0
+	for range rows {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("Read %d rows", count)
+	}
+}
+
+func TestSQLKillOnCancel(t *testing.T) {
+	db := fakeShopDB()
+	db.results["SELECT CONNECTION_ID()"] = &fakeSQLResult{columns: []string{"CONNECTION_ID()"}, rows: [][]driver.Value{{int64(42)}}}
+	db.results["KILL QUERY 42"] = &fakeSQLResult{}
+	client := newFakeSQLClient(db, "shop", SQLOptions{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, rows, err := client.GetTypedRows(ctx, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rows
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		db.lock.Lock()
+		killed := containsWords(db.queries, []string{"KILL QUERY 42"})
+		db.lock.Unlock()
+		if killed {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Query was not killed")
+}
+
+func TestGetTablesMatchingRules(t *testing.T) {
+	client := newFakeSQLClient(fakePagedDB(), "shop", SQLOptions{})
+	tables, err := client.GetTablesMatchingRules(context.Background(), []*regexp.Regexp{regexp.MustCompile(`@example\.com`)}, 1)
+	if err != nil || !reflect.DeepEqual(tables, []string{"users"}) {
+		t.Errorf("Wrong tables %v %v", tables, err)
+	}
+
+	tables, err = client.GetTablesMatchingRules(context.Background(), []*regexp.Regexp{regexp.MustCompile(`failed`)}, -1)
+	if err != nil || !reflect.DeepEqual(tables, []string{"logs"}) {
+		t.Errorf("Wrong tables %v %v", tables, err)
+	}
+}
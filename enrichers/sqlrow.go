@@ -0,0 +1,258 @@
+package enrichers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:generate stringer -type=SQLDumpFormat
+
+// SQLDumpFormat Format of the rows when dumping or reading a SQL server
+type SQLDumpFormat int
+
+// SQL dump formats
+const (
+	// SQLDumpRaw The bytes of every value back to back, with nothing between values or rows
+	SQLDumpRaw SQLDumpFormat = iota
+	// SQLDumpJSON A JSON object per line like {"db": "shop", "table": "users", "pk": {"id": "1"}, "columns": {"id": "1"}}.
+	// pk is null when the table has no primary key
+	SQLDumpJSON
+	// SQLDumpCSV A section per table: the table name, the column names, then the rows as CSV, followed by a blank line
+	SQLDumpCSV
+	// SQLDumpDelimited Like SQLDumpCSV with values separated by SQLOptions.Delimiter instead of quoted.  Backslashes,
+	// tabs, newlines, and the delimiter in values are escaped with a backslash
+	SQLDumpDelimited
+)
+
+// defaultSQLDelimiter Delimiter of SQLDumpDelimited when none is set
+const defaultSQLDelimiter = "\t"
+
+// sqlNull How NULL is written in CSV and delimited dumps
+const sqlNull = `\N`
+
+// SQLValue A value of a column.  Binary values are written as base64 in JSON, CSV, and delimited dumps
+type SQLValue struct {
+	Null   bool   // The value is NULL, which is different from empty
+	Binary bool   // The value is binary data such as an image in a BLOB rather than text
+	Data   []byte // Bytes of the value.  Numbers and dates are as text
+}
+
+// newSQLValue Create a value from its bytes, nil for NULL.  Values of binary columns are binary unless they are text,
+// values of other columns are binary if they are not UTF-8
+func newSQLValue(data []byte, binaryColumn bool) SQLValue {
+	if data == nil {
+		return SQLValue{Null: true}
+	}
+	value := SQLValue{Data: data}
+	if binaryColumn {
+		value.Binary = len(data) > 0 && !isMostlyText(data)
+	} else {
+		value.Binary = !utf8.Valid(data)
+	}
+	return value
+}
+
+// String Get the value as text.  Binary values are base64 and NULL is empty
+func (value SQLValue) String() string {
+	if value.Binary {
+		return base64.StdEncoding.EncodeToString(value.Data)
+	}
+	return string(value.Data)
+}
+
+// SQLRow A row of a table along with its column names, from a live server or a dump
+type SQLRow struct {
+	Database string     // Empty when unknown
+	Table    string     // Name of the table, with the schema for PostgreSQL such as public.users
+	Schema   string     // Schema of the table for PostgreSQL such as public.  Empty when unknown
+	Columns  []string   // Names of the columns, numbered from 1 when they are unknown
+	Key      []string   // Names of the primary key columns.  Empty when the table has none or it is unknown
+	Values   []SQLValue // Values of the columns
+}
+
+// FullName Get the name of the table with its database, such as shop.users
+func (row *SQLRow) FullName() string {
+	if row.Database == "" {
+		return row.Table
+	}
+	return row.Database + "." + row.Table
+}
+
+// JSON Get the row as a JSON object like {"db": "shop", "table": "users", "pk": {"id": "1"}, "columns": {"id": "1"}}.
+// Columns are in the order of the table and NULL values are null
+func (row *SQLRow) JSON() []byte {
+	buffer := &bytes.Buffer{}
+	buffer.WriteString(`{"db":`)
+	writeJSONString(buffer, row.Database)
+	buffer.WriteString(`,"table":`)
+	writeJSONString(buffer, row.Table)
+
+	buffer.WriteString(`,"pk":`)
+	if len(row.Key) == 0 {
+		buffer.WriteString("null")
+	} else {
+		buffer.WriteByte('{')
+		for i, column := range row.Key {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			writeJSONString(buffer, column)
+			buffer.WriteByte(':')
+			writeJSONValue(buffer, row.Value(column))
+		}
+		buffer.WriteByte('}')
+	}
+
+	buffer.WriteString(`,"columns":{`)
+	for i, value := range row.Values {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		writeJSONString(buffer, row.column(i))
+		buffer.WriteByte(':')
+		writeJSONValue(buffer, value)
+	}
+	buffer.WriteString("}}")
+	return buffer.Bytes()
+}
+
+// Value Get the value of a column by name.  NULL if there is no such column
+func (row *SQLRow) Value(column string) SQLValue {
+	for i, value := range row.Values {
+		if row.column(i) == column {
+			return value
+		}
+	}
+	return SQLValue{Null: true}
+}
+
+// column Get the name of the ith column
+func (row *SQLRow) column(i int) string {
+	if i < len(row.Columns) {
+		return row.Columns[i]
+	}
+	return sqlColumnNumber(i)
+}
+
+// writeJSONValue Write the value as a JSON string, or null
+func writeJSONValue(buffer *bytes.Buffer, value SQLValue) {
+	if value.Null {
+		buffer.WriteString("null")
+		return
+	}
+	writeJSONString(buffer, value.String())
+}
+
+// writeJSONString Write the string quoted as JSON, leaving HTML characters as they are so rules can match them
+func writeJSONString(buffer *bytes.Buffer, s string) {
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	// Remove the newline after the value
+	buffer.Truncate(buffer.Len() - 1)
+}
+
+// sqlRowWriter Writes rows in a dump format.  Rows of a table must be written together
+type sqlRowWriter struct {
+	w         io.Writer
+	format    SQLDumpFormat
+	delimiter string
+	table     string // Table of the last row, to know when a new section starts
+}
+
+// newSQLRowWriter Create a writer of rows in the format.  delimiter is only for SQLDumpDelimited, empty for a tab
+func newSQLRowWriter(w io.Writer, format SQLDumpFormat, delimiter string) *sqlRowWriter {
+	if delimiter == "" {
+		delimiter = defaultSQLDelimiter
+	}
+	return &sqlRowWriter{w: w, format: format, delimiter: delimiter}
+}
+
+// write Write the row, starting a new section first if it is from a new table
+func (writer *sqlRowWriter) write(row *SQLRow) error {
+	switch writer.format {
+	case SQLDumpRaw:
+		for _, value := range row.Values {
+			if _, err := writer.w.Write(value.Data); err != nil {
+				return err
+			}
+		}
+		return nil
+	case SQLDumpJSON:
+		_, err := writer.w.Write(append(row.JSON(), '\n'))
+		return err
+	case SQLDumpCSV, SQLDumpDelimited:
+		if err := writer.section(row); err != nil {
+			return err
+		}
+		return writer.record(row.Values, func(value SQLValue) string {
+			if value.Null {
+				return sqlNull
+			}
+			return value.String()
+		})
+	default:
+		return fmt.Errorf("unknown dump format %s", writer.format)
+	}
+}
+
+// section Write the table name and column names if the row starts a new table
+func (writer *sqlRowWriter) section(row *SQLRow) error {
+	name := row.FullName()
+	if name == writer.table {
+		return nil
+	}
+	if writer.table != "" {
+		if _, err := writer.w.Write([]byte("\n")); err != nil {
+			return err
+		}
+	}
+	writer.table = name
+
+	if _, err := writer.w.Write([]byte(name + "\n")); err != nil {
+		return err
+	}
+	columns := make([]SQLValue, len(row.Values))
+	for i := range columns {
+		columns[i] = SQLValue{Data: []byte(row.column(i))}
+	}
+	return writer.record(columns, SQLValue.String)
+}
+
+// record Write one line of CSV or delimited values
+func (writer *sqlRowWriter) record(values []SQLValue, text func(value SQLValue) string) error {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		fields[i] = text(value)
+	}
+
+	if writer.format == SQLDumpCSV {
+		csvWriter := csv.NewWriter(writer.w)
+		csvWriter.Write(fields)
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+
+	for i, field := range fields {
+		if !(values[i].Null && field == sqlNull) {
+			fields[i] = escapeDelimited(field, writer.delimiter)
+		}
+	}
+	_, err := writer.w.Write([]byte(strings.Join(fields, writer.delimiter) + "\n"))
+	return err
+}
+
+// escapeDelimited Escape backslashes, line breaks, tabs, and the delimiter with backslashes
+func escapeDelimited(field, delimiter string) string {
+	replacements := []string{`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`}
+	if delimiter != "\t" {
+		replacements = append(replacements, delimiter, `\`+delimiter)
+	}
+	return strings.NewReplacer(replacements...).Replace(field)
+}
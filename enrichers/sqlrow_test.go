@@ -0,0 +1,31 @@
+package enrichers
+
+import (
+	"testing"
+)
+
+func TestSQLValue(t *testing.T) {
+	tests := []struct {
+		data         []byte
+		binaryColumn bool
+		value        SQLValue
+		text         string
+	}{
+		{nil, false, SQLValue{Null: true}, ""},
+		{[]byte{}, true, SQLValue{Data: []byte{}}, ""},
+		{[]byte("text"), false, SQLValue{Data: []byte("text")}, "text"},
+		{[]byte("text in a blob"), true, SQLValue{Data: []byte("text in a blob")}, "text in a blob"},
+		{[]byte{0x00, 0x01, 0x02}, true, SQLValue{Binary: true, Data: []byte{0x00, 0x01, 0x02}}, "AAEC"},
+		{[]byte{0xff, 0xfe}, false, SQLValue{Binary: true, Data: []byte{0xff, 0xfe}}, "//4="},
+	}
+	for _, test := range tests {
+		value := newSQLValue(test.data, test.binaryColumn)
+		if value.Null != test.value.Null || value.Binary != test.value.Binary || value.String() != test.text {
+			t.Errorf("Wrong value of %q: %+v %s", test.data, value, value)
+		}
+	}
+
+	if escaped := escapeDelimited("a|b\\c\nd\te", "|"); escaped != `a\|b\\c\nd\te` {
+		t.Errorf("Wrong escaping %s", escaped)
+	}
+}
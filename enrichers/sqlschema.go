@@ -0,0 +1,213 @@
+package enrichers
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SQLColumnClass A class of sensitive data, found by the names and types of columns
+type SQLColumnClass struct {
+	Name   string
+	Weight int // How sensitive the data is, from 1 to 10.  Tables are ranked by the weights of their columns
+
+	// Names Column names of this class, as lowercase words joined by underscores.  A column is of this class if the
+	// words appear in its name in order, so api_key matches user_api_key and apiKey
+	Names []string
+	// Types Type families the column must be: text, binary, number, or date.  Empty for any
+	Types []string
+}
+
+// SQLColumnClasses Classes of sensitive columns, checked in order
+var SQLColumnClasses = []SQLColumnClass{
+	{"password", 10, []string{"password", "passwd", "pwd", "pass", "passphrase", "pass_hash", "hashed_password"}, []string{"text", "binary"}},
+	{"api_key", 10, []string{"api_key", "apikey", "token", "secret", "secret_key", "access_key", "private_key", "client_secret"}, []string{"text", "binary"}},
+	{"card_number", 9, []string{"card_number", "cardnumber", "card_no", "cc_number", "ccnum", "credit_card", "creditcard", "pan", "cvv", "cvc"}, []string{"text", "number"}},
+	{"national_id", 9, []string{"ssn", "social_security", "national_id", "tax_id", "passport", "passport_number"}, []string{"text", "number"}},
+	{"bank_account", 8, []string{"iban", "account_number", "routing_number", "bank_account"}, []string{"text", "number"}},
+	{"email", 5, []string{"email", "e_mail", "email_address"}, []string{"text"}},
+	{"dob", 5, []string{"dob", "date_of_birth", "birth_date", "birthdate", "birthday"}, []string{"text", "date"}},
+	{"phone", 3, []string{"phone", "phone_number", "mobile", "telephone"}, []string{"text", "number"}},
+	{"address", 3, []string{"address", "street", "postcode", "zip_code", "postal_code"}, []string{"text"}},
+}
+
+// sqlTypeFamilies Families of database types
+var sqlTypeFamilies = map[string]string{
+	"CHAR": "text", "VARCHAR": "text", "TINYTEXT": "text", "TEXT": "text", "MEDIUMTEXT": "text", "LONGTEXT": "text",
+	"ENUM": "text", "SET": "text", "JSON": "text",
+	"BINARY": "binary", "VARBINARY": "binary", "TINYBLOB": "binary", "BLOB": "binary", "MEDIUMBLOB": "binary", "LONGBLOB": "binary",
+	"TINYINT": "number", "SMALLINT": "number", "MEDIUMINT": "number", "INT": "number", "INTEGER": "number", "BIGINT": "number",
+	"DECIMAL": "number", "NUMERIC": "number", "FLOAT": "number", "DOUBLE": "number", "BIT": "number",
+	"DATE": "date", "DATETIME": "date", "TIMESTAMP": "date", "TIME": "date", "YEAR": "date",
+	// MSSQL
+	"NCHAR": "text", "NVARCHAR": "text", "NTEXT": "text", "XML": "text", "UNIQUEIDENTIFIER": "text",
+	"IMAGE": "binary", "REAL": "number", "MONEY": "number", "SMALLMONEY": "number",
+	"DATETIME2": "date", "SMALLDATETIME": "date", "DATETIMEOFFSET": "date",
+}
+
+// SQLSchema Tables of a database with their columns classified
+type SQLSchema struct {
+	Database string
+	Tables   []*SQLTableSchema // Most sensitive first
+}
+
+// SQLTableSchema A table with its size and columns
+type SQLTableSchema struct {
+	Name        string
+	Rows        int64 // Estimate of the number of rows.  InnoDB estimates can be far off
+	DataSize    int64 // Bytes of data
+	IndexSize   int64 // Bytes of indexes
+	Columns     []SQLColumn
+	Sensitivity int // Sum of the weights of the classes of its columns, each class counted once
+}
+
+// ClassifySQLColumn Get the class of sensitive data a column holds from its name and database type.  Empty if it
+// does not look sensitive
+func ClassifySQLColumn(name, dataType string) string {
+	words := sqlNameWords(name)
+	family := sqlTypeFamilies[strings.ToUpper(dataType)]
+
+	for _, class := range SQLColumnClasses {
+		if family != "" && len(class.Types) > 0 && !containsWords(class.Types, []string{family}) {
+			continue
+		}
+		for _, className := range class.Names {
+			if containsWords(words, strings.Split(className, "_")) {
+				return class.Name
+			}
+		}
+	}
+	return ""
+}
+
+// sqlNameWords Split a column name like userPassword_hash into lowercase words
+func sqlNameWords(name string) []string {
+	words := []string{}
+	word := []rune{}
+	var last rune
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = word[0:0]
+		} else {
+			// camelCase
+			if unicode.IsUpper(r) && unicode.IsLower(last) && len(word) > 0 {
+				words = append(words, string(word))
+				word = word[0:0]
+			}
+			word = append(word, unicode.ToLower(r))
+		}
+		last = r
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// containsWords Check if the words contain the sequence of words
+func containsWords(words, sequence []string) bool {
+	for start := 0; start+len(sequence) <= len(words); start++ {
+		matched := true
+		for i := range sequence {
+			if words[start+i] != sequence[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSchema Get the tables of the database with their size from information_schema.TABLES and their columns from
+// information_schema.COLUMNS, with sensitive columns classified.  Tables are ranked by sensitivity then size
+func (client *SQLClient) GetSchema(ctx context.Context) (*SQLSchema, error) {
+	schema := &SQLSchema{Database: client.database(ctx), Tables: []*SQLTableSchema{}}
+	tables := map[string]*SQLTableSchema{}
+
+	rows, err := client.db.QueryContext(ctx, "SELECT TABLE_NAME, TABLE_ROWS, DATA_LENGTH, INDEX_LENGTH "+
+		"FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE()")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var tableRows, dataSize, indexSize sql.NullInt64
+		if err := rows.Scan(&name, &tableRows, &dataSize, &indexSize); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		table := &SQLTableSchema{Name: name, Rows: tableRows.Int64, DataSize: dataSize.Int64, IndexSize: indexSize.Int64, Columns: []SQLColumn{}}
+		tables[name] = table
+		schema.Tables = append(schema.Tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = client.db.QueryContext(ctx, "SELECT TABLE_NAME, COLUMN_NAME, DATA_TYPE, COLUMN_KEY "+
+		"FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME, ORDINAL_POSITION")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tableName, name, dataType, key string
+		if err := rows.Scan(&tableName, &name, &dataType, &key); err != nil {
+			return nil, err
+		}
+		table, ok := tables[tableName]
+		if !ok {
+			continue
+		}
+		column := SQLColumn{Name: name, Type: strings.ToUpper(dataType), PrimaryKey: key == "PRI"}
+		column.Binary = sqlBinaryTypes[column.Type]
+		column.Class = ClassifySQLColumn(name, column.Type)
+		table.Columns = append(table.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, table := range schema.Tables {
+		table.Sensitivity = sqlSensitivity(table.Columns)
+	}
+	sort.SliceStable(schema.Tables, func(i, j int) bool {
+		a, b := schema.Tables[i], schema.Tables[j]
+		if a.Sensitivity != b.Sensitivity {
+			return a.Sensitivity > b.Sensitivity
+		}
+		if a.Rows != b.Rows {
+			return a.Rows > b.Rows
+		}
+		return a.Name < b.Name
+	})
+
+	return schema, nil
+}
+
+// sqlSensitivity Get the sum of the weights of the classes of the columns, counting each class once
+func sqlSensitivity(columns []SQLColumn) int {
+	sensitivity := 0
+	counted := map[string]bool{}
+	for _, column := range columns {
+		if column.Class == "" || counted[column.Class] {
+			continue
+		}
+		counted[column.Class] = true
+		for _, class := range SQLColumnClasses {
+			if class.Name == column.Class {
+				sensitivity += class.Weight
+			}
+		}
+	}
+	return sensitivity
+}
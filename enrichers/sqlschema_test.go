@@ -0,0 +1,80 @@
+package enrichers
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestClassifySQLColumn(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataType string
+		class    string
+	}{
+		{"password", "varchar", "password"},
+		{"userPasswordHash", "CHAR", "password"},
+		{"user_api_key", "TEXT", "api_key"},
+		{"apiKey", "", "api_key"},
+		{"cc_number", "BIGINT", "card_number"},
+		{"passport_number", "VARCHAR", "national_id"},
+		{"Email", "VARCHAR", "email"},
+		{"date_of_birth", "DATE", "dob"},
+		{"phone", "VARCHAR", "phone"},
+		// The type does not fit the class
+		{"email_verified", "TINYINT", ""},
+		{"password_changed", "DATETIME", ""},
+		// Words must match whole
+		{"company", "VARCHAR", ""},
+		{"compass", "VARCHAR", ""},
+	}
+	for _, test := range tests {
+		if class := ClassifySQLColumn(test.name, test.dataType); class != test.class {
+			t.Errorf("%s %s is %q instead of %q", test.name, test.dataType, class, test.class)
+		}
+	}
+}
+
+func TestGetSchema(t *testing.T) {
+	client := newFakeSQLClient(fakeShopDB(), "shop", SQLOptions{})
+	schema, err := client.GetSchema(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Database != "shop" || len(schema.Tables) != 2 {
+		t.Fatalf("Wrong schema %+v", schema)
+	}
+
+	// users is ranked first even though logs has more rows
+	users, logs := schema.Tables[0], schema.Tables[1]
+	if users.Name != "users" || users.Sensitivity != 15 || users.Rows != 2 || users.DataSize != 16384 || users.IndexSize != 16384 {
+		t.Errorf("Wrong users table %+v", users)
+	}
+	expectedColumns := []SQLColumn{
+		{"id", "INT", false, true, ""},
+		{"email", "VARCHAR", false, false, "email"},
+		{"note", "TEXT", false, false, ""},
+		{"avatar", "BLOB", true, false, ""},
+		{"token", "BLOB", true, false, "api_key"},
+	}
+	if !reflect.DeepEqual(users.Columns, expectedColumns) {
+		t.Errorf("Wrong users columns %+v", users.Columns)
+	}
+	if logs.Name != "logs" || logs.Sensitivity != 0 || logs.Rows != 5000 || logs.IndexSize != 0 {
+		t.Errorf("Wrong logs table %+v", logs)
+	}
+}
+
+func TestSQLDumpSensitiveOnly(t *testing.T) {
+	client := newFakeSQLClient(fakeShopDB(), "shop", SQLOptions{DumpFormat: SQLDumpJSON, SensitiveOnly: true})
+	reader, err := client.Dump(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump, err := ioutil.ReadAll(reader)
+	expected := `{"db":"shop","table":"users","pk":{"id":"1"},"columns":{"id":"1","email":"admin@example.com","token":"abc123"}}` + "\n"
+	if err != nil || string(dump) != expected {
+		t.Errorf("Wrong dump %v:\n%s", err, dump)
+	}
+}
@@ -0,0 +1,295 @@
+package enrichers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoTLS Returned when getting TLS info of a server that does not use TLS
+var ErrNoTLS = errors.New("server does not use TLS")
+
+// TLSInfo Details of a TLS connection and the certificate chain the server sent
+type TLSInfo struct {
+	Version            string `json:"version"`
+	CipherSuite        string `json:"cipher_suite"`
+	ServerName         string `json:"server_name"`
+	NegotiatedProtocol string `json:"negotiated_protocol,omitempty"` // ALPN
+
+	// JA3S-style fingerprint of the parameters the server chose: version,cipher,extensions in decimal.
+	// Only extensions visible after the handshake are included (status_request, ALPN, SCT, and the TLS 1.3 ones)
+	JA3S     string `json:"ja3s"`
+	JA3SHash string `json:"ja3s_hash"` // MD5 of JA3S
+
+	Certificates []TLSCertificate `json:"certificates"` // Leaf first
+	Verified     bool             `json:"verified"`     // Chain verifies against the system roots for ServerName
+	VerifyError  string           `json:"verify_error,omitempty"`
+
+	// Hostnames Every DNS name in the leaf SANs and common name, without wildcards, to pivot on
+	Hostnames []string `json:"hostnames"`
+}
+
+// TLSCertificate Details of a certificate
+type TLSCertificate struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SerialNumber       string    `json:"serial_number"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	Expired            bool      `json:"expired"`
+	SelfSigned         bool      `json:"self_signed"`
+	IsCA               bool      `json:"is_ca"`
+	DNSNames           []string  `json:"dns_names"`
+	IPAddresses        []string  `json:"ip_addresses"`
+	EmailAddresses     []string  `json:"email_addresses"`
+	KeyType            string    `json:"key_type"` // RSA, ECDSA, Ed25519
+	KeySize            int       `json:"key_size"` // Bits
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	SHA256             string    `json:"sha256"` // Fingerprint of the DER certificate
+}
+
+// tlsVersionNames Names of TLS versions
+var tlsVersionNames = map[uint16]string{
+	tls.VersionSSL30: "SSL 3.0",
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// tlsCipherSuiteNames IANA names of the cipher suites crypto/tls supports
+var tlsCipherSuiteNames = map[uint16]string{
+	tls.TLS_RSA_WITH_RC4_128_SHA:                "TLS_RSA_WITH_RC4_128_SHA",
+	tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA:           "TLS_RSA_WITH_3DES_EDE_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA:            "TLS_RSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA:            "TLS_RSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA256:         "TLS_RSA_WITH_AES_128_CBC_SHA256",
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256:         "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384:         "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA:        "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA:          "TLS_ECDHE_RSA_WITH_RC4_128_SHA",
+	tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA:     "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256:   "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305:    "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305:  "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+	tls.TLS_AES_128_GCM_SHA256:                  "TLS_AES_128_GCM_SHA256",
+	tls.TLS_AES_256_GCM_SHA384:                  "TLS_AES_256_GCM_SHA384",
+	tls.TLS_CHACHA20_POLY1305_SHA256:            "TLS_CHACHA20_POLY1305_SHA256",
+}
+
+// TLS extensions we can tell the server sent from the connection state
+const (
+	tlsExtensionStatusRequest     = 5
+	tlsExtensionALPN              = 16
+	tlsExtensionSCT               = 18
+	tlsExtensionSupportedVersions = 43
+	tlsExtensionKeyShare          = 51
+)
+
+// newTLSInfo Get details of a TLS connection
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:            tlsVersionName(state.Version),
+		CipherSuite:        tlsCipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		Certificates:       []TLSCertificate{},
+		Hostnames:          []string{},
+	}
+
+	// JA3S
+	extensions := []int{}
+	if len(state.OCSPResponse) > 0 {
+		extensions = append(extensions, tlsExtensionStatusRequest)
+	}
+	if state.NegotiatedProtocol != "" {
+		extensions = append(extensions, tlsExtensionALPN)
+	}
+	if len(state.SignedCertificateTimestamps) > 0 {
+		extensions = append(extensions, tlsExtensionSCT)
+	}
+	if state.Version == tls.VersionTLS13 {
+		extensions = append(extensions, tlsExtensionSupportedVersions, tlsExtensionKeyShare)
+	}
+	extensionStrings := []string{}
+	for _, extension := range extensions {
+		extensionStrings = append(extensionStrings, fmt.Sprint(extension))
+	}
+	// The ServerHello of TLS 1.3 says TLS 1.2 and puts the real version in the supported_versions extension
+	helloVersion := state.Version
+	if helloVersion == tls.VersionTLS13 {
+		helloVersion = tls.VersionTLS12
+	}
+	info.JA3S = fmt.Sprintf("%d,%d,%s", helloVersion, state.CipherSuite, strings.Join(extensionStrings, "-"))
+	ja3sHash := md5.Sum([]byte(info.JA3S))
+	info.JA3SHash = hex.EncodeToString(ja3sHash[:])
+
+	for _, certificate := range state.PeerCertificates {
+		info.Certificates = append(info.Certificates, newTLSCertificate(certificate))
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+
+		// Verify
+		intermediates := x509.NewCertPool()
+		for _, certificate := range state.PeerCertificates[1:] {
+			intermediates.AddCert(certificate)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{DNSName: state.ServerName, Intermediates: intermediates})
+		info.Verified = err == nil
+		if err != nil {
+			info.VerifyError = err.Error()
+		}
+
+		// Hostnames
+		seen := map[string]bool{}
+		for _, name := range append([]string{leaf.Subject.CommonName}, leaf.DNSNames...) {
+			name = strings.ToLower(strings.TrimPrefix(name, "*."))
+			if name == "" || net.ParseIP(name) != nil || !strings.Contains(name, ".") || seen[name] {
+				continue
+			}
+			seen[name] = true
+			info.Hostnames = append(info.Hostnames, name)
+		}
+		sort.Strings(info.Hostnames)
+	}
+
+	return info
+}
+
+// newTLSCertificate Get details of a certificate
+func newTLSCertificate(certificate *x509.Certificate) TLSCertificate {
+	fingerprint := sha256.Sum256(certificate.Raw)
+	info := TLSCertificate{
+		Subject:            certificate.Subject.String(),
+		Issuer:             certificate.Issuer.String(),
+		SerialNumber:       certificate.SerialNumber.String(),
+		NotBefore:          certificate.NotBefore,
+		NotAfter:           certificate.NotAfter,
+		Expired:            time.Now().After(certificate.NotAfter),
+		IsCA:               certificate.IsCA,
+		DNSNames:           certificate.DNSNames,
+		IPAddresses:        []string{},
+		EmailAddresses:     certificate.EmailAddresses,
+		SignatureAlgorithm: certificate.SignatureAlgorithm.String(),
+		SHA256:             hex.EncodeToString(fingerprint[:]),
+	}
+	if info.DNSNames == nil {
+		info.DNSNames = []string{}
+	}
+	if info.EmailAddresses == nil {
+		info.EmailAddresses = []string{}
+	}
+	for _, ip := range certificate.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+
+	// Self signed if the issuer is the subject and the certificate's own key signed it
+	if certificate.Subject.String() == certificate.Issuer.String() {
+		info.SelfSigned = certificate.CheckSignature(certificate.SignatureAlgorithm, certificate.RawTBSCertificate, certificate.Signature) == nil
+	}
+
+	switch key := certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.KeyType, info.KeySize = "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.KeyType, info.KeySize = "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.KeyType, info.KeySize = "Ed25519", 256
+	default:
+		info.KeyType = certificate.PublicKeyAlgorithm.String()
+	}
+
+	return info
+}
+
+// tlsVersionName Get name of a TLS version
+func tlsVersionName(version uint16) string {
+	if name, ok := tlsVersionNames[version]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
+// tlsCipherSuiteName Get the IANA name of a cipher suite
+func tlsCipherSuiteName(cipherSuite uint16) string {
+	if name, ok := tlsCipherSuiteNames[cipherSuite]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", cipherSuite)
+}
+
+// inspectTLSConfig Config for handshakes made only to look at the server's certificates, which we want to see
+// even if they are invalid
+func inspectTLSConfig(host string) *tls.Config {
+	return &tls.Config{ServerName: host, InsecureSkipVerify: true}
+}
+
+// dialInspectTLS Connect to the address, run the protocol's preamble to start TLS if there is one, then
+// handshake and get details of the connection
+func dialInspectTLS(ctx context.Context, address, host string, preamble func(conn net.Conn) error) (*TLSInfo, error) {
+	conn, err := (&net.Dialer{Timeout: 30 * time.Second}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if preamble != nil {
+		if err := preamble(conn); err != nil {
+			return nil, err
+		}
+	}
+
+	tlsConn := tls.Client(conn, inspectTLSConfig(host))
+	if err := tlsHandshake(ctx, tlsConn); err != nil {
+		return nil, err
+	}
+	state := tlsConn.ConnectionState()
+
+	return newTLSInfo(&state), nil
+}
+
+// tlsHandshake Run the handshake, stopping if the context is done
+func tlsHandshake(ctx context.Context, conn *tls.Conn) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	err := conn.Handshake()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	conn.SetDeadline(time.Time{})
+	return err
+}
@@ -0,0 +1,267 @@
+package enrichers
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate Generate a self signed certificate with a key of the type (rsa, ecdsa, ed25519) for the hosts
+func testCertificate(t *testing.T, keyType string, hosts ...string) tls.Certificate {
+	var key crypto.Signer
+	var err error
+	switch keyType {
+	case "rsa":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ecdsa":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ed25519":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: hosts[0], Organization: []string{"Test"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// fakeTLSServer Accept one connection, run the preamble, then handshake with the certificate
+func fakeTLSServer(t *testing.T, certificate tls.Certificate, preamble func(conn net.Conn) error) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if err := preamble(conn); err != nil {
+			return
+		}
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{certificate}})
+		tlsConn.Handshake()
+		tlsConn.Close()
+	}()
+	return listener.Addr().String()
+}
+
+func TestNewTLSCertificate(t *testing.T) {
+	tests := []struct {
+		keyType string
+		keySize int
+		name    string
+	}{
+		{"rsa", 2048, "RSA"},
+		{"ecdsa", 256, "ECDSA"},
+		{"ed25519", 256, "Ed25519"},
+	}
+
+	for _, test := range tests {
+		certificate := testCertificate(t, test.keyType, "example.test", "*.wild.test", "127.0.0.1")
+		parsed, _ := x509.ParseCertificate(certificate.Certificate[0])
+		info := newTLSCertificate(parsed)
+
+		if info.KeyType != test.name || info.KeySize != test.keySize {
+			t.Errorf("Wrong key for %s, got %s %d", test.keyType, info.KeyType, info.KeySize)
+		}
+		if !info.SelfSigned || info.Expired || info.SerialNumber != "42" || info.Subject != "CN=example.test,O=Test" || info.Issuer != info.Subject {
+			t.Errorf("Wrong certificate details %+v", info)
+		}
+		if !reflect.DeepEqual(info.DNSNames, []string{"example.test", "*.wild.test"}) || !reflect.DeepEqual(info.IPAddresses, []string{"127.0.0.1"}) {
+			t.Errorf("Wrong SANs %v %v", info.DNSNames, info.IPAddresses)
+		}
+		if len(info.SHA256) != 64 {
+			t.Errorf("Wrong fingerprint %s", info.SHA256)
+		}
+	}
+}
+
+func TestNewTLSInfo(t *testing.T) {
+	tests := []struct {
+		state       tls.ConnectionState
+		version     string
+		cipherSuite string
+		ja3s        string
+	}{
+		{tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256}, "TLS 1.3", "TLS_AES_128_GCM_SHA256", "771,4865,43-51"},
+		{tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305, NegotiatedProtocol: "h2"}, "TLS 1.2", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256", "771,52392,16"},
+		{tls.ConnectionState{Version: tls.VersionTLS10, CipherSuite: 0x1234}, "TLS 1.0", "0x1234", "769,4660,"},
+	}
+
+	for _, test := range tests {
+		info := newTLSInfo(&test.state)
+		if info.Version != test.version || info.CipherSuite != test.cipherSuite || info.JA3S != test.ja3s {
+			t.Errorf("Wrong TLS info %s %s %s, expected %s %s %s", info.Version, info.CipherSuite, info.JA3S, test.version, test.cipherSuite, test.ja3s)
+		}
+	}
+}
+
+func TestHTTPGetTLSInfo(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{testCertificate(t, "ecdsa", "example.test", "*.wild.test", "www.example.test")}}
+	server.StartTLS()
+	defer server.Close()
+
+	client, _ := NewHTTPWithOptions(server.URL, HTTPOptions{HTTPConfig: HTTPConfig{InsecureSkipVerify: true}})
+	info, err := client.GetTLSInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.Hostnames, []string{"example.test", "wild.test", "www.example.test"}) {
+		t.Errorf("Wrong hostnames %v", info.Hostnames)
+	}
+	if info.Verified || info.VerifyError == "" || len(info.Certificates) != 1 || info.Certificates[0].KeyType != "ECDSA" {
+		t.Errorf("Wrong TLS info %+v", info)
+	}
+	if info.Version == "" || info.CipherSuite == "" || !strings.HasPrefix(info.JA3S, "77") || len(info.JA3SHash) != 32 {
+		t.Errorf("Wrong negotiated parameters %+v", info)
+	}
+
+	// Enrichment includes TLS
+	enrichment, err := client.Enrich(context.Background())
+	if err != nil || enrichment.TLS == nil || enrichment.TLS.Certificates[0].SHA256 != info.Certificates[0].SHA256 {
+		t.Errorf("Enrichment does not include TLS info")
+	}
+
+	// Plain HTTP
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	client, _ = NewHTTP(plain.URL)
+	if _, err := client.GetTLSInfo(context.Background()); err != ErrNoTLS {
+		t.Errorf("Expected ErrNoTLS, got %v", err)
+	}
+}
+
+func TestELKGetTLSInfo(t *testing.T) {
+	address := fakeTLSServer(t, testCertificate(t, "rsa", "elk.test"), func(conn net.Conn) error { return nil })
+
+	client, _ := NewELK("https://" + address)
+	info, err := client.GetTLSInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Certificates) != 1 || info.Certificates[0].KeyType != "RSA" || !reflect.DeepEqual(info.Hostnames, []string{"elk.test"}) {
+		t.Errorf("Wrong TLS info %+v", info)
+	}
+
+	client, _ = NewELK("http://" + address)
+	if _, err := client.GetTLSInfo(context.Background()); err != ErrNoTLS {
+		t.Errorf("Expected ErrNoTLS, got %v", err)
+	}
+}
+
+func TestFTPGetTLSInfo(t *testing.T) {
+	ftpPreamble := func(reply string) func(conn net.Conn) error {
+		return func(conn net.Conn) error {
+			fmt.Fprint(conn, "220-Welcome\r\n220 Ready\r\n")
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil || line != "AUTH TLS\r\n" {
+				return fmt.Errorf("unexpected command %q", line)
+			}
+			fmt.Fprint(conn, reply)
+			if !strings.HasPrefix(reply, "234") {
+				return fmt.Errorf("no tls")
+			}
+			return nil
+		}
+	}
+
+	address := fakeTLSServer(t, testCertificate(t, "ecdsa", "ftp.test"), ftpPreamble("234 AUTH TLS OK\r\n"))
+	client, _ := NewFTP("ftp://" + address)
+	info, err := client.GetTLSInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.Hostnames, []string{"ftp.test"}) {
+		t.Errorf("Wrong TLS info %+v", info)
+	}
+
+	address = fakeTLSServer(t, testCertificate(t, "ecdsa", "ftp.test"), ftpPreamble("502 Not implemented\r\n"))
+	client, _ = NewFTP("ftp://" + address)
+	if _, err := client.GetTLSInfo(context.Background()); err != ErrNoTLS {
+		t.Errorf("Expected ErrNoTLS, got %v", err)
+	}
+}
+
+func TestSQLGetTLSInfo(t *testing.T) {
+	mysqlPreamble := func(capabilities uint16) func(conn net.Conn) error {
+		return func(conn net.Conn) error {
+			payload := append([]byte{10}, "5.7.30\x00"...)
+			payload = append(payload, 1, 0, 0, 0)        // Connection id
+			payload = append(payload, "abcdefgh\x00"...) // Auth data and filler
+			payload = append(payload, byte(capabilities), byte(capabilities>>8))
+			payload = append(payload, make([]byte, 20)...)
+			conn.Write(append([]byte{byte(len(payload)), 0, 0, 0}, payload...))
+
+			request := make([]byte, 36)
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return err
+			}
+			if request[3] != 1 || binary.LittleEndian.Uint32(request[4:])&mysqlClientSSL == 0 {
+				return fmt.Errorf("not an ssl request")
+			}
+			return nil
+		}
+	}
+
+	address := fakeTLSServer(t, testCertificate(t, "rsa", "db.test"), mysqlPreamble(0xffff))
+	client, err := NewSQL("user:pass@tcp(" + address + ")/db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.GetTLSInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.Hostnames, []string{"db.test"}) || info.Certificates[0].KeySize != 2048 {
+		t.Errorf("Wrong TLS info %+v", info)
+	}
+
+	address = fakeTLSServer(t, testCertificate(t, "rsa", "db.test"), mysqlPreamble(0xffff&^mysqlClientSSL))
+	client, _ = NewSQL("user:pass@tcp(" + address + ")/db")
+	if _, err := client.GetTLSInfo(context.Background()); err != ErrNoTLS {
+		t.Errorf("Expected ErrNoTLS, got %v", err)
+	}
+}
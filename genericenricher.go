@@ -26,6 +26,36 @@ type Server interface {
 	ResetReader() error // Go back to start of data
 }
 
+// TLSServer Optional interface of servers that can report their TLS connection and certificate chain.
+// HTTP, ELK, FTP, and SQL servers implement it.  Use GetTLSInfo to call it on any Server
+type TLSServer interface {
+	GetTLSInfo(ctx context.Context) (*enrichers.TLSInfo, error)
+}
+
+// GetTLSInfo Get details of the server's TLS connection and certificates.  enrichers.ErrNoTLS if the server
+// does not use TLS or can not report it
+func GetTLSInfo(ctx context.Context, server Server) (*enrichers.TLSInfo, error) {
+	if tlsServer, ok := server.(TLSServer); ok {
+		return tlsServer.GetTLSInfo(ctx)
+	}
+	return nil, enrichers.ErrNoTLS
+}
+
+// SizedServer Optional interface of servers that can estimate how much data they hold before reading it.
+// HTTP, ELK, FTP, SQL, MSSQL, and SQLite servers implement it.  Use GetSize to call it on any Server
+type SizedServer interface {
+	Size(ctx context.Context) (*enrichers.ServerSize, error)
+}
+
+// GetSize Get an estimate of the bytes and number of items on the server, to budget a scan before starting it.
+// enrichers.ErrNoSize if the server can not report it
+func GetSize(ctx context.Context, server Server) (*enrichers.ServerSize, error) {
+	if sizedServer, ok := server.(SizedServer); ok {
+		return sizedServer.Size(ctx)
+	}
+	return nil, enrichers.ErrNoSize
+}
+
 // GetServer Given a connection string, attempt to determine server type and return a Server, if you know the server type use GetServerWithType.
 func GetServer(connectString string) (Server, error) {
 	// Detect type
@@ -45,6 +75,10 @@ func GetServerWithType(connectString string, serverType enrichers.ServerType) (S
 		return enrichers.NewSQL(connectString)
 	case enrichers.HTTP:
 		return enrichers.NewHTTP(connectString)
+	case enrichers.MSSQL:
+		return enrichers.NewMSSQL(connectString)
+	case enrichers.SQLite:
+		return enrichers.NewSQLite(connectString)
 	default:
 		return nil, errors.New("unknown server type")
 	}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/vertoforce/genericenricher/enrichers"
@@ -88,3 +90,57 @@ func checkServerFunctionality(s Server, ip net.IP, port uint16) error {
 
 	return nil
 }
+
+// Servers that report TLS info
+var (
+	_ TLSServer = &enrichers.HTTPClient{}
+	_ TLSServer = &enrichers.ELKClient{}
+	_ TLSServer = &enrichers.FTPClient{}
+	_ TLSServer = &enrichers.SQLClient{}
+)
+
+// Servers that report their size
+var (
+	_ SizedServer = &enrichers.HTTPClient{}
+	_ SizedServer = &enrichers.ELKClient{}
+	_ SizedServer = &enrichers.FTPClient{}
+	_ SizedServer = &enrichers.SQLClient{}
+	_ SizedServer = &enrichers.MSSQLClient{}
+	_ SizedServer = &enrichers.SQLiteClient{}
+)
+
+func TestGetTLSInfo(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	s, err := enrichers.NewHTTPWithOptions(server.URL, enrichers.HTTPOptions{HTTPConfig: enrichers.HTTPConfig{InsecureSkipVerify: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := GetTLSInfo(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Certificates) == 0 {
+		t.Errorf("No certificates")
+	}
+}
+
+func TestGetSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	s, err := GetServerWithType(server.URL, enrichers.HTTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := GetSize(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size.Bytes != 5 || size.Items != 1 {
+		t.Errorf("Wrong size %+v", size)
+	}
+}
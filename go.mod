@@ -1,26 +1,23 @@
 module github.com/vertoforce/genericenricher
 
-go 1.12
+go 1.15
 
 require (
+	github.com/bodgit/sevenzip v1.1.0
+	github.com/denisenkom/go-mssqldb v0.9.0
 	github.com/fortytw2/leaktest v1.3.0 // indirect
 	github.com/go-sql-driver/mysql v1.4.1
-	github.com/golang/protobuf v1.3.2 // indirect
-	github.com/google/go-cmp v0.3.1 // indirect
 	github.com/jlaffaye/ftp v0.0.0-20191025175106-a59fe673c9b2
 	github.com/mailru/easyjson v0.7.0 // indirect
 	github.com/olivere/elastic v6.2.26+incompatible
 	github.com/pkg/errors v0.8.1 // indirect
 	github.com/stretchr/objx v0.2.0 // indirect
+	github.com/ulikunitz/xz v0.5.7
 	github.com/vertoforce/multiregex v0.0.0-20191205214147-7cfc691a8511
 	github.com/vertoforce/streamregex v0.0.0-20191205220918-91dbe6d4239e // indirect
-	golang.org/x/crypto v0.0.0-20191206172530-e9b2fee46413 // indirect
-	golang.org/x/net v0.0.0-20191209160850-c0dbc17a3553 // indirect
-	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e // indirect
-	golang.org/x/sys v0.0.0-20191210023423-ac6580df4449 // indirect
-	golang.org/x/tools v0.0.0-20191212051200-825cb0626375 // indirect
-	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
+	golang.org/x/net v0.0.0-20201021035429-f5854403a974
 	google.golang.org/appengine v1.6.5 // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/yaml.v2 v2.2.7 // indirect
+	modernc.org/sqlite v1.10.6
 )